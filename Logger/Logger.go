@@ -33,6 +33,7 @@ func ZapLogger() fiber.Handler {
 			zap.String("ip", c.IP()),
 			zap.String("method", c.Method()),
 			zap.String("path", c.OriginalURL()),
+			zap.Any("request_id", c.Locals("requestID")),
 		)
 
 		return err
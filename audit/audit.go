@@ -0,0 +1,339 @@
+// Package audit records who changed what, in an append-only log that is
+// hash-chained per organization so tampering can be detected.
+//
+// Handlers attach the caller with WithActor, run their changes in
+// Transaction and call Record inside it for every change, so an entry is
+// written if and only if the change commits.
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/models"
+	"gorm.io/gorm"
+)
+
+// SystemOrg is the chain for changes that belong to no organization, such as
+// a password reset requested by someone who is not signed in.
+var SystemOrg = uuid.Nil
+
+// Redacted stands in for values that must not be stored, such as passwords.
+const Redacted = "[redacted]"
+
+// ignoredFields change on every save and say nothing about what was done.
+var ignoredFields = map[string]bool{"updated_at": true}
+
+const (
+	actorKey   = "audit:actor"
+	pendingKey = "audit:pending"
+)
+
+// Actor is who is making a change and from where.
+type Actor struct {
+	UserID    *uuid.UUID
+	OrgID     *uuid.UUID
+	APIKeyID  *uuid.UUID
+	IP        string
+	RequestID string
+}
+
+// ActorFrom reads the caller from what utils.AuthMiddleware and RequestID
+// stored on the request. Unauthenticated requests give an actor with only
+// the IP and request ID.
+func ActorFrom(c *fiber.Ctx) Actor {
+	actor := Actor{IP: c.IP()}
+	actor.RequestID, _ = c.Locals("requestID").(string)
+	actor.UserID = localUUID(c, "userID")
+	actor.OrgID = localUUID(c, "orgID")
+	actor.APIKeyID = localUUID(c, "apiKeyID")
+	return actor
+}
+
+func localUUID(c *fiber.Ctx, key string) *uuid.UUID {
+	s, ok := c.Locals(key).(string)
+	if !ok {
+		return nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// WithActor returns db carrying actor, for Record calls in transactions
+// started from it.
+func WithActor(db *gorm.DB, actor Actor) *gorm.DB {
+	return db.Set(actorKey, actor)
+}
+
+func actorOf(tx *gorm.DB) Actor {
+	actor, _ := tx.Get(actorKey)
+	a, _ := actor.(Actor)
+	return a
+}
+
+// pending holds a transaction's entries until it is about to commit.
+type pending struct {
+	entries []models.AuditLog
+}
+
+// Transaction runs fn in a transaction on db. Entries Record adds in it are
+// appended to their chains after fn returns, just before the commit, taking
+// the chain locks in a fixed order. Appending as the changes are made would
+// take a chain lock in the middle of the transaction, and a transaction
+// holding it while waiting for a row another holds, which in turn waits for
+// the chain, would deadlock.
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		queue := &pending{}
+		if err := fn(tx.Set(pendingKey, queue).Session(&gorm.Session{})); err != nil {
+			return err
+		}
+		return queue.flush(tx)
+	})
+}
+
+// flush appends the queued entries, chain by chain in order of organization
+// ID, each chain's in the order they were recorded.
+func (p *pending) flush(tx *gorm.DB) error {
+	sort.SliceStable(p.entries, func(i, j int) bool {
+		return p.entries[i].OrgID.String() < p.entries[j].OrgID.String()
+	})
+	for i := 0; i < len(p.entries); {
+		j := i
+		for j < len(p.entries) && p.entries[j].OrgID == p.entries[i].OrgID {
+			j++
+		}
+		if err := appendChain(tx, p.entries[i:j]); err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+// appendChain appends entries, which belong to one organization, to its
+// chain.
+func appendChain(tx *gorm.DB, entries []models.AuditLog) error {
+	orgID := entries[0].OrgID
+	// One writer per chain at a time, held until the transaction ends, so
+	// entries get consecutive sequence numbers and link to their predecessor.
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "audit:"+orgID.String()).Error; err != nil {
+		return err
+	}
+	var last models.AuditLog
+	if err := tx.Select("seq", "hash").Where("org_id = ?", orgID).
+		Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	// Postgres keeps microseconds; truncate so the hash survives the round trip.
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for i := range entries {
+		entry := &entries[i]
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+		entry.CreatedAt = now
+		entry.Hash = Hash(entry)
+		last = *entry
+	}
+	return tx.Create(&entries).Error
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// RequestID tags each request with an ID, taken from a well-formed
+// X-Request-ID header or generated, and echoes it in the response so clients
+// and audit entries can be matched up.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Locals("requestID", id)
+		c.Set(fiber.HeaderXRequestID, id)
+		return c.Next()
+	}
+}
+
+// Entry is a change to record. Changes may be given directly; otherwise they
+// are the fields that differ between Before and After, either of which is
+// nil for a creation or deletion. OrgID and ActorID default to the actor's.
+type Entry struct {
+	OrgID      *uuid.UUID
+	ActorID    *uuid.UUID
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     interface{}
+	After      interface{}
+	Changes    map[string]models.AuditChange
+}
+
+// Record adds e to its organization's chain as part of tx, which should come
+// from Transaction; outside one the entry is appended at once. An update in
+// which nothing changed is not recorded.
+func Record(tx *gorm.DB, e Entry) error {
+	changes := e.Changes
+	if changes == nil {
+		var err error
+		if changes, err = Diff(e.Before, e.After); err != nil {
+			return err
+		}
+		if e.Before != nil && e.After != nil && len(changes) == 0 {
+			return nil
+		}
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	actor := actorOf(tx)
+	entry := models.AuditLog{
+		OrgID:      SystemOrg,
+		ActorID:    actor.UserID,
+		APIKeyID:   actor.APIKeyID,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Changes:    models.AuditChanges(raw),
+		IP:         actor.IP,
+		RequestID:  actor.RequestID,
+	}
+	if e.OrgID != nil {
+		entry.OrgID = *e.OrgID
+	} else if actor.OrgID != nil {
+		entry.OrgID = *actor.OrgID
+	}
+	if e.ActorID != nil {
+		entry.ActorID = e.ActorID
+	}
+
+	if queue, ok := tx.Get(pendingKey); ok {
+		p := queue.(*pending)
+		p.entries = append(p.entries, entry)
+		return nil
+	}
+	return appendChain(tx, []models.AuditLog{entry})
+}
+
+// Diff returns the fields whose JSON values differ between before and after,
+// which are structs or maps. Fields hidden from JSON are never included.
+func Diff(before, after interface{}) (map[string]models.AuditChange, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]models.AuditChange{}
+	for name, value := range a {
+		if old, ok := b[name]; (!ok || !reflect.DeepEqual(old, value)) && !ignoredFields[name] {
+			changes[name] = models.AuditChange{Before: b[name], After: value}
+		}
+	}
+	for name, old := range b {
+		if _, ok := a[name]; !ok && !ignoredFields[name] {
+			changes[name] = models.AuditChange{Before: old}
+		}
+	}
+	return changes, nil
+}
+
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("audit: %T is not an object: %w", v, err)
+	}
+	return m, nil
+}
+
+// Hash is the SHA-256 of an entry's fields, including PrevHash, in a fixed
+// order.
+func Hash(entry *models.AuditLog) string {
+	optional := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	canonical, _ := json.Marshal([]interface{}{
+		entry.OrgID.String(),
+		entry.Seq,
+		optional(entry.ActorID),
+		optional(entry.APIKeyID),
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		string(entry.Changes),
+		entry.IP,
+		entry.RequestID,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		entry.PrevHash,
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyBatch is how many entries Verify reads at a time.
+const verifyBatch = 500
+
+// Verify walks an organization's chain from the start, recomputing every
+// hash, and reports the first entry that is missing, altered or out of place.
+func Verify(db *gorm.DB, orgID uuid.UUID) (models.AuditVerification, error) {
+	var result models.AuditVerification
+	var seq int64
+	prev := ""
+	for {
+		var batch []models.AuditLog
+		if err := db.Where("org_id = ? AND seq > ?", orgID, seq).
+			Order("seq").Limit(verifyBatch).Find(&batch).Error; err != nil {
+			return result, err
+		}
+		for i := range batch {
+			entry := &batch[i]
+			switch {
+			case entry.Seq != seq+1:
+				result.BadSeq, result.Problem = seq+1, "entry is missing"
+			case entry.PrevHash != prev:
+				result.BadSeq, result.Problem = entry.Seq, "entry does not link to the previous one"
+			case Hash(entry) != entry.Hash:
+				result.BadSeq, result.Problem = entry.Seq, "entry has been altered"
+			}
+			if result.Problem != "" {
+				return result, nil
+			}
+			seq, prev = entry.Seq, entry.Hash
+			result.Entries++
+		}
+		if len(batch) < verifyBatch {
+			break
+		}
+	}
+	result.Valid = true
+	result.Head = prev
+	return result, nil
+}
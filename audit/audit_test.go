@@ -0,0 +1,92 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+	"gorm.io/gorm"
+)
+
+func TestVerify(t *testing.T) {
+	db := testdb.Open(t)
+	orgID := uuid.New()
+	record := func(tx *gorm.DB, action string) error {
+		return audit.Record(tx, audit.Entry{OrgID: &orgID, Action: action, EntityType: "product", EntityID: "p1",
+			Changes: map[string]models.AuditChange{"quantity": {After: action}}})
+	}
+	if err := audit.Transaction(db, func(tx *gorm.DB) error {
+		if err := record(tx, "first"); err != nil {
+			return err
+		}
+		return record(tx, "second")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := record(db, "third"); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.Transaction(db, func(tx *gorm.DB) error { return record(tx, "fourth") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.Transaction(db, func(tx *gorm.DB) error {
+		if err := record(tx, "rolled back"); err != nil {
+			return err
+		}
+		return gorm.ErrInvalidData
+	}); err != gorm.ErrInvalidData {
+		t.Fatalf("rolled back transaction: %v", err)
+	}
+
+	result, err := audit.Verify(db, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var head models.AuditLog
+	if err := db.Where("org_id = ?", orgID).Order("seq DESC").First(&head).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.Entries != 4 || result.Head != head.Hash || head.Action != "fourth" {
+		t.Fatalf("Verify = %+v, head %d %q; want 4 valid entries ending in fourth", result, head.Seq, head.Action)
+	}
+
+	tests := []struct {
+		name        string
+		tamper      string
+		wantValid   bool
+		wantEntries int64
+		wantBadSeq  int64
+		wantProblem string
+	}{
+		{"altered entry", `UPDATE audit_logs SET action = 'forged' WHERE org_id = ? AND seq = 2`, false, 1, 2, "entry has been altered"},
+		{"removed entry", `DELETE FROM audit_logs WHERE org_id = ? AND seq = 2`, false, 1, 2, "entry is missing"},
+		{"relinked entry", `UPDATE audit_logs SET prev_hash = '' WHERE org_id = ? AND seq = 3`, false, 2, 3, "entry does not link to the previous one"},
+		{"truncated tail", `DELETE FROM audit_logs WHERE org_id = ? AND seq = 4`, true, 3, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Tamper inside a transaction that is rolled back, with the
+			// append-only trigger off for its duration.
+			tx := db.Begin()
+			defer tx.Rollback()
+			if err := tx.Exec(`ALTER TABLE audit_logs DISABLE TRIGGER audit_logs_no_change`).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Exec(tt.tamper, orgID).Error; err != nil {
+				t.Fatal(err)
+			}
+			got, err := audit.Verify(tx, orgID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Valid != tt.wantValid || got.Entries != tt.wantEntries || got.BadSeq != tt.wantBadSeq || got.Problem != tt.wantProblem {
+				t.Errorf("Verify = %+v, want valid %v, %d entries, bad seq %d %q", got, tt.wantValid, tt.wantEntries, tt.wantBadSeq, tt.wantProblem)
+			}
+			if tt.wantValid && got.Head == head.Hash {
+				t.Error("head unchanged after truncating the chain")
+			}
+		})
+	}
+}
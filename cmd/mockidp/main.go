@@ -0,0 +1,332 @@
+// Command mockidp is an OpenID Connect identity provider for trying single
+// sign-on locally. It has no accounts: the sign-in page lets you type the
+// claims you want, such as email and groups, and issues tokens for them.
+// Never expose it to a network anyone else can reach.
+//
+//	go run ./cmd/mockidp -addr :9000 -client-id inventory
+//
+// then start the API with
+//
+//	OIDC_DISCOVERY_URL=http://localhost:9000/.well-known/openid-configuration
+//	OIDC_CLIENT_ID=inventory
+//	OIDC_REDIRECT_URL=http://localhost:8080/auth/oidc/callback
+//
+// and open http://localhost:8080/auth/oidc/login in a browser.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const keyID = "mockidp"
+
+// grant is an issued authorization code and what it was issued for.
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      jwt.MapClaims
+	expires     time.Time
+}
+
+type idp struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]*grant
+	access map[string]jwt.MapClaims
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "", "issuer URL (default http://localhost<addr>)")
+	clientID := flag.String("client-id", "inventory", "the only client ID accepted")
+	clientSecret := flag.String("client-secret", "", "client secret to require, if any")
+	flag.Parse()
+	if *issuer == "" {
+		*issuer = "http://localhost" + *addr
+	}
+
+	p, err := newIDP(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Mock identity provider %s for client %q listening on %s\n", p.issuer, p.clientID, *addr)
+	log.Fatal(http.ListenAndServe(*addr, p.handler()))
+}
+
+// newIDP returns a provider with a fresh signing key.
+func newIDP(issuer, clientID, clientSecret string) (*idp, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &idp{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		codes:        map[string]*grant{},
+		access:       map[string]jwt.MapClaims{},
+	}, nil
+}
+
+func (p *idp) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/userinfo", p.userinfo)
+	return mux
+}
+
+func (p *idp) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"userinfo_endpoint":                     p.issuer + "/userinfo",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile", "groups"},
+	})
+}
+
+func (p *idp) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+var signInPage = template.Must(template.New("signin").Parse(`<!doctype html>
+<title>Mock identity provider</title>
+<h1>Sign in to the mock identity provider</h1>
+<form method="post">
+{{range $name, $values := .Query}}<input type="hidden" name="{{$name}}" value="{{index $values 0}}">
+{{end}}
+<p><label>Subject <input name="sub" value="{{.Email}}" required></label>
+<p><label>Email <input name="email" type="email" value="{{.Email}}" required></label>
+<label><input name="email_verified" type="checkbox" checked> verified</label>
+<p><label>Username <input name="preferred_username"></label>
+<p><label>Name <input name="name"></label>
+<p><label>Groups <input name="groups" placeholder="comma separated"></label>
+<p><label><input name="mfa" type="checkbox"> signed in with a second factor</label>
+<p><button>Sign in</button> <button name="deny" value="1">Deny</button>
+</form>`))
+
+// authorize shows the sign-in page, and on submit sends the browser back to
+// the client with a code for the claims entered.
+func (p *idp) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+	if q.Get("client_id") != p.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := func(params url.Values) {
+		params.Set("state", q.Get("state"))
+		redirect.RawQuery = params.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	}
+	if q.Get("response_type") != "code" {
+		back(url.Values{"error": {"unsupported_response_type"}})
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		back(url.Values{"error": {"invalid_request"}, "error_description": {"PKCE with S256 is required"}})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		params := url.Values{}
+		for _, name := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			params.Set(name, q.Get(name))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = signInPage.Execute(w, map[string]interface{}{"Query": params, "Email": q.Get("login_hint")})
+		return
+	}
+	if q.Get("deny") != "" {
+		back(url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"sub":            q.Get("sub"),
+		"email":          q.Get("email"),
+		"email_verified": q.Get("email_verified") != "",
+		"amr":            []string{"pwd"},
+	}
+	if q.Get("mfa") != "" {
+		claims["amr"] = []string{"pwd", "otp", "mfa"}
+	}
+	for _, name := range []string{"preferred_username", "name"} {
+		if v := q.Get(name); v != "" {
+			claims[name] = v
+		}
+	}
+	if groups := strings.TrimSpace(q.Get("groups")); groups != "" {
+		var list []string
+		for _, g := range strings.Split(groups, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				list = append(list, g)
+			}
+		}
+		claims["groups"] = list
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = &grant{
+		clientID:    p.clientID,
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		claims:      claims,
+		expires:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+	back(url.Values{"code": {code}})
+}
+
+// token redeems a code for an ID token and an access token, checking the
+// client, the redirect URI and the PKCE verifier.
+func (p *idp) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		tokenError(w, "invalid_request", "POST a form")
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.clientID || (p.clientSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(p.clientSecret)) != 1) {
+		tokenError(w, "invalid_client", "")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	p.mu.Lock()
+	g := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if g == nil || time.Now().After(g.expires) || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", "unknown, used or expired code")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		tokenError(w, "invalid_grant", "code_verifier does not match code_challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.issuer,
+		"aud":   g.clientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": g.nonce,
+	}
+	for name, value := range g.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessToken := randomString()
+	p.mu.Lock()
+	p.access[accessToken] = g.claims
+	p.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *idp) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	claims, ok := p.access[token]
+	p.mu.Unlock()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	status := http.StatusBadRequest
+	if code == "invalid_client" {
+		status = http.StatusUnauthorized
+	}
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
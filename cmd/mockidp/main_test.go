@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/oidc"
+)
+
+const (
+	testClientID    = "inventory"
+	testRedirectURL = "http://localhost:8080/auth/oidc/callback"
+)
+
+// startIDP serves a mock provider and returns it with a relying party that
+// trusts it.
+func startIDP(t *testing.T) (*idp, *oidc.Provider) {
+	t.Helper()
+	p, err := newIDP("", testClientID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(p.handler())
+	t.Cleanup(srv.Close)
+	p.issuer = srv.URL
+	provider := oidc.New(oidc.Config{
+		DiscoveryURL: srv.URL + "/.well-known/openid-configuration",
+		ClientID:     testClientID,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email"},
+	})
+	return p, provider
+}
+
+func TestVerifyIDTokenFromSignIn(t *testing.T) {
+	_, provider := startIDP(t)
+	ctx := context.Background()
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := url.Values{"sub": {"alice"}, "email": {"alice@example.com"}, "email_verified": {"on"}}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.PostForm(authURL, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Query().Get("code") == "" {
+		t.Fatalf("sign-in redirected to %q, want a code", resp.Header.Get("Location"))
+	}
+
+	tokens, err := provider.Exchange(ctx, location.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.String("sub") != "alice" || claims.String("email") != "alice@example.com" || !claims.Bool("email_verified") {
+		t.Errorf("claims = %v", claims)
+	}
+	if _, err := provider.VerifyIDToken(ctx, tokens.IDToken, "another-nonce"); err == nil {
+		t.Error("token accepted with the wrong nonce")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	p, provider := startIDP(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   p.issuer,
+			"aud":   testClientID,
+			"sub":   "alice",
+			"iat":   now.Unix(),
+			"exp":   now.Add(5 * time.Minute).Unix(),
+			"nonce": "nonce-1",
+		}
+	}
+	with := func(name string, value interface{}) jwt.MapClaims {
+		claims := valid()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		method  jwt.SigningMethod
+		key     interface{}
+		kid     string
+		claims  jwt.MapClaims
+		wantErr string
+	}{
+		{"valid", jwt.SigningMethodRS256, p.key, keyID, valid(), ""},
+		{"no kid with a single provider key", jwt.SigningMethodRS256, p.key, "", valid(), ""},
+		{"several audiences including ours with azp", jwt.SigningMethodRS256, p.key, keyID, func() jwt.MapClaims {
+			claims := with("aud", []string{testClientID, "other"})
+			claims["azp"] = testClientID
+			return claims
+		}(), ""},
+		{"signed with another key", jwt.SigningMethodRS256, otherKey, keyID, valid(), "verification error"},
+		{"unknown kid", jwt.SigningMethodRS256, p.key, "rotated", valid(), "unknown signing key"},
+		{"algorithm the key is not for", jwt.SigningMethodRS512, p.key, keyID, valid(), "not RS512"},
+		{"shared-secret algorithm", jwt.SigningMethodHS256, []byte("secret"), keyID, valid(), "signing method HS256 is invalid"},
+		{"wrong issuer", jwt.SigningMethodRS256, p.key, keyID, with("iss", "https://evil.example.com"), "wrong issuer"},
+		{"another client's token", jwt.SigningMethodRS256, p.key, keyID, with("aud", "someone-else"), "not issued for this client"},
+		{"several audiences without azp", jwt.SigningMethodRS256, p.key, keyID, with("aud", []string{testClientID, "other"}), "authorized party"},
+		{"expired", jwt.SigningMethodRS256, p.key, keyID, with("exp", now.Add(-time.Minute).Unix()), "expired"},
+		{"no expiry", jwt.SigningMethodRS256, p.key, keyID, with("exp", nil), "no expiry"},
+		{"wrong nonce", jwt.SigningMethodRS256, p.key, keyID, with("nonce", "replayed"), "nonce mismatch"},
+		{"no subject", jwt.SigningMethodRS256, p.key, keyID, with("sub", nil), "no subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(tt.method, tt.claims)
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+			raw, err := token.SignedString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := provider.VerifyIDToken(context.Background(), raw, "nonce-1")
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("VerifyIDToken: %v", err)
+			case tt.wantErr == "" && claims.String("sub") != "alice":
+				t.Errorf("sub = %q, want alice", claims.String("sub"))
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("VerifyIDToken error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// ssoSignIn signs in at the API through the mock provider as sub with email,
+// which the provider reports as verified, and returns the callback's status.
+func ssoSignIn(t *testing.T, app *fiber.App, sub, email string) int {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/auth/oidc/login", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusFound {
+		t.Fatalf("login: status %d", resp.StatusCode)
+	}
+	cookies := resp.Cookies()
+
+	form := url.Values{"sub": {sub}, "email": {email}, "email_verified": {"on"}}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	idpResp, err := client.PostForm(resp.Header.Get(fiber.HeaderLocation), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idpResp.Body.Close()
+	callback, err := url.Parse(idpResp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(fiber.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	resp, err = app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSSOLinksOnlyVerifiedAccounts(t *testing.T) {
+	app := testdb.App(t)
+	_, provider := startIDP(t)
+	saved := oidc.Default
+	oidc.Default = provider
+	t.Cleanup(func() { oidc.Default = saved })
+
+	// Someone registers the address first and never verifies it; signing in
+	// with SSO must not hand the account, and its password, to the owner.
+	squatted := testdb.Register(t, app)
+	if status := ssoSignIn(t, app, "owner-"+squatted.Username, squatted.Email); status != fiber.StatusConflict {
+		t.Errorf("linking an unverified account: status %d, want 409", status)
+	}
+
+	verified, _ := testdb.SignUp(t, app)
+	if status := ssoSignIn(t, app, "owner-"+verified.Username, verified.Email); status != fiber.StatusOK {
+		t.Errorf("linking a verified account: status %d, want 200", status)
+	}
+}
@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/mailer"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const accountFile = "AccountController"
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+)
+
+// appURL is where links in emails point, from APP_URL.
+func appURL() string {
+	if url := strings.TrimRight(os.Getenv("APP_URL"), "/"); url != "" {
+		return url
+	}
+	return "http://localhost:8080"
+}
+
+// issueAccountToken creates a token for purpose that expires after ttl,
+// replacing any unused token of the same purpose the user already has.
+func issueAccountToken(tx *gorm.DB, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Delete(&models.AccountToken{}).Error; err != nil {
+		return "", err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Create(&models.AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+var errInvalidAccountToken = fiber.NewError(fiber.StatusBadRequest, "Invalid or expired token")
+
+// consumeAccountToken marks token used and returns it, provided it was issued
+// for purpose, has not been used and has not expired.
+func consumeAccountToken(tx *gorm.DB, token, purpose string) (*models.AccountToken, error) {
+	var stored models.AccountToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&stored, "token_hash = ? AND purpose = ?", hashToken(token), purpose).Error; err != nil {
+		return nil, errInvalidAccountToken
+	}
+	now := time.Now()
+	if stored.UsedAt != nil || now.After(stored.ExpiresAt) {
+		return nil, errInvalidAccountToken
+	}
+	stored.UsedAt = &now
+	if err := tx.Model(&stored).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func verificationEmail(user *models.User, token string) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Confirm this is your email address by opening the link below:\n\n" +
+			appURL() + "/verify-email?token=" + token + "\n\n" +
+			"The link expires in 48 hours. If you did not create an account, ignore this email.\n",
+	}
+}
+
+func passwordResetEmail(user *models.User, token string) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Someone asked to reset the password for your account. To choose a new one, open the link below:\n\n" +
+			appURL() + "/reset-password?token=" + token + "\n\n" +
+			"The link expires in 1 hour and works once. If you did not ask for this, ignore this email; your password is unchanged.\n",
+	}
+}
+
+// passwordProblemFor applies the password policy to a new password for user.
+func passwordProblemFor(user *models.User, password string) string {
+	local, _, _ := strings.Cut(user.Email, "@")
+	return utils.PasswordProblem(password, user.Username, local)
+}
+
+// deliver sends msg, logging rather than returning failures: the request that
+// triggered it has already succeeded and the user can ask for another email.
+func deliver(function string, msg mailer.Message) {
+	if err := mailer.Send(msg); err != nil {
+		logger.Log.Error("Package controllers File "+accountFile, zap.String("Function", function), zap.String("Message", "Failed to send email"), zap.String("subject", msg.Subject), zap.Error(err))
+	}
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset
+// @Description  Emails a single-use password reset link, valid for an hour, if the address belongs to an account. The response is the same whether or not it does, so it cannot be used to discover accounts.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ForgotPasswordRequest  true  "Account email"
+// @Success      202      {object}  map[string]string
+// @Failure      400      {object}  map[string]string  "Invalid input"
+// @Router       /password/forgot [post]
+func ForgotPassword(c *fiber.Ctx) error {
+	var input models.ForgotPasswordRequest
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Email) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "email is required"})
+	}
+	accepted := c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "If the email belongs to an account, a reset link has been sent"})
+
+	var user models.User
+	if err := database.DB.Where("LOWER(email) = LOWER(?)", strings.TrimSpace(input.Email)).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Log.Error("Package controllers File "+accountFile, zap.String("Function", "ForgotPassword"), zap.String("Message", "Failed to look up user"), zap.Error(err))
+		}
+		return accepted
+	}
+	token, err := issueAccountToken(database.DB, user.UserID, models.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+accountFile, zap.String("Function", "ForgotPassword"), zap.String("Message", "Failed to issue reset token"), zap.Error(err))
+		return accepted
+	}
+	// Send in the background so the response time does not reveal whether
+	// the account exists.
+	go deliver("ForgotPassword", passwordResetEmail(&user, token))
+
+	logger.Log.Info("Package controllers File "+accountFile, zap.String("Function", "ForgotPassword"), zap.String("Message", "Password reset requested"), zap.String("user_id", user.UserID.String()), zap.String("ip", c.IP()))
+	return accepted
+}
+
+// resetPassword sets password on the account token was issued to and revokes
+// its sessions, returning the account's ID.
+func resetPassword(c *fiber.Ctx, token, password string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := auditTransaction(c, func(tx *gorm.DB) error {
+		stored, err := consumeAccountToken(tx, token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = stored.UserID
+		var user models.User
+		if err := tx.First(&user, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		// Rejecting the password rolls back, so the token can be tried again.
+		if msg := passwordProblemFor(&user, password); msg != "" {
+			return fiber.NewError(fiber.StatusBadRequest, msg)
+		}
+		hashed, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&models.User{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"password":          hashed,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", now),
+		}).Error; err != nil {
+			return err
+		}
+		if _, err = revokeUserSessions(tx, userID); err != nil {
+			return err
+		}
+		updated := user
+		updated.Password = hashed
+		if updated.EmailVerifiedAt == nil {
+			updated.EmailVerifiedAt = &now
+		}
+		return auditUser(tx, "user.update", &user, &updated, passwordAudit(user.Password != ""), &userID)
+	})
+	return userID, err
+}
+
+// ResetPassword godoc
+// @Summary      Reset a password
+// @Description  Sets a new password using the token from a password reset email. The token works once. Every session of the account is revoked, so the new password must be used to sign in again. Completing a reset also verifies the email address, since the link was delivered to it.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ResetPasswordRequest  true  "Reset token and new password"
+// @Success      204
+// @Failure      400      {object}  map[string]string  "Invalid input, weak password, or invalid or expired token"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Router       /password/reset [post]
+func ResetPassword(c *fiber.Ctx) error {
+	var input models.ResetPasswordRequest
+	if err := c.BodyParser(&input); err != nil || input.Token == "" || input.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token and password are required"})
+	}
+
+	userID, err := resetPassword(c, input.Token, input.Password)
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+accountFile, zap.String("Function", "ResetPassword"), zap.String("Message", "Failed to reset password"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+accountFile, zap.String("Function", "ResetPassword"), zap.String("Message", "Password reset"), zap.String("user_id", userID.String()), zap.String("ip", c.IP()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// verifyEmailToken marks the email address of the account token was issued to
+// as verified, returning the account's ID.
+func verifyEmailToken(c *fiber.Ctx, token string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := auditTransaction(c, func(tx *gorm.DB) error {
+		stored, err := consumeAccountToken(tx, token, models.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+		userID = stored.UserID
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		if err := tx.Model(&models.User{}).Where("user_id = ?", userID).Update("email_verified_at", now).Error; err != nil {
+			return err
+		}
+		updated := user
+		updated.EmailVerifiedAt = &now
+		return auditUser(tx, "user.update", &user, &updated, nil, &userID)
+	})
+	return userID, err
+}
+
+// VerifyEmail godoc
+// @Summary      Verify an email address
+// @Description  Marks the account's email address as verified using the token from a verification email. Access tokens issued before verification still say unverified; refresh to get one that unlocks the inventory.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.VerifyEmailRequest  true  "Verification token"
+// @Success      204
+// @Failure      400      {object}  map[string]string  "Invalid input, or invalid or expired token"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Router       /email/verify [post]
+func VerifyEmail(c *fiber.Ctx) error {
+	var input models.VerifyEmailRequest
+	if err := c.BodyParser(&input); err != nil || input.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token is required"})
+	}
+
+	userID, err := verifyEmailToken(c, input.Token)
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+accountFile, zap.String("Function", "VerifyEmail"), zap.String("Message", "Failed to verify email"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+accountFile, zap.String("Function", "VerifyEmail"), zap.String("Message", "Email verified"), zap.String("user_id", userID.String()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ResendVerification godoc
+// @Summary      Resend the verification email
+// @Description  Sends a new email verification link to the authenticated user, replacing any earlier one
+// @Tags         Auth
+// @Produce      json
+// @Success      202  {object}  map[string]string
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      409  {object}  map[string]string  "Already verified"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /email/verify/resend [post]
+func ResendVerification(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "user_id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	if user.EmailVerifiedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email address is already verified"})
+	}
+	token, err := issueAccountToken(database.DB, user.UserID, models.TokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+accountFile, zap.String("Function", "ResendVerification"), zap.String("Message", "Failed to issue verification token"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	deliver("ResendVerification", verificationEmail(&user, token))
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Verification email sent"})
+}
@@ -0,0 +1,100 @@
+package controllers_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/mailer"
+	"github.com/lokesh2201013/models"
+)
+
+// mailedToken waits for the newest email to to whose subject is subject and
+// returns the token in its link.
+func mailedToken(t *testing.T, to, subject string) string {
+	t.Helper()
+	memory, ok := mailer.Default.(*mailer.MemoryMailer)
+	if !ok {
+		t.Skip("the memory mailer is not in use")
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		msg, found := memory.Last(to)
+		if !found || msg.Subject != subject {
+			continue
+		}
+		i := strings.Index(msg.Body, "token=")
+		if i < 0 {
+			t.Fatalf("no token in %q", msg.Body)
+		}
+		return strings.Fields(msg.Body[i+len("token="):])[0]
+	}
+	t.Fatalf("no %q email to %s", subject, to)
+	return ""
+}
+
+func TestPasswordReset(t *testing.T) {
+	app := testdb.App(t)
+	user, bearer := testdb.SignUp(t, app)
+
+	if status := testdb.Call(t, app, fiber.MethodPost, "/password/forgot", "", models.ForgotPasswordRequest{Email: user.Email}, nil); status != fiber.StatusAccepted {
+		t.Fatalf("forgot: status %d", status)
+	}
+	token := mailedToken(t, user.Email, "Reset your password")
+	if status := testdb.Call(t, app, fiber.MethodGet, "/reset-password?token="+token, "", nil, nil); status != fiber.StatusOK {
+		t.Errorf("reset form: status %d, want 200", status)
+	}
+
+	form := url.Values{"token": {token}, "password": {"a new " + testdb.Password}}
+	submit := func() int {
+		req := httptest.NewRequest(fiber.MethodPost, "/reset-password", strings.NewReader(form.Encode()))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := submit(); status != fiber.StatusOK {
+		t.Fatalf("reset: status %d", status)
+	}
+	if status := submit(); status != fiber.StatusBadRequest {
+		t.Errorf("reusing the reset token: status %d, want 400", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products", bearer, nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("token from before the reset: status %d, want 401", status)
+	}
+	login := map[string]string{"username": user.Username, "password": testdb.Password}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/login", "", login, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("old password: status %d, want 401", status)
+	}
+	login["password"] = form.Get("password")
+	if status := testdb.Call(t, app, fiber.MethodPost, "/login", "", login, nil); status != fiber.StatusOK {
+		t.Errorf("new password: status %d, want 200", status)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	app := testdb.App(t)
+	user := testdb.Register(t, app)
+	bearer := testdb.Login(t, app, user.Username).AccessToken
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products", bearer, nil, nil); status != fiber.StatusForbidden {
+		t.Errorf("unverified user listing products: status %d, want 403", status)
+	}
+
+	token := mailedToken(t, user.Email, "Verify your email address")
+	if status := testdb.Call(t, app, fiber.MethodGet, "/verify-email?token="+token, "", nil, nil); status != fiber.StatusOK {
+		t.Fatalf("verify: status %d", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/email/verify", "", models.VerifyEmailRequest{Token: token}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("reusing the verification token: status %d, want 400", status)
+	}
+	bearer = testdb.Login(t, app, user.Username).AccessToken
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products", bearer, nil, nil); status != fiber.StatusOK {
+		t.Errorf("verified user listing products: status %d, want 200", status)
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+	"html/template"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/Logger"
+	"go.uber.org/zap"
+)
+
+// The links in verification and password reset emails open these pages in
+// a browser; API clients use POST /email/verify and POST /password/reset.
+
+var accountPage = template.Must(template.New("account").Parse(`<!doctype html>
+<title>{{.Title}}</title>
+<h1>{{.Title}}</h1>
+{{if .Message}}<p>{{.Message}}
+{{end}}{{if .Token}}<form method="post" action="/reset-password">
+<input type="hidden" name="token" value="{{.Token}}">
+<p><label>New password <input name="password" type="password" autocomplete="new-password" required></label>
+<p><button>Set password</button>
+</form>
+{{end}}`))
+
+type accountPageData struct {
+	Title   string
+	Message string
+	// Token shows the new password form for this reset token.
+	Token string
+}
+
+func renderAccountPage(c *fiber.Ctx, status int, page accountPageData) error {
+	c.Status(status)
+	c.Type("html", "utf-8")
+	// The token is in the URL; keep it out of Referer headers.
+	c.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+	return accountPage.Execute(c.Response().BodyWriter(), page)
+}
+
+// pageError is the status and message to show for an error from a token
+// handler, logging the ones the user cannot act on.
+func pageError(function string, err error) (int, string) {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code, fe.Message
+	}
+	logger.Log.Error("Package controllers File "+accountFile, zap.String("Function", function), zap.String("Message", "Failed to use account token"), zap.Error(err))
+	return fiber.StatusInternalServerError, "Something went wrong. Try again later."
+}
+
+// VerifyEmailLink godoc
+// @Summary      Open an email verification link
+// @Description  The link in a verification email. Verifies the address like POST /email/verify and shows the result as a page.
+// @Tags         Auth
+// @Produce      html
+// @Param        token  query  string  true  "Verification token"
+// @Success      200
+// @Failure      400  "Invalid or expired token"
+// @Router       /verify-email [get]
+func VerifyEmailLink(c *fiber.Ctx) error {
+	const title = "Verify your email address"
+	token := c.Query("token")
+	if token == "" {
+		return renderAccountPage(c, fiber.StatusBadRequest, accountPageData{Title: title, Message: "The link is missing its token."})
+	}
+	userID, err := verifyEmailToken(c, token)
+	if err != nil {
+		status, msg := pageError("VerifyEmailLink", err)
+		return renderAccountPage(c, status, accountPageData{Title: title, Message: msg})
+	}
+
+	logger.Log.Info("Package controllers File "+accountFile, zap.String("Function", "VerifyEmailLink"), zap.String("Message", "Email verified"), zap.String("user_id", userID.String()))
+	return renderAccountPage(c, fiber.StatusOK, accountPageData{Title: "Email address verified", Message: "You can close this page and sign in."})
+}
+
+// ResetPasswordForm godoc
+// @Summary      Open a password reset link
+// @Description  The link in a password reset email. Shows a form for the new password, which is submitted to POST /reset-password.
+// @Tags         Auth
+// @Produce      html
+// @Param        token  query  string  true  "Reset token"
+// @Success      200
+// @Failure      400  "Missing token"
+// @Router       /reset-password [get]
+func ResetPasswordForm(c *fiber.Ctx) error {
+	const title = "Reset your password"
+	token := c.Query("token")
+	if token == "" {
+		return renderAccountPage(c, fiber.StatusBadRequest, accountPageData{Title: title, Message: "The link is missing its token."})
+	}
+	return renderAccountPage(c, fiber.StatusOK, accountPageData{Title: title, Token: token})
+}
+
+// ResetPasswordSubmit godoc
+// @Summary      Submit the password reset form
+// @Description  Sets the new password from the password reset form like POST /password/reset and shows the result as a page. A rejected password shows the form again.
+// @Tags         Auth
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        token     formData  string  true  "Reset token"
+// @Param        password  formData  string  true  "New password"
+// @Success      200
+// @Failure      400  "Weak password, or invalid or expired token"
+// @Router       /reset-password [post]
+func ResetPasswordSubmit(c *fiber.Ctx) error {
+	const title = "Reset your password"
+	token, password := c.FormValue("token"), c.FormValue("password")
+	if token == "" {
+		return renderAccountPage(c, fiber.StatusBadRequest, accountPageData{Title: title, Message: "The link is missing its token."})
+	}
+	if password == "" {
+		return renderAccountPage(c, fiber.StatusBadRequest, accountPageData{Title: title, Message: "Enter a new password.", Token: token})
+	}
+	userID, err := resetPassword(c, token, password)
+	if err != nil {
+		status, msg := pageError("ResetPasswordSubmit", err)
+		page := accountPageData{Title: title, Message: msg}
+		if errors.Is(err, errInvalidAccountToken) {
+			page.Message = "The link is invalid or has expired. Ask for a new reset email."
+		} else if status == fiber.StatusBadRequest {
+			page.Token = token
+		}
+		return renderAccountPage(c, status, page)
+	}
+
+	logger.Log.Info("Package controllers File "+accountFile, zap.String("Function", "ResetPasswordSubmit"), zap.String("Message", "Password reset"), zap.String("user_id", userID.String()), zap.String("ip", c.IP()))
+	return renderAccountPage(c, fiber.StatusOK, accountPageData{Title: "Password changed", Message: "Sign in with your new password. Every device that was signed in has been signed out."})
+}
@@ -3,21 +3,28 @@ package controllers
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
 	"github.com/lokesh2201013/database"
 	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
 )
 
 // GetProductByID godoc
 // @Summary      Get a product by ID
-// @Description  Retrieves a single product based on the provided UUID in query parameter
+// @Description  Retrieves a single product based on the provided UUID in query parameter, along with its suppliers (preferred first)
 // @Tags         Products
 // @Produce      json
 // @Param        product_id  query     string  true  "Product UUID"  example("d290f1ee-6c54-4b01-90e6-d701748f0851")
-// @Success      200  {object}  models.Product
+// @Success      200  {object}  models.ProductDetail
 // @Failure      400  {object}  map[string]string  "Missing or invalid product_id"
 // @Failure      404  {object}  map[string]string  "Product not found"
+// @Failure      500  {object}  map[string]string  "Internal server error"
 // @Router       /products/get [get]
 func GetProductByID(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
 	productIDParam := c.Query("product_id")
 
 	if productIDParam == "" {
@@ -30,12 +37,43 @@ func GetProductByID(c *fiber.Ctx) error {
 	}
 
 	var product models.Product
-	err = database.DB.First(&product, "id = ?", productID).Error
+	err = database.DB.First(&product, "id = ? AND org_id = ?", productID, orgID).Error
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
 	}
 
-	return c.JSON(product)
+	available, err := availableQuantity(database.DB, &product)
+	if err != nil {
+		logger.Log.Error("Package controllers File AnalyticsController", zap.String("Function", "GetProductByID"), zap.String("Message", "Failed to compute available quantity"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compute available quantity"})
+	}
+
+	suppliers, err := productSuppliers(product.ID)
+	if err != nil {
+		logger.Log.Error("Package controllers File AnalyticsController", zap.String("Function", "GetProductByID"), zap.String("Message", "Failed to load product suppliers"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load product suppliers"})
+	}
+
+	units := []models.UnitConversion{}
+	if err := database.DB.Where("product_id = ?", product.ID).Order("factor").Find(&units).Error; err != nil {
+		logger.Log.Error("Package controllers File AnalyticsController", zap.String("Function", "GetProductByID"), zap.String("Message", "Failed to load product units"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load product units"})
+	}
+
+	detail := models.ProductDetail{Product: product, Available: available, Suppliers: suppliers, Units: units}
+	if product.IsBundle {
+		kits, err := buildableKits(database.DB, product.ID)
+		if err != nil {
+			logger.Log.Error("Package controllers File AnalyticsController", zap.String("Function", "GetProductByID"), zap.String("Message", "Failed to compute bundle availability"), zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compute bundle availability"})
+		}
+		detail.Available += kits
+		if detail.Components, err = bundleComponents(database.DB, product.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load bundle components"})
+		}
+	}
+
+	return c.JSON(detail)
 }
 
 // GetProductByQuantityExtremes godoc
@@ -53,19 +91,22 @@ func GetProductByQuantityExtremes(c *fiber.Ctx) error {
 	most := c.QueryBool("most")
 	least := c.QueryBool("least")
 
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
 	var product models.Product
-	var err error
 
 	switch {
 	case most:
-		err = database.DB.Order("quantity DESC").First(&product).Error
+		err = database.DB.Where("org_id = ?", orgID).Order("quantity DESC").First(&product).Error
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get product with highest quantity"})
 		}
 		return c.JSON(product)
 
 	case least:
-		err = database.DB.Order("quantity ASC").First(&product).Error
+		err = database.DB.Where("org_id = ?", orgID).Order("quantity ASC").First(&product).Error
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get product with lowest quantity"})
 		}
@@ -78,5 +119,91 @@ func GetProductByQuantityExtremes(c *fiber.Ctx) error {
 	}
 }
 
+// GetInventorySummary godoc
+// @Summary      Inventory dashboard summary
+// @Description  Returns totals, stock health counts, this week's top movers and a per-category breakdown for the products of the caller's active organization
+// @Tags         Analytics
+// @Produce      json
+// @Param        top  query     int  false  "Number of top movers to return (default: 5)"
+// @Success      200  {object}  models.InventorySummary
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /analytics/summary [get]
+func GetInventorySummary(c *fiber.Ctx) error {
+	const file = "AnalyticsController"
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+file, zap.String("Function", "GetInventorySummary"), zap.String("Message", "No active organization in context"), zap.Error(err))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	top := c.QueryInt("top", 5)
+	if top <= 0 {
+		top = 5
+	}
+
+	// Totals and the category breakdown come back from a single query: the
+	// empty grouping set yields the overall row, flagged by GROUPING(type) = 1.
+	var rows []struct {
+		Category   string  `gorm:"column:category"`
+		IsTotal    bool    `gorm:"column:is_total"`
+		SKUs       int64   `gorm:"column:skus"`
+		Units      int64   `gorm:"column:units"`
+		StockValue float64 `gorm:"column:stock_value"`
+		OutOfStock int64   `gorm:"column:out_of_stock"`
+		LowStock   int64   `gorm:"column:low_stock"`
+	}
+	err = database.DB.Raw(`
+		SELECT COALESCE(type, '') AS category,
+		       GROUPING(type) = 1 AS is_total,
+		       COUNT(*) AS skus,
+		       COALESCE(SUM(quantity), 0) AS units,
+		       COALESCE(SUM(quantity * price), 0) AS stock_value,
+		       COUNT(*) FILTER (WHERE quantity <= 0) AS out_of_stock,
+		       COUNT(*) FILTER (WHERE quantity > 0 AND quantity <= reorder_point) AS low_stock
+		FROM products
+		WHERE org_id = ?
+		GROUP BY GROUPING SETS ((type), ())
+		ORDER BY is_total DESC, stock_value DESC`, orgID).Scan(&rows).Error
+	if err != nil {
+		logger.Log.Error("Package controllers File "+file, zap.String("Function", "GetInventorySummary"), zap.String("Message", "Failed to aggregate products"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compute summary"})
+	}
 
+	summary := models.InventorySummary{
+		TopMovers:  []models.TopMover{},
+		Categories: []models.CategoryBreakdown{},
+	}
+	for _, row := range rows {
+		if row.IsTotal {
+			summary.TotalSKUs = row.SKUs
+			summary.TotalUnits = row.Units
+			summary.TotalStockValue = row.StockValue
+			summary.OutOfStock = row.OutOfStock
+			summary.LowStock = row.LowStock
+			continue
+		}
+		summary.Categories = append(summary.Categories, models.CategoryBreakdown{
+			Category:   row.Category,
+			SKUs:       row.SKUs,
+			Units:      row.Units,
+			StockValue: row.StockValue,
+		})
+	}
 
+	err = database.DB.Raw(`
+		SELECT p.id AS product_id, p.name, p.sku, SUM(ABS(m.quantity)) AS units_moved
+		FROM stock_movements m
+		JOIN products p ON p.id = m.product_id
+		WHERE p.org_id = ? AND m.type <> ? AND m.created_at >= date_trunc('week', now())
+		GROUP BY p.id, p.name, p.sku
+		ORDER BY units_moved DESC
+		LIMIT ?`, orgID, models.MovementInitial, top).Scan(&summary.TopMovers).Error
+	if err != nil {
+		logger.Log.Error("Package controllers File "+file, zap.String("Function", "GetInventorySummary"), zap.String("Message", "Failed to rank top movers"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compute summary"})
+	}
+
+	return c.JSON(summary)
+}
@@ -0,0 +1,48 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestInventorySummary(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+
+	shirt := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Type: "Clothing", Quantity: 10, Price: 2, ReorderPoint: 3})
+	testdb.CreateProduct(t, app, bearer, models.Product{Name: "Socks", SKU: "SO-1", Type: "Clothing", Quantity: 2, Price: 5, ReorderPoint: 5})
+	testdb.CreateProduct(t, app, bearer, models.Product{Name: "Kite", SKU: "KI-1", Type: "Toys", Quantity: 0, Price: 1})
+	if status := testdb.Call(t, app, fiber.MethodPut, "/products/"+shirt.String()+"/quantity", bearer, models.QuantityUpdateRequest{Quantity: 4}, nil); status != fiber.StatusOK {
+		t.Fatalf("update quantity: status %d", status)
+	}
+
+	var summary models.InventorySummary
+	if status := testdb.Call(t, app, fiber.MethodGet, "/analytics/summary", bearer, nil, &summary); status != fiber.StatusOK {
+		t.Fatalf("summary: status %d", status)
+	}
+	if summary.TotalSKUs != 3 || summary.TotalUnits != 6 || summary.TotalStockValue != 18 {
+		t.Errorf("totals = %d SKUs, %d units, value %v; want 3, 6, 18", summary.TotalSKUs, summary.TotalUnits, summary.TotalStockValue)
+	}
+	if summary.OutOfStock != 1 || summary.LowStock != 1 {
+		t.Errorf("out of stock %d, low stock %d; want 1 and 1", summary.OutOfStock, summary.LowStock)
+	}
+	want := []models.CategoryBreakdown{
+		{Category: "Clothing", SKUs: 2, Units: 6, StockValue: 18},
+		{Category: "Toys", SKUs: 1, Units: 0, StockValue: 0},
+	}
+	if len(summary.Categories) != len(want) {
+		t.Fatalf("categories = %+v, want %+v", summary.Categories, want)
+	}
+	for i := range want {
+		if summary.Categories[i] != want[i] {
+			t.Errorf("categories[%d] = %+v, want %+v", i, summary.Categories[i], want[i])
+		}
+	}
+	// Initial stock is not movement; the adjustment of 6 is.
+	if len(summary.TopMovers) != 1 || summary.TopMovers[0].ProductID != shirt || summary.TopMovers[0].UnitsMoved != 6 {
+		t.Errorf("top movers = %+v, want only the shirt with 6 units", summary.TopMovers)
+	}
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const apiKeyFile = "APIKeyController"
+
+// validateAPIKeyRequest normalises input and explains what is wrong with it,
+// or returns "" if it is acceptable. Keys can only be given permissions the
+// caller has.
+func validateAPIKeyRequest(c *fiber.Ctx, input *models.APIKeyRequest) string {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return "name is required"
+	}
+	if len(input.Scopes) == 0 {
+		return "at least one scope is required"
+	}
+	seen := map[string]bool{}
+	scopes := input.Scopes[:0]
+	for _, scope := range input.Scopes {
+		if !utils.HasPermission(c, scope) {
+			return "cannot grant scope " + scope + ": you do not have it"
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	input.Scopes = scopes
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return "expires_at must be in the future"
+	}
+	return ""
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Creates an API key that acts as the caller in the active organization, for scripts and integrations. Scopes are permission names and must be ones the caller has; the key never grants more than its owner's current role. Send it as "X-API-Key: <key>" or "Authorization: Bearer <key>". The key is shown only in this response. Each key has its own rate limit bucket.
+// @Tags         API keys
+// @Accept       json
+// @Produce      json
+// @Param        key  body      models.APIKeyRequest  true  "Name, scopes and optional expiry"
+// @Success      201  {object}  models.CreatedAPIKey
+// @Failure      400  {object}  map[string]string  "Invalid input"
+// @Failure      403  {object}  map[string]string  "Called with an API key, or a second factor is required"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /api-keys [post]
+func CreateAPIKey(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	if required, _ := c.Locals("mfaRequired").(bool); required {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Organization requires two-factor authentication; set it up at /2fa/enroll and sign in again"})
+	}
+	var input models.APIKeyRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if msg := validateAPIKeyRequest(c, &input); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	key, prefix, err := utils.NewAPIKey()
+	if err != nil {
+		logger.Log.Error("Package controllers File "+apiKeyFile, zap.String("Function", "CreateAPIKey"), zap.String("Message", "Failed to generate key"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	apiKey := models.APIKey{
+		UserID:    userID,
+		OrgID:     orgID,
+		Name:      input.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashAPIKey(key),
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{Action: "api_key.create", EntityType: "api_key", EntityID: apiKey.ID.String(), After: apiKey})
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+apiKeyFile, zap.String("Function", "CreateAPIKey"), zap.String("Message", "Database error while creating API key"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving API key"})
+	}
+
+	logger.Log.Info("Package controllers File "+apiKeyFile, zap.String("Function", "CreateAPIKey"), zap.String("Message", "API key created"), zap.String("prefix", prefix), zap.String("user_id", userID.String()), zap.String("org_id", orgID.String()))
+	return c.Status(fiber.StatusCreated).JSON(models.CreatedAPIKey{APIKey: apiKey, Key: key})
+}
+
+// GetAPIKeys godoc
+// @Summary      List API keys
+// @Description  Lists the caller's API keys in the active organization, newest first, including revoked and expired ones. With all=true, callers with users:manage see every member's keys.
+// @Tags         API keys
+// @Produce      json
+// @Param        all      query     bool  false  "Every member's keys (needs users:manage)"
+// @Param        pagenum  query     int   false  "Page number (default: 1)"
+// @Param        limit    query     int   false  "Items per page (default: 10)"
+// @Success      200      {array}   models.APIKey
+// @Failure      403      {object}  map[string]string  "Missing permission users:manage"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /api-keys [get]
+func GetAPIKeys(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	query := database.DB.Where("org_id = ?", orgID)
+	if c.QueryBool("all") {
+		if !utils.HasPermission(c, models.PermUsersManage) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Missing permission " + models.PermUsersManage})
+		}
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	keys := []models.APIKey{}
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&keys).Error; err != nil {
+		logger.Log.Error("Package controllers File "+apiKeyFile, zap.String("Function", "GetAPIKeys"), zap.String("Message", "Error retrieving API keys"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving API keys"})
+	}
+	return c.JSON(keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Revokes one of the caller's API keys in the active organization; it stops working immediately. Callers with users:manage can revoke any member's key.
+// @Tags         API keys
+// @Produce      json
+// @Param        id   path      string  true  "API key ID (UUID)"
+// @Success      200  {object}  models.APIKey
+// @Failure      400  {object}  map[string]string  "Invalid API key ID"
+// @Failure      404  {object}  map[string]string  "API key not found"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /api-keys/{id} [delete]
+func RevokeAPIKey(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	keyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid API key ID format"})
+	}
+
+	var apiKey models.APIKey
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND org_id = ?", keyID, orgID)
+		if !utils.HasPermission(c, models.PermUsersManage) {
+			query = query.Where("user_id = ?", userID)
+		}
+		if err := query.First(&apiKey).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "API key not found")
+		}
+		if apiKey.RevokedAt != nil {
+			return nil
+		}
+		before := apiKey
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := tx.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{Action: "api_key.revoke", EntityType: "api_key", EntityID: apiKey.ID.String(), Before: before, After: apiKey})
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+apiKeyFile, zap.String("Function", "RevokeAPIKey"), zap.String("Message", "Failed to revoke API key"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+apiKeyFile, zap.String("Function", "RevokeAPIKey"), zap.String("Message", "API key revoked"), zap.String("prefix", apiKey.Prefix), zap.String("by", userID.String()))
+	return c.JSON(apiKey)
+}
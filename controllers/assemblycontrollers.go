@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const assemblyFile = "AssemblyController"
+
+// findOrgAssemblyOrder loads an assembly order by the :id path parameter, scoped to the caller's organization.
+func findOrgAssemblyOrder(db *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.AssemblyOrder, error) {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid assembly order ID format")
+	}
+	var order models.AssemblyOrder
+	if err := db.First(&order, "id = ? AND org_id = ?", orderID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Assembly order not found")
+	}
+	return &order, nil
+}
+
+// assemblyStep runs step against the locked assembly order at :id inside a
+// transaction, then saves it in status.
+func assemblyStep(c *fiber.Ctx, function, status string, step func(tx *gorm.DB, order *models.AssemblyOrder, userID uuid.UUID) error) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var order *models.AssemblyOrder
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if order, err = findOrgAssemblyOrder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if !order.CanTransitionTo(status) {
+			return fiber.NewError(fiber.StatusConflict, "Cannot move assembly order from "+order.Status+" to "+status)
+		}
+		if step != nil {
+			if err := step(tx, order, userID); err != nil {
+				return err
+			}
+		}
+		order.Status = status
+		return tx.Save(order).Error
+	})
+	if err != nil {
+		if errors.Is(err, errInsufficientStock) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Insufficient component stock"})
+		}
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+assemblyFile, zap.String("Function", function), zap.String("Message", "Failed to update assembly order"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+assemblyFile, zap.String("Function", function), zap.String("Message", "Assembly order status changed"), zap.String("assembly_order_id", order.ID.String()), zap.String("status", status))
+	return c.JSON(order)
+}
+
+// CreateAssemblyOrder godoc
+// @Summary      Create an assembly order
+// @Description  Creates a draft order to build a number of kits of a bundle from its components
+// @Tags         Bundles
+// @Accept       json
+// @Produce      json
+// @Param        order  body      models.AssemblyOrderRequest  true  "Assembly order"
+// @Success      201    {object}  models.AssemblyOrder
+// @Failure      400    {object}  map[string]string  "Invalid input or product is not a bundle"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /assembly-orders [post]
+func CreateAssemblyOrder(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.AssemblyOrderRequest
+	if err := c.BodyParser(&input); err != nil || input.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A positive quantity is required"})
+	}
+	var bundle models.Product
+	if err := database.DB.First(&bundle, "id = ? AND org_id = ? AND is_bundle", input.BundleID, orgID).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Bundle not found"})
+	}
+
+	order := models.AssemblyOrder{
+		UserID:   userID,
+		OrgID:    orgID,
+		BundleID: bundle.ID,
+		Quantity: input.Quantity,
+		Status:   models.AssemblyStatusDraft,
+		Notes:    input.Notes,
+	}
+	if err := database.DB.Create(&order).Error; err != nil {
+		logger.Log.Error("Package controllers File "+assemblyFile, zap.String("Function", "CreateAssemblyOrder"), zap.String("Message", "Database error while creating assembly order"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving assembly order"})
+	}
+
+	logger.Log.Info("Package controllers File "+assemblyFile, zap.String("Function", "CreateAssemblyOrder"), zap.String("Message", "Assembly order created"), zap.String("assembly_order_id", order.ID.String()))
+	return c.Status(fiber.StatusCreated).JSON(order)
+}
+
+// GetAssemblyOrders godoc
+// @Summary      List assembly orders
+// @Description  Get paginated list of the authenticated user's assembly orders, optionally filtered by status
+// @Tags         Bundles
+// @Produce      json
+// @Param        status   query     string  false  "Filter by status"
+// @Param        pagenum  query     int     false  "Page number (default: 1)"
+// @Param        limit    query     int     false  "Items per page (default: 10)"
+// @Success      200      {array}   models.AssemblyOrder
+// @Failure      401      {object}  map[string]string  "Unauthorized"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /assembly-orders [get]
+func GetAssemblyOrders(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	query := database.DB.Where("org_id = ?", orgID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.AssemblyOrder
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&orders).Error; err != nil {
+		logger.Log.Error("Package controllers File "+assemblyFile, zap.String("Function", "GetAssemblyOrders"), zap.String("Message", "Error retrieving assembly orders"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving assembly orders"})
+	}
+	return c.JSON(orders)
+}
+
+// GetAssemblyOrder godoc
+// @Summary      Get an assembly order
+// @Description  Retrieves an assembly order
+// @Tags         Bundles
+// @Produce      json
+// @Param        id   path      string  true  "Assembly order ID (UUID)"
+// @Success      200  {object}  models.AssemblyOrder
+// @Failure      404  {object}  map[string]string  "Assembly order not found"
+// @Security     BearerAuth
+// @Router       /assembly-orders/{id} [get]
+func GetAssemblyOrder(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	order, err := findOrgAssemblyOrder(database.DB, c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(order)
+}
+
+// CompleteAssemblyOrder godoc
+// @Summary      Complete an assembly order
+// @Description  Builds the kits: consumes each component's unreserved stock and adds the kits to the bundle's quantity, with assembly movements on both sides in one transaction. Any bundle backorders are then filled from the new kits.
+// @Tags         Bundles
+// @Produce      json
+// @Param        id   path      string  true  "Assembly order ID (UUID)"
+// @Success      200  {object}  models.AssemblyOrder
+// @Failure      404  {object}  map[string]string  "Assembly order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition or insufficient component stock"
+// @Security     BearerAuth
+// @Router       /assembly-orders/{id}/complete [post]
+func CompleteAssemblyOrder(c *fiber.Ctx) error {
+	return assemblyStep(c, "CompleteAssemblyOrder", models.AssemblyStatusCompleted, func(tx *gorm.DB, order *models.AssemblyOrder, userID uuid.UUID) error {
+		components, err := bundleComponents(tx, order.BundleID)
+		if err != nil {
+			return err
+		}
+		if len(components) == 0 {
+			return fiber.NewError(fiber.StatusConflict, "Bundle has no components")
+		}
+		reference := "asm:" + order.ID.String()
+		for _, component := range components {
+			qty := order.Quantity * component.Quantity
+			product, err := lockProduct(tx, component.ComponentID)
+			if err != nil {
+				return err
+			}
+			// Stock reserved for sales orders is not available to build with.
+			avail, err := availableQuantity(tx, product)
+			if err != nil {
+				return err
+			}
+			if avail < qty {
+				return errInsufficientStock
+			}
+			if _, err := applyMovement(tx, &models.StockMovement{
+				ProductID: component.ComponentID,
+				UserID:    userID,
+				Type:      models.MovementAssembly,
+				Quantity:  -qty,
+				Reference: reference,
+			}); err != nil {
+				return err
+			}
+		}
+		if _, err := applyMovement(tx, &models.StockMovement{
+			ProductID: order.BundleID,
+			UserID:    userID,
+			Type:      models.MovementAssembly,
+			Quantity:  order.Quantity,
+			Reference: reference,
+		}); err != nil {
+			return err
+		}
+		now := time.Now()
+		order.CompletedAt = &now
+		return nil
+	})
+}
+
+// CancelAssemblyOrder godoc
+// @Summary      Cancel an assembly order
+// @Description  Cancels a draft assembly order without touching stock
+// @Tags         Bundles
+// @Produce      json
+// @Param        id   path      string  true  "Assembly order ID (UUID)"
+// @Success      200  {object}  models.AssemblyOrder
+// @Failure      404  {object}  map[string]string  "Assembly order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /assembly-orders/{id}/cancel [post]
+func CancelAssemblyOrder(c *fiber.Ctx) error {
+	return assemblyStep(c, "CancelAssemblyOrder", models.AssemblyStatusCancelled, nil)
+}
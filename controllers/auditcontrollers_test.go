@@ -0,0 +1,54 @@
+package controllers_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestAuditLog(t *testing.T) {
+	app := testdb.App(t)
+	user, bearer := testdb.SignUp(t, app)
+	id := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Lamp", SKU: "LMP-1", Price: 30, Quantity: 2})
+
+	var entries []models.AuditLog
+	path := "/audit?entity_type=product&entity_id=" + id.String()
+	if status := testdb.Call(t, app, fiber.MethodGet, path, bearer, nil, &entries); status != fiber.StatusOK {
+		t.Fatalf("list audit log: status %d", status)
+	}
+	if len(entries) != 1 || entries[0].Action != "product.create" || entries[0].ActorID == nil || *entries[0].ActorID != user.UserID || entries[0].RequestID == "" {
+		t.Fatalf("entries = %+v, want one product.create by %s with a request ID", entries, user.UserID)
+	}
+	if err := database.DB.Model(&models.AuditLog{}).Where("id = ?", entries[0].ID).Update("action", "product.delete").Error; err == nil {
+		t.Error("updating an audit entry: want an error")
+	}
+
+	var verification models.AuditVerification
+	if status := testdb.Call(t, app, fiber.MethodGet, "/audit/verify", bearer, nil, &verification); status != fiber.StatusOK {
+		t.Fatalf("verify: status %d", status)
+	}
+	if !verification.Valid || verification.Entries == 0 {
+		t.Errorf("verification = %+v, want a valid chain", verification)
+	}
+
+	_, otherBearer := testdb.SignUp(t, app)
+	entries = nil
+	if status := testdb.Call(t, app, fiber.MethodGet, path, otherBearer, nil, &entries); status != fiber.StatusOK || len(entries) != 0 {
+		t.Errorf("another organization's entries: status %d, %d entries, want 200 and none", status, len(entries))
+	}
+
+	req := httptest.NewRequest(fiber.MethodGet, "/audit/export?entity_id="+id.String(), nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+bearer)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get(fiber.HeaderXRequestID) == "" {
+		t.Errorf("export: status %d, X-Request-ID %q, want 200 with a request ID", resp.StatusCode, resp.Header.Get(fiber.HeaderXRequestID))
+	}
+}
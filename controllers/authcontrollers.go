@@ -1,7 +1,12 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"math"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/lokesh2201013/Logger"
@@ -9,67 +14,136 @@ import (
 	"github.com/lokesh2201013/models"
 	"github.com/lokesh2201013/utils"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{2,31}$`)
+
+// validateRegisterRequest normalises input and explains what is wrong with
+// it, or returns "" if it is acceptable.
+func validateRegisterRequest(input *models.RegisterRequest) string {
+	input.Username = strings.TrimSpace(input.Username)
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	switch {
+	case !usernamePattern.MatchString(input.Username):
+		return "username must be 3-32 letters, digits, '.', '_' or '-', starting with a letter or digit"
+	case !validEmail(input.Email):
+		return "email must be a valid email address"
+	}
+	local, _, _ := strings.Cut(input.Email, "@")
+	return utils.PasswordProblem(input.Password, input.Username, local)
+}
+
+// validEmail accepts a bare address (no display name) with a dotted domain.
+func validEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	return strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// registrationTaken reports whether the username or email is already in use,
+// ignoring case.
+func registrationTaken(db *gorm.DB, input *models.RegisterRequest) (bool, error) {
+	var count int64
+	err := db.Model(&models.User{}).
+		Where("LOWER(username) = LOWER(?) OR LOWER(email) = LOWER(?)", input.Username, input.Email).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// errRegistrationTaken does not say which of username or email clashed, so
+// registering cannot be used to discover whose email has an account.
+var errRegistrationTaken = fiber.NewError(fiber.StatusConflict, "Username or email already in use")
+
 // Register godoc
 // @Summary      Register a new user
-// @Description  Creates a new user in the system with a unique username and email. The password is securely hashed before storage.
+// @Description  Creates a new user with a unique username and email. Usernames are 3-32 letters, digits, '.', '_' or '-'. Passwords need at least 10 characters and 5 different ones, must not contain the username or email, and must not be on the breached-password list. The password is hashed before storage and never returned. A verification link is emailed; until it is followed the user can sign in but not use the inventory.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
-// @Param        user  body      models.User  true  "User registration payload"
-// @Success      201   {object}  models.User  "User successfully registered"
-// @Failure      400   {object}  map[string]string  "Bad Request – Invalid JSON or missing fields"
-// @Failure      409   {object}  map[string]string  "Conflict – Username or email already exists"
+// @Param        user  body      models.RegisterRequest  true  "User registration payload"
+// @Success      201   {object}  models.UserResponse  "User successfully registered"
+// @Failure      400   {object}  map[string]string  "Bad Request – Invalid JSON, or a field failed validation"
+// @Failure      409   {object}  map[string]string  "Conflict – Username or email already in use"
 // @Failure      500   {object}  map[string]string  "Internal server error"
 // @Router       /register [post]
 func Register(c *fiber.Ctx)error{
 
-	var user models.User
-	if err:= c.BodyParser(&user); err!=nil{
+	var input models.RegisterRequest
+	if err:= c.BodyParser(&input); err!=nil{
 	  logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to decrypt request body"))
       return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error":"Invalid input"})
 	}
-    
-	var existingUser models.User
-
-	if err:=database.DB.Where("username=?",user.Username).First(&existingUser).Error;err==nil{
-		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Similar Name"))
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email already in use"})
+	if msg := validateRegisterRequest(&input); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
 	}
 
-	/*if err:=database.DB.Where("email=?",user.Email).First(&existingUser).Error;err==nil{
-			  logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Similar Emails"))
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email already in use"})
-	}*/
+	if taken, err := registrationTaken(database.DB, &input); err != nil {
+		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to check for existing user"))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	} else if taken {
+		return sendError(c, errRegistrationTaken)
+	}
 
-	hashpassword, err:=utils.HashPassword(user.Password)
+	hashpassword, err:=utils.HashPassword(input.Password)
 
 	if err != nil {
 			  logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to Hash request body password"))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
-	user.Password = hashpassword
-
-	if err:=database.DB.Create(&user).Error;err!=nil{
-			  logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to Create database input"))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	user := models.User{Username: input.Username, Email: input.Email, Password: hashpassword}
+
+	// Every user starts with a personal organization of their own, and must
+	// verify their email address before using it.
+	var verifyToken string
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			// A concurrent registration may have taken the name since the check.
+			if taken, checkErr := registrationTaken(database.DB, &input); checkErr == nil && taken {
+				return errRegistrationTaken
+			}
+			return err
+		}
+		if _, err := createPersonalOrg(tx, &user); err != nil {
+			return err
+		}
+		if err := auditUser(tx, "user.create", nil, &user, nil, &user.UserID); err != nil {
+			return err
+		}
+		var err error
+		verifyToken, err = issueAccountToken(tx, user.UserID, models.TokenPurposeEmailVerification, emailVerificationTTL)
+		return err
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to Create database input"))
+		}
+		return sendError(c, err)
 	}
+	deliver("Register", verificationEmail(&user, verifyToken))
 
-	return c.Status(fiber.StatusCreated).JSON(user)
+	return c.Status(fiber.StatusCreated).JSON(user.Response())
 }
 
 // Login godoc
 // @Summary      User login
-// @Description  Authenticates a registered user using username and password. Returns a JWT token upon success.
+// @Description  Authenticates a registered user using username and password. Starts a session and returns a short-lived JWT access token and a refresh token for renewing it. Users with two-factor authentication instead get a challenge token to complete at /login/2fa. Repeated failures for a username or from an IP make further attempts wait, with a growing delay and then a temporary lockout.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Param        login  body      models.LoginRequest  true  "Login credentials"
-// @Success      200    {object}  map[string]string     "Authentication successful – JWT access token returned"
+// @Success      200    {object}  models.TokenResponse  "Authentication successful – access and refresh tokens returned"
+// @Success      202    {object}  models.MFAChallenge   "Password accepted – second factor required"
 // @Failure      400    {object}  map[string]string     "Bad Request – Invalid JSON or missing fields"
-// @Failure      401    {object}  map[string]string     "Unauthorized – Incorrect password"
-// @Failure      404    {object}  map[string]string     "Not Found – User does not exist"
+// @Failure      401    {object}  map[string]string     "Unauthorized – Unknown username or incorrect password"
+// @Failure      429    {object}  map[string]string     "Too many failed attempts for the account or IP; see Retry-After"
 // @Failure      500    {object}  map[string]string     "Internal server error"
 // @Router       /login [post]
 func Login(c *fiber.Ctx)error{
@@ -82,28 +156,63 @@ func Login(c *fiber.Ctx)error{
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error":"invald input"})
 	}
 
+	// Accounts and IPs with recent failures must wait before trying again.
+	// This sits behind the per-IP token bucket, which limits all requests.
+	wait, err := loginRetryAfter(database.DB, loginData.Name, c.IP())
+	if err != nil {
+		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to check login throttle"))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return sendError(c, errLoginThrottled)
+	}
+
 	var user models.User
 
 	if err:= database.DB.Where("username=?",loginData.Name).First(&user).Error;err!=nil{
-		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to Create database input"))
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error":"User not found"})
+		spendPasswordCheck(loginData.Password)
+		return failLogin(c, loginData.Name)
 	}
 
+	// Users created by single sign-on have no password.
+	if user.Password == "" {
+		spendPasswordCheck(loginData.Password)
+		return failLogin(c, loginData.Name)
+	}
 	if err := utils.CheckPassword(user.Password, loginData.Password); err != nil {
-		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to Create database input"))
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+		return failLogin(c, loginData.Name)
+	}
+	challenge, err := startLoginChallenge(&user)
+	if err != nil {
+		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to start login challenge"))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	if challenge != nil {
+		return c.Status(fiber.StatusAccepted).JSON(challenge)
 	}
 
-	token, err := utils.GenerateJWT(user.UserID, user.Username)
-	fmt.Println("<<<<<<<<<<<<<<<<<<<<<<>TOKEN",token)
-
+	tokens, err := startSession(c, &user, false)
 	if err != nil {
-		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to Create database input"))
+		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to start session"))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
+	// Only a session, not a correct password alone, ends the throttle; with
+	// two-factor authentication LoginMFA clears it.
+	if err := clearAccountThrottle(database.DB, user.Username); err != nil {
+		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to clear login throttle"))
+	}
     
-	logger.Log.Info(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "token created"))
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"access_token":   token,
-	}) 
-}
\ No newline at end of file
+	logger.Log.Info(" Package controllers File Authcontroller", zap.String("Message", "token created"))
+	return c.Status(fiber.StatusOK).JSON(tokens)
+}
+
+// failLogin counts a failed login for username from the caller's IP and gives
+// the same answer whether or not the account exists.
+func failLogin(c *fiber.Ctx, username string) error {
+	if err := recordLoginFailure(database.DB, username, c.IP()); err != nil {
+		logger.Log.Error(" Package controllers File Authcontroller", zap.Error(err), zap.String("Message", "Failed to record failed login"))
+	}
+	logger.Log.Warn(" Package controllers File Authcontroller", zap.String("Message", "Failed login"), zap.String("username", username), zap.String("ip", c.IP()))
+	return sendError(c, errInvalidLogin)
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lokesh2201013/models"
+)
+
+func TestValidEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"john@example.com", true},
+		{"john.doe+stock@mail.example.co.uk", true},
+		{"john@localhost", false},
+		{"john@.example.com", false},
+		{"john@example.com.", false},
+		{"john.example.com", false},
+		{"@example.com", false},
+		{"John <john@example.com>", false},
+		{" john@example.com", false},
+		{"", false},
+		{strings.Repeat("a", 64) + "@" + strings.Repeat("b", 186) + ".com", false},
+	}
+	for _, tt := range tests {
+		if got := validEmail(tt.email); got != tt.want {
+			t.Errorf("validEmail(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRegisterRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     models.RegisterRequest
+		want      string
+		wantEmail string
+	}{
+		{"valid", models.RegisterRequest{Username: "john_doe", Email: "john@example.com", Password: "correct horse battery"}, "", "john@example.com"},
+		{"trims and lower-cases email", models.RegisterRequest{Username: " john_doe ", Email: " John@Example.COM ", Password: "correct horse battery"}, "", "john@example.com"},
+		{"username too short", models.RegisterRequest{Username: "jd", Email: "john@example.com", Password: "correct horse battery"}, "username must be 3-32 letters, digits, '.', '_' or '-', starting with a letter or digit", ""},
+		{"username starts with punctuation", models.RegisterRequest{Username: "_john", Email: "john@example.com", Password: "correct horse battery"}, "username must be 3-32 letters, digits, '.', '_' or '-', starting with a letter or digit", ""},
+		{"username with spaces", models.RegisterRequest{Username: "john doe", Email: "john@example.com", Password: "correct horse battery"}, "username must be 3-32 letters, digits, '.', '_' or '-', starting with a letter or digit", ""},
+		{"invalid email", models.RegisterRequest{Username: "john_doe", Email: "john@localhost", Password: "correct horse battery"}, "email must be a valid email address", ""},
+		{"password contains username", models.RegisterRequest{Username: "john_doe", Email: "jd@example.com", Password: "JOHN_DOE is great"}, "password must not contain your username or email", ""},
+		{"password contains email local part", models.RegisterRequest{Username: "jdoe", Email: "mark.smith@example.com", Password: "mark.smith rocks"}, "password must not contain your username or email", ""},
+		{"weak password", models.RegisterRequest{Username: "john_doe", Email: "john@example.com", Password: "short"}, "password must be at least 10 characters", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			if got := validateRegisterRequest(&input); got != tt.want {
+				t.Errorf("validateRegisterRequest = %q, want %q", got, tt.want)
+			}
+			if tt.wantEmail != "" && input.Email != tt.wantEmail {
+				t.Errorf("email normalized to %q, want %q", input.Email, tt.wantEmail)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const backorderFile = "BackorderController"
+
+// allocateBackorders hands the product's unreserved stock to confirmed order
+// lines waiting on it, highest priority first and then oldest confirmation
+// first. Orders left with no backorders start their reservation window. The
+// product must already be locked by the caller's transaction.
+func allocateBackorders(tx *gorm.DB, product *models.Product) error {
+	avail, err := reservableQuantity(tx, product)
+	if err != nil || avail <= 0 {
+		return err
+	}
+
+	var lines []models.SalesOrderLine
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "sales_order_lines"}}).
+		Joins("JOIN sales_orders o ON o.id = sales_order_lines.sales_order_id").
+		Where("sales_order_lines.product_id = ? AND sales_order_lines.backordered_quantity > 0 AND o.status = ?", product.ID, models.SOStatusConfirmed).
+		Order("o.priority DESC, o.confirmed_at ASC, sales_order_lines.id").
+		Find(&lines).Error; err != nil {
+		return err
+	}
+
+	allocated := 0
+	touched := make(map[uuid.UUID]bool)
+	for i := range lines {
+		if avail == 0 {
+			break
+		}
+		line := &lines[i]
+		take := min(avail, line.BackorderedQuantity)
+		line.ReservedQuantity += take
+		line.BackorderedQuantity -= take
+		if err := tx.Model(line).Updates(map[string]interface{}{
+			"reserved_quantity":    line.ReservedQuantity,
+			"backordered_quantity": line.BackorderedQuantity,
+		}).Error; err != nil {
+			return err
+		}
+		avail -= take
+		allocated += take
+		touched[line.SalesOrderID] = true
+	}
+	if allocated == 0 {
+		return nil
+	}
+
+	if err := holdStock(tx, product, allocated); err != nil {
+		return err
+	}
+
+	for orderID := range touched {
+		var waiting int64
+		if err := tx.Model(&models.SalesOrderLine{}).
+			Where("sales_order_id = ? AND backordered_quantity > 0", orderID).
+			Count(&waiting).Error; err != nil {
+			return err
+		}
+		if waiting > 0 {
+			continue
+		}
+		if err := tx.Model(&models.SalesOrder{}).Where("id = ?", orderID).
+			Update("reservation_expires_at", time.Now().Add(reservationTTL())).Error; err != nil {
+			return err
+		}
+		logger.Log.Info("Package controllers File "+backorderFile, zap.String("Function", "allocateBackorders"), zap.String("Message", "Backorders filled"), zap.String("sales_order_id", orderID.String()))
+	}
+	return nil
+}
+
+// GetBackorders godoc
+// @Summary      List outstanding backorders
+// @Description  Returns, per product, the quantity confirmed sales orders are still waiting for, how many orders are waiting and since when
+// @Tags         Sales
+// @Produce      json
+// @Success      200  {array}   models.BackorderSummary
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /backorders [get]
+func GetBackorders(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	backorders := []models.BackorderSummary{}
+	err = database.DB.Raw(`
+		SELECT p.id AS product_id, p.name, p.sku,
+		       SUM(l.backordered_quantity) AS outstanding,
+		       COUNT(DISTINCT o.id) AS orders,
+		       MIN(o.confirmed_at) AS oldest_waiting
+		FROM sales_order_lines l
+		JOIN sales_orders o ON o.id = l.sales_order_id
+		JOIN products p ON p.id = l.product_id
+		WHERE o.org_id = ? AND o.status = ? AND l.backordered_quantity > 0
+		GROUP BY p.id, p.name, p.sku
+		ORDER BY outstanding DESC`, orgID, models.SOStatusConfirmed).Scan(&backorders).Error
+	if err != nil {
+		logger.Log.Error("Package controllers File "+backorderFile, zap.String("Function", "GetBackorders"), zap.String("Message", "Error retrieving backorders"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving backorders"})
+	}
+	return c.JSON(backorders)
+}
@@ -0,0 +1,68 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestAllocateBackorders(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Kite", SKU: "KI-1", Price: 4})
+
+	confirm := func(priority int) models.SalesOrder {
+		t.Helper()
+		var order models.SalesOrder
+		body := models.SalesOrderRequest{Customer: "Jane Doe", Priority: priority, Lines: []models.SalesOrderLineRequest{{ProductID: product, Quantity: 3}}}
+		if status := testdb.Call(t, app, fiber.MethodPost, "/sales-orders", bearer, body, &order); status != fiber.StatusCreated {
+			t.Fatalf("create sales order: status %d", status)
+		}
+		if status := testdb.Call(t, app, fiber.MethodPost, "/sales-orders/"+order.ID.String()+"/confirm", bearer, nil, &order); status != fiber.StatusOK {
+			t.Fatalf("confirm: status %d", status)
+		}
+		return order
+	}
+	older := confirm(0)
+	urgent := confirm(5)
+	if older.Lines[0].BackorderedQuantity != 3 || older.ReservationExpiresAt != nil {
+		t.Errorf("older order: %d backordered, expires %v; want 3 and no expiry", older.Lines[0].BackorderedQuantity, older.ReservationExpiresAt)
+	}
+
+	var backorders []models.BackorderSummary
+	if status := testdb.Call(t, app, fiber.MethodGet, "/backorders", bearer, nil, &backorders); status != fiber.StatusOK {
+		t.Fatalf("list backorders: status %d", status)
+	}
+	if len(backorders) != 1 || backorders[0].Outstanding != 6 || backorders[0].Orders != 2 {
+		t.Fatalf("backorders = %+v, want 6 across 2 orders", backorders)
+	}
+
+	// Four arrive: the higher priority order is filled first despite being newer.
+	path := "/products/" + product.String() + "/quantity"
+	if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, models.QuantityUpdateRequest{Quantity: 4}, nil); status != fiber.StatusOK {
+		t.Fatalf("update quantity: status %d", status)
+	}
+	for _, want := range []struct {
+		order                 models.SalesOrder
+		reserved, backordered int
+		expires               bool
+	}{
+		{urgent, 3, 0, true},
+		{older, 1, 2, false},
+	} {
+		var order models.SalesOrder
+		if status := testdb.Call(t, app, fiber.MethodGet, "/sales-orders/"+want.order.ID.String(), bearer, nil, &order); status != fiber.StatusOK {
+			t.Fatalf("get sales order: status %d", status)
+		}
+		line := order.Lines[0]
+		if line.ReservedQuantity != want.reserved || line.BackorderedQuantity != want.backordered || (order.ReservationExpiresAt != nil) != want.expires {
+			t.Errorf("priority %d order: reserved %d, backordered %d, expires %v; want %d, %d and expiring %v",
+				order.Priority, line.ReservedQuantity, line.BackorderedQuantity, order.ReservationExpiresAt, want.reserved, want.backordered, want.expires)
+		}
+	}
+	if _, reserved := stockLevels(t, product); reserved != 4 {
+		t.Errorf("reserved = %d, want all 4 arrivals", reserved)
+	}
+}
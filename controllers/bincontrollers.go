@@ -0,0 +1,503 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const binFile = "BinController"
+
+// binPathOrder sorts bins along the picking walk.
+const binPathOrder = "bins.sequence, bins.aisle, bins.rack, bins.shelf, bins.code"
+
+// findOrgBin loads a bin by the :id path parameter, scoped to the caller's organization.
+func findOrgBin(db *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.Bin, error) {
+	binID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid bin ID format")
+	}
+	var bin models.Bin
+	if err := db.First(&bin, "id = ? AND org_id = ?", binID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Bin not found")
+	}
+	return &bin, nil
+}
+
+// suggestPutaway picks a bin for qty units of a product: the first bin along
+// the walk that already holds the product and has room, otherwise the first
+// empty bin with room.
+func suggestPutaway(db *gorm.DB, orgID, productID uuid.UUID, qty int) (models.PutawaySuggestion, error) {
+	suggestion := models.PutawaySuggestion{ProductID: productID, Quantity: qty}
+	used := "(SELECT COALESCE(SUM(quantity), 0) FROM bin_stocks s WHERE s.bin_id = bins.id)"
+	room := "(bins.capacity = 0 OR bins.capacity - " + used + " >= ?)"
+
+	var bin models.Bin
+	err := db.Joins("JOIN bin_stocks held ON held.bin_id = bins.id AND held.product_id = ? AND held.quantity > 0", productID).
+		Where("bins.org_id = ? AND "+room, orgID, qty).
+		Order(binPathOrder).First(&bin).Error
+	if err == nil {
+		suggestion.BinID, suggestion.Code, suggestion.Reason = &bin.ID, bin.Code, "Bin already holds this product"
+		return suggestion, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return suggestion, err
+	}
+
+	err = db.Where("bins.org_id = ? AND "+used+" = 0 AND "+room, orgID, qty).
+		Order(binPathOrder).First(&bin).Error
+	switch {
+	case err == nil:
+		suggestion.BinID, suggestion.Code, suggestion.Reason = &bin.ID, bin.Code, "Empty bin with room"
+	case err == gorm.ErrRecordNotFound:
+		suggestion.Reason = "No bin has room"
+	default:
+		return suggestion, err
+	}
+	return suggestion, nil
+}
+
+// setBinQuantity sets the units of a product in a locked bin, enforcing the
+// bin's capacity and that no more is binned than the product has on hand.
+func setBinQuantity(tx *gorm.DB, bin *models.Bin, productID uuid.UUID, qty int) (*models.BinStock, error) {
+	product, err := lockProduct(tx, productID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Product not found")
+	}
+	var stock models.BinStock
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("bin_id = ? AND product_id = ?", bin.ID, productID).First(&stock).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		stock = models.BinStock{BinID: bin.ID, ProductID: productID}
+	case err != nil:
+		return nil, err
+	}
+
+	if bin.Capacity > 0 {
+		var others int
+		if err := tx.Model(&models.BinStock{}).Where("bin_id = ? AND product_id <> ?", bin.ID, productID).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&others).Error; err != nil {
+			return nil, err
+		}
+		if others+qty > bin.Capacity {
+			return nil, fiber.NewError(fiber.StatusConflict, "Bin "+bin.Code+" does not have room")
+		}
+	}
+	var binnedElsewhere int
+	if err := tx.Model(&models.BinStock{}).Where("product_id = ? AND bin_id <> ?", productID, bin.ID).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&binnedElsewhere).Error; err != nil {
+		return nil, err
+	}
+	if binnedElsewhere+qty > product.Quantity {
+		return nil, fiber.NewError(fiber.StatusConflict, "More units would be binned than "+product.SKU+" has on hand")
+	}
+
+	before := stock.Quantity
+	stock.Quantity = qty
+	if err := tx.Save(&stock).Error; err != nil {
+		return nil, err
+	}
+	return &stock, auditBinStock(tx, "stock.bin", bin.OrgID, productID, bin.Code, before, qty)
+}
+
+// trimBins takes units of a product out of its bins, in walking order, until
+// no more is binned than it has on hand. Stock that leaves other than through
+// a pick list, such as a shipment picked by hand or an adjustment, is thereby
+// not left behind in the bins. The product must be locked by tx.
+func trimBins(tx *gorm.DB, product *models.Product) error {
+	var slots []binSlot
+	if err := tx.Table("bins").Select("bins.*, s.quantity").
+		Joins("JOIN bin_stocks s ON s.bin_id = bins.id").
+		Where("s.product_id = ? AND s.quantity > 0", product.ID).
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "s"}}).
+		Order(binPathOrder).Scan(&slots).Error; err != nil {
+		return err
+	}
+	excess := -product.Quantity
+	for _, slot := range slots {
+		excess += slot.Quantity
+	}
+	for _, slot := range slots {
+		if excess <= 0 {
+			break
+		}
+		take := min(excess, slot.Quantity)
+		if err := tx.Model(&models.BinStock{}).Where("bin_id = ? AND product_id = ?", slot.ID, product.ID).
+			Update("quantity", slot.Quantity-take).Error; err != nil {
+			return err
+		}
+		if err := auditBinStock(tx, "stock.bin", product.OrgID, product.ID, slot.Code, slot.Quantity, slot.Quantity-take); err != nil {
+			return err
+		}
+		excess -= take
+	}
+	return nil
+}
+
+// auditBinStock records a change in how many units of a product a bin holds.
+func auditBinStock(tx *gorm.DB, action string, orgID, productID uuid.UUID, binCode string, before, after int) error {
+	if before == after {
+		return nil
+	}
+	return audit.Record(tx, audit.Entry{
+		OrgID:      &orgID,
+		Action:     action,
+		EntityType: "stock",
+		EntityID:   productID.String(),
+		Changes:    map[string]models.AuditChange{"bins." + binCode: {Before: before, After: after}},
+	})
+}
+
+// CreateBin godoc
+// @Summary      Create a bin
+// @Description  Adds a bin location (aisle/rack/shelf). Sequence sets the bin's place on the picking walk; capacity of zero means unlimited.
+// @Tags         Bins
+// @Accept       json
+// @Produce      json
+// @Param        bin  body      models.BinRequest  true  "Bin"
+// @Success      201  {object}  models.Bin
+// @Failure      400  {object}  map[string]string  "Invalid input"
+// @Failure      409  {object}  map[string]string  "Bin code already exists"
+// @Security     BearerAuth
+// @Router       /bins [post]
+func CreateBin(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.BinRequest
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Code) == "" || input.Capacity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code is required and capacity cannot be negative"})
+	}
+
+	bin := models.Bin{
+		UserID:   userID,
+		OrgID:    orgID,
+		Code:     strings.TrimSpace(input.Code),
+		Aisle:    input.Aisle,
+		Rack:     input.Rack,
+		Shelf:    input.Shelf,
+		Sequence: input.Sequence,
+		Capacity: input.Capacity,
+	}
+	var count int64
+	database.DB.Model(&models.Bin{}).Where("org_id = ? AND code = ?", orgID, bin.Code).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Bin code already exists"})
+	}
+	if err := database.DB.Create(&bin).Error; err != nil {
+		logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "CreateBin"), zap.String("Message", "Database error while creating bin"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving bin"})
+	}
+
+	logger.Log.Info("Package controllers File "+binFile, zap.String("Function", "CreateBin"), zap.String("Message", "Bin created"), zap.String("bin_id", bin.ID.String()))
+	return c.Status(fiber.StatusCreated).JSON(bin)
+}
+
+// GetBins godoc
+// @Summary      List bins
+// @Description  Get paginated list of bins in walking order with their contents
+// @Tags         Bins
+// @Produce      json
+// @Param        pagenum  query     int  false  "Page number (default: 1)"
+// @Param        limit    query     int  false  "Items per page (default: 50)"
+// @Success      200      {array}   models.Bin
+// @Failure      401      {object}  map[string]string  "Unauthorized"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /bins [get]
+func GetBins(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 50)
+	offset := (pageNumber - 1) * limit
+
+	var bins []models.Bin
+	if err := database.DB.Preload("Stock", "quantity > 0").Where("org_id = ?", orgID).
+		Order(binPathOrder).Limit(limit).Offset(offset).Find(&bins).Error; err != nil {
+		logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "GetBins"), zap.String("Message", "Error retrieving bins"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving bins"})
+	}
+	return c.JSON(bins)
+}
+
+// GetBin godoc
+// @Summary      Get a bin
+// @Description  Retrieves a bin with the products it holds
+// @Tags         Bins
+// @Produce      json
+// @Param        id   path      string  true  "Bin ID (UUID)"
+// @Success      200  {object}  models.Bin
+// @Failure      404  {object}  map[string]string  "Bin not found"
+// @Security     BearerAuth
+// @Router       /bins/{id} [get]
+func GetBin(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	bin, err := findOrgBin(database.DB.Preload("Stock", "quantity > 0"), c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(bin)
+}
+
+// UpdateBin godoc
+// @Summary      Update a bin
+// @Description  Updates a bin's position, walking sequence and capacity
+// @Tags         Bins
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string             true  "Bin ID (UUID)"
+// @Param        bin  body      models.BinRequest  true  "Bin"
+// @Success      200  {object}  models.Bin
+// @Failure      400  {object}  map[string]string  "Invalid input"
+// @Failure      404  {object}  map[string]string  "Bin not found"
+// @Security     BearerAuth
+// @Router       /bins/{id} [put]
+func UpdateBin(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var input models.BinRequest
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Code) == "" || input.Capacity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code is required and capacity cannot be negative"})
+	}
+	bin, err := findOrgBin(database.DB, c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	bin.Code = strings.TrimSpace(input.Code)
+	bin.Aisle, bin.Rack, bin.Shelf = input.Aisle, input.Rack, input.Shelf
+	bin.Sequence, bin.Capacity = input.Sequence, input.Capacity
+	if err := database.DB.Save(bin).Error; err != nil {
+		logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "UpdateBin"), zap.String("Message", "Failed to update bin"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update bin"})
+	}
+	return c.JSON(bin)
+}
+
+// DeleteBin godoc
+// @Summary      Delete a bin
+// @Description  Deletes an empty bin
+// @Tags         Bins
+// @Produce      json
+// @Param        id   path      string  true  "Bin ID (UUID)"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string  "Bin not found"
+// @Failure      409  {object}  map[string]string  "Bin is not empty"
+// @Security     BearerAuth
+// @Router       /bins/{id} [delete]
+func DeleteBin(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	bin, err := findOrgBin(database.DB, c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var held int64
+	database.DB.Model(&models.BinStock{}).Where("bin_id = ? AND quantity > 0", bin.ID).Count(&held)
+	if held > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Bin is not empty"})
+	}
+	if err := database.DB.Delete(bin).Error; err != nil {
+		logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "DeleteBin"), zap.String("Message", "Failed to delete bin"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete bin"})
+	}
+	return c.JSON(fiber.Map{"message": "Bin deleted"})
+}
+
+// GetProductBins godoc
+// @Summary      Where a product is shelved
+// @Description  Lists the bins holding a product in walking order
+// @Tags         Bins
+// @Produce      json
+// @Param        id   path      string  true  "Product ID (UUID)"
+// @Success      200  {array}   models.ProductBin
+// @Failure      404  {object}  map[string]string  "Product not found"
+// @Security     BearerAuth
+// @Router       /products/{id}/bins [get]
+func GetProductBins(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	bins := []models.ProductBin{}
+	if err := database.DB.Table("bins").
+		Select("bins.id AS bin_id, bins.code, bins.sequence, s.quantity").
+		Joins("JOIN bin_stocks s ON s.bin_id = bins.id").
+		Where("s.product_id = ? AND s.quantity > 0", product.ID).
+		Order(binPathOrder).Scan(&bins).Error; err != nil {
+		logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "GetProductBins"), zap.String("Message", "Error retrieving product bins"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving product bins"})
+	}
+	return c.JSON(bins)
+}
+
+// SuggestPutaway godoc
+// @Summary      Suggest a bin for putaway
+// @Description  Recommends where to shelve units of a product: a bin along the walk that already holds it and has room, otherwise the first empty bin with room
+// @Tags         Bins
+// @Produce      json
+// @Param        product_id  query     string  true   "Product ID (UUID)"
+// @Param        quantity    query     int     false  "Units to put away (default: 1)"
+// @Success      200         {object}  models.PutawaySuggestion
+// @Failure      404         {object}  map[string]string  "Product not found"
+// @Security     BearerAuth
+// @Router       /bins/putaway [get]
+func SuggestPutaway(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Query("product_id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	qty := c.QueryInt("quantity", 1)
+	if qty <= 0 {
+		qty = 1
+	}
+	suggestion, err := suggestPutaway(database.DB, orgID, product.ID, qty)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "SuggestPutaway"), zap.String("Message", "Failed to suggest a bin"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to suggest a bin"})
+	}
+	return c.JSON(suggestion)
+}
+
+// PutAway godoc
+// @Summary      Put stock away into a bin
+// @Description  Records units of a product placed in a bin. The bin's capacity is enforced and no more can be binned than the product has on hand.
+// @Tags         Bins
+// @Accept       json
+// @Produce      json
+// @Param        putaway  body      models.PutawayRequest  true  "Putaway"
+// @Success      200      {object}  models.BinStock
+// @Failure      400      {object}  map[string]string  "Invalid input"
+// @Failure      404      {object}  map[string]string  "Bin or product not found"
+// @Failure      409      {object}  map[string]string  "Bin full or more than on hand"
+// @Security     BearerAuth
+// @Router       /bins/putaway [post]
+func PutAway(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var input models.PutawayRequest
+	if err := c.BodyParser(&input); err != nil || input.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A positive quantity is required"})
+	}
+
+	var stock *models.BinStock
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var bin models.Bin
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bin, "id = ? AND org_id = ?", input.BinID, orgID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Bin not found")
+		}
+		var product models.Product
+		if err := tx.First(&product, "id = ? AND org_id = ?", input.ProductID, orgID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Product not found")
+		}
+		var current int
+		if err := tx.Model(&models.BinStock{}).Where("bin_id = ? AND product_id = ?", bin.ID, product.ID).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&current).Error; err != nil {
+			return err
+		}
+		var err error
+		stock, err = setBinQuantity(tx, &bin, product.ID, current+input.Quantity)
+		return err
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "PutAway"), zap.String("Message", "Failed to put stock away"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+binFile, zap.String("Function", "PutAway"), zap.String("Message", "Stock put away"), zap.String("bin_id", input.BinID.String()), zap.String("product_id", input.ProductID.String()), zap.Int("quantity", input.Quantity))
+	return c.JSON(stock)
+}
+
+// SetBinStock godoc
+// @Summary      Correct a bin's quantity
+// @Description  Sets how many units of a product are in a bin, e.g. after a recount or a move between bins
+// @Tags         Bins
+// @Accept       json
+// @Produce      json
+// @Param        id         path      string                  true  "Bin ID (UUID)"
+// @Param        productId  path      string                  true  "Product ID (UUID)"
+// @Param        stock      body      models.BinStockRequest  true  "Quantity in the bin"
+// @Success      200        {object}  models.BinStock
+// @Failure      400        {object}  map[string]string  "Invalid input"
+// @Failure      404        {object}  map[string]string  "Bin or product not found"
+// @Failure      409        {object}  map[string]string  "Bin full or more than on hand"
+// @Security     BearerAuth
+// @Router       /bins/{id}/stock/{productId} [put]
+func SetBinStock(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var input models.BinStockRequest
+	if err := c.BodyParser(&input); err != nil || input.Quantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Quantity cannot be negative"})
+	}
+
+	var stock *models.BinStock
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		bin, err := findOrgBin(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID)
+		if err != nil {
+			return err
+		}
+		var product models.Product
+		if err := tx.First(&product, "id = ? AND org_id = ?", c.Params("productId"), orgID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Product not found")
+		}
+		stock, err = setBinQuantity(tx, bin, product.ID, input.Quantity)
+		return err
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+binFile, zap.String("Function", "SetBinStock"), zap.String("Message", "Failed to set bin stock"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+	return c.JSON(stock)
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const bundleFile = "BundleController"
+
+// bundleComponents returns a bundle's components in a stable order so that
+// callers locking them cannot deadlock each other.
+func bundleComponents(db *gorm.DB, bundleID uuid.UUID) ([]models.BundleComponent, error) {
+	var components []models.BundleComponent
+	err := db.Where("bundle_id = ?", bundleID).Order("component_id").Find(&components).Error
+	return components, err
+}
+
+// buildableKits is how many kits of a bundle the components' available stock
+// could make.
+func buildableKits(db *gorm.DB, bundleID uuid.UUID) (int, error) {
+	components, err := bundleComponents(db, bundleID)
+	if err != nil || len(components) == 0 {
+		return 0, err
+	}
+	kits := -1
+	for _, component := range components {
+		var product models.Product
+		if err := db.First(&product, "id = ?", component.ComponentID).Error; err != nil {
+			return 0, err
+		}
+		avail, err := availableQuantity(db, &product)
+		if err != nil {
+			return 0, err
+		}
+		if n := max(avail, 0) / component.Quantity; kits < 0 || n < kits {
+			kits = n
+		}
+	}
+	return kits, nil
+}
+
+// componentQuantities maps each component product ID to its quantity per
+// kit, for auditing.
+func componentQuantities(components []models.BundleComponent) map[string]int {
+	quantities := make(map[string]int, len(components))
+	for _, component := range components {
+		quantities[component.ComponentID.String()] = component.Quantity
+	}
+	return quantities
+}
+
+// reserveKits reserves the components for up to kits kits of a bundle and
+// returns how many kits it reserved. Products that are not bundles reserve
+// nothing.
+func reserveKits(tx *gorm.DB, bundleID uuid.UUID, kits int) (int, error) {
+	components, err := bundleComponents(tx, bundleID)
+	if err != nil || len(components) == 0 || kits <= 0 {
+		return 0, err
+	}
+	products := make([]*models.Product, len(components))
+	for i, component := range components {
+		product, err := lockProduct(tx, component.ComponentID)
+		if err != nil {
+			return 0, err
+		}
+		avail, err := reservableQuantity(tx, product)
+		if err != nil {
+			return 0, err
+		}
+		kits = min(kits, max(avail, 0)/component.Quantity)
+		products[i] = product
+	}
+	if kits == 0 {
+		return 0, nil
+	}
+	for i, component := range components {
+		if err := holdStock(tx, products[i], kits*component.Quantity); err != nil {
+			return 0, err
+		}
+	}
+	return kits, nil
+}
+
+// kitComponentQuantities adds the component quantities behind kits kits of a
+// bundle to totals, keyed by component product.
+func kitComponentQuantities(tx *gorm.DB, bundleID uuid.UUID, kits int, totals map[uuid.UUID]int) error {
+	components, err := bundleComponents(tx, bundleID)
+	if err != nil {
+		return err
+	}
+	for _, component := range components {
+		totals[component.ComponentID] += kits * component.Quantity
+	}
+	return nil
+}
+
+// shipKits consumes the reserved components of kits kits of a bundle with
+// outbound movements.
+func shipKits(tx *gorm.DB, bundleID uuid.UUID, kits int, userID uuid.UUID, reference string) error {
+	components, err := bundleComponents(tx, bundleID)
+	if err != nil {
+		return err
+	}
+	for _, component := range components {
+		qty := kits * component.Quantity
+		if err := releaseStock(tx, component.ComponentID, qty); err != nil {
+			return err
+		}
+		if _, err := applyMovement(tx, &models.StockMovement{
+			ProductID: component.ComponentID,
+			UserID:    userID,
+			Type:      models.MovementOutbound,
+			Quantity:  -qty,
+			Reference: reference,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBundleComponents godoc
+// @Summary      List a bundle's components
+// @Description  Lists the products that make up a bundle and how many of each go into one kit
+// @Tags         Bundles
+// @Produce      json
+// @Param        id   path      string  true  "Bundle product ID (UUID)"
+// @Success      200  {array}   models.BundleComponent
+// @Failure      404  {object}  map[string]string  "Product not found"
+// @Security     BearerAuth
+// @Router       /products/{id}/components [get]
+func GetBundleComponents(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	components, err := bundleComponents(database.DB, product.ID)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+bundleFile, zap.String("Function", "GetBundleComponents"), zap.String("Message", "Error retrieving components"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving components"})
+	}
+	if components == nil {
+		components = []models.BundleComponent{}
+	}
+	return c.JSON(components)
+}
+
+// SetBundleComponents godoc
+// @Summary      Set a bundle's components
+// @Description  Makes a product a bundle of the given components in fixed quantities per kit, replacing any previous components. An empty list makes it an ordinary product again. Components cannot be bundles or serial-tracked, and components cannot change while sales orders hold kits reserved from them.
+// @Tags         Bundles
+// @Accept       json
+// @Produce      json
+// @Param        id      path      string                true  "Bundle product ID (UUID)"
+// @Param        bundle  body      models.BundleRequest  true  "Components"
+// @Success      200     {array}   models.BundleComponent
+// @Failure      400     {object}  map[string]string  "Invalid components"
+// @Failure      404     {object}  map[string]string  "Product not found"
+// @Failure      409     {object}  map[string]string  "Kits reserved from the current components"
+// @Security     BearerAuth
+// @Router       /products/{id}/components [put]
+func SetBundleComponents(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var input models.BundleRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var components []models.BundleComponent
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var bundle models.Product
+		if err := tx.First(&bundle, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Product not found")
+		}
+		if _, err := lockProduct(tx, bundle.ID); err != nil {
+			return err
+		}
+		if len(input.Components) > 0 && (bundle.LotTracked || bundle.SerialTracked) {
+			return fiber.NewError(fiber.StatusBadRequest, "Lot- or serial-tracked products cannot be bundles")
+		}
+		var usedIn int64
+		if err := tx.Model(&models.BundleComponent{}).Where("component_id = ?", bundle.ID).Count(&usedIn).Error; err != nil {
+			return err
+		}
+		if usedIn > 0 && len(input.Components) > 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "A component of another bundle cannot itself be a bundle")
+		}
+		var kitsReserved int64
+		if err := tx.Model(&models.SalesOrderLine{}).Where("product_id = ? AND kit_reserved_quantity > 0", bundle.ID).Count(&kitsReserved).Error; err != nil {
+			return err
+		}
+		if kitsReserved > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Sales orders hold kits reserved from the current components")
+		}
+
+		seen := make(map[uuid.UUID]bool, len(input.Components))
+		for _, in := range input.Components {
+			if in.Quantity < 1 {
+				return fiber.NewError(fiber.StatusBadRequest, "Component quantity must be at least 1")
+			}
+			if in.ProductID == bundle.ID || seen[in.ProductID] {
+				return fiber.NewError(fiber.StatusBadRequest, "Components must be distinct products other than the bundle")
+			}
+			seen[in.ProductID] = true
+			var component models.Product
+			if err := tx.First(&component, "id = ? AND org_id = ?", in.ProductID, orgID).Error; err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Product "+in.ProductID.String()+" not found")
+			}
+			if component.IsBundle || component.SerialTracked {
+				return fiber.NewError(fiber.StatusBadRequest, "Product "+component.SKU+" is a bundle or serial-tracked and cannot be a component")
+			}
+			components = append(components, models.BundleComponent{BundleID: bundle.ID, ComponentID: component.ID, Quantity: in.Quantity})
+		}
+
+		previous, err := bundleComponents(tx, bundle.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BundleComponent{}).Error; err != nil {
+			return err
+		}
+		if len(components) > 0 {
+			if err := tx.Create(&components).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&bundle).Update("is_bundle", len(components) > 0).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			Action:     "product.update",
+			EntityType: "product",
+			EntityID:   bundle.ID.String(),
+			Before:     map[string]interface{}{"is_bundle": bundle.IsBundle, "components": componentQuantities(previous)},
+			After:      map[string]interface{}{"is_bundle": len(components) > 0, "components": componentQuantities(components)},
+		})
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+bundleFile, zap.String("Function", "SetBundleComponents"), zap.String("Message", "Failed to save components"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+bundleFile, zap.String("Function", "SetBundleComponents"), zap.String("Message", "Bundle components saved"), zap.String("product_id", c.Params("id")), zap.Int("components", len(components)))
+	if components == nil {
+		components = []models.BundleComponent{}
+	}
+	return c.JSON(components)
+}
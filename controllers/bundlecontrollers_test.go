@@ -0,0 +1,72 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestBundleAvailability(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	box := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Gift box", SKU: "GB-1", Price: 1, Quantity: 10})
+	pen := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Pen", SKU: "PN-1", Price: 2, Quantity: 7})
+	bundle := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Pen gift set", SKU: "PGS-1", Price: 6})
+
+	components := models.BundleRequest{Components: []models.BundleComponentRequest{{ProductID: box, Quantity: 1}, {ProductID: pen, Quantity: 2}}}
+	if status := testdb.Call(t, app, fiber.MethodPut, "/products/"+bundle.String()+"/components", bearer, components, nil); status != fiber.StatusOK {
+		t.Fatalf("set components: status %d", status)
+	}
+	nested := models.BundleRequest{Components: []models.BundleComponentRequest{{ProductID: bundle, Quantity: 1}}}
+	if status := testdb.Call(t, app, fiber.MethodPut, "/products/"+box.String()+"/components", bearer, nested, nil); status != fiber.StatusBadRequest {
+		t.Errorf("bundle as a component: status %d, want 400", status)
+	}
+	available := func() int {
+		t.Helper()
+		var detail models.ProductDetail
+		if status := testdb.Call(t, app, fiber.MethodGet, "/products/by-id?product_id="+bundle.String(), bearer, nil, &detail); status != fiber.StatusOK {
+			t.Fatalf("get bundle: status %d", status)
+		}
+		return detail.Available
+	}
+	// Seven pens make three kits.
+	if got := available(); got != 3 {
+		t.Errorf("available kits = %d, want 3", got)
+	}
+
+	assemble := func(quantity int) int {
+		t.Helper()
+		var order models.AssemblyOrder
+		body := models.AssemblyOrderRequest{BundleID: bundle, Quantity: quantity}
+		if status := testdb.Call(t, app, fiber.MethodPost, "/assembly-orders", bearer, body, &order); status != fiber.StatusCreated {
+			t.Fatalf("create assembly order: status %d", status)
+		}
+		return testdb.Call(t, app, fiber.MethodPost, "/assembly-orders/"+order.ID.String()+"/complete", bearer, nil, nil)
+	}
+	if status := assemble(1); status != fiber.StatusOK {
+		t.Fatalf("assemble: status %d", status)
+	}
+	// One assembled kit plus two more from the nine boxes and five pens left.
+	if got := available(); got != 3 {
+		t.Errorf("available kits after assembly = %d, want 3", got)
+	}
+
+	// The order takes the assembled kit first, then components for the rest.
+	order := newSalesOrder(t, app, bearer, bundle, 3)
+	for _, step := range []string{"confirm", "pick", "pack", "ship"} {
+		if status := salesOrderStep(t, app, bearer, order, step); status != fiber.StatusOK {
+			t.Fatalf("%s: status %d", step, status)
+		}
+	}
+	for id, want := range map[uuid.UUID]int{bundle: 0, box: 7, pen: 1} {
+		if got := productQuantity(t, id); got != want {
+			t.Errorf("quantity of %s = %d, want %d", id, got, want)
+		}
+	}
+	if status := assemble(1); status != fiber.StatusConflict {
+		t.Errorf("assembling without enough pens: status %d, want 409", status)
+	}
+}
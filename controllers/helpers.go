@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// currentUserID returns the authenticated user's ID stored by utils.AuthMiddleware.
+func currentUserID(c *fiber.Ctx) (uuid.UUID, error) {
+	userIDStr, ok := c.Locals("userID").(string)
+	if !ok {
+		return uuid.Nil, errors.New("userID not found or not a string")
+	}
+	return uuid.Parse(userIDStr)
+}
+
+// currentOrgID returns the caller's active organization, from the access
+// token. Everything a user can see or change is scoped to it.
+func currentOrgID(c *fiber.Ctx) (uuid.UUID, error) {
+	orgIDStr, ok := c.Locals("orgID").(string)
+	if !ok {
+		return uuid.Nil, errors.New("orgID not found or not a string")
+	}
+	return uuid.Parse(orgIDStr)
+}
+
+// currentScope returns the caller's user ID, for recording who did
+// something, and active organization, for scoping what they can touch.
+func currentScope(c *fiber.Ctx) (userID, orgID uuid.UUID, err error) {
+	if userID, err = currentUserID(c); err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if orgID, err = currentOrgID(c); err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userID, orgID, nil
+}
+
+// sendError writes err as a JSON error body, using its status when it is a
+// *fiber.Error and 500 otherwise.
+func sendError(c *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// throttlePolicy is how failed logins for one key slow down further attempts:
+// after freeAttempts failures each one doubles the wait, from one second up
+// to maxDelay, and maxFailures failures lock the key out for lockout.
+// Failures older than lockout are forgotten.
+type throttlePolicy struct {
+	freeAttempts int
+	maxFailures  int
+	maxDelay     time.Duration
+	lockout      time.Duration
+}
+
+var (
+	accountThrottle = throttlePolicy{freeAttempts: 3, maxFailures: 10, maxDelay: time.Minute, lockout: 15 * time.Minute}
+	// An IP may be shared by an office, so it gets more room than one account.
+	ipThrottle = throttlePolicy{freeAttempts: 20, maxFailures: 100, maxDelay: time.Minute, lockout: 15 * time.Minute}
+)
+
+// errInvalidLogin is the one answer to a wrong username or password, so
+// responses do not reveal which accounts exist.
+var errInvalidLogin = fiber.NewError(fiber.StatusUnauthorized, "Invalid username or password")
+
+// errLoginThrottled is the one answer while an account or IP must wait.
+var errLoginThrottled = fiber.NewError(fiber.StatusTooManyRequests, "Too many failed login attempts. Try again later.")
+
+func accountThrottleKey(username string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// delayAfter is how long key must wait after its nth consecutive failure.
+func (p throttlePolicy) delayAfter(failures int) time.Duration {
+	if failures >= p.maxFailures {
+		return p.lockout
+	}
+	if failures <= p.freeAttempts {
+		return 0
+	}
+	shift := failures - p.freeAttempts - 1
+	if shift > 16 {
+		return p.maxDelay
+	}
+	if delay := time.Second << uint(shift); delay < p.maxDelay {
+		return delay
+	}
+	return p.maxDelay
+}
+
+// loginRetryAfter is how long the caller must wait before a login for
+// username from ip is checked, or zero if it can go ahead.
+func loginRetryAfter(db *gorm.DB, username, ip string) (time.Duration, error) {
+	var throttles []models.LoginThrottle
+	if err := db.Where("key IN ? AND blocked_until > ?", []string{accountThrottleKey(username), ipThrottleKey(ip)}, time.Now()).
+		Find(&throttles).Error; err != nil {
+		return 0, err
+	}
+	var wait time.Duration
+	for _, t := range throttles {
+		if d := time.Until(*t.BlockedUntil); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// checkLoginThrottle refuses with errLoginThrottled, and a Retry-After
+// header, while username or the caller's IP must wait. Every check of a
+// password or second factor goes through it, so none of them can be used to
+// keep guessing once the login form is throttled.
+func checkLoginThrottle(c *fiber.Ctx, db *gorm.DB, username string) error {
+	wait, err := loginRetryAfter(db, username, c.IP())
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return errLoginThrottled
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login against username and, when ip is
+// not empty, against ip.
+func recordLoginFailure(db *gorm.DB, username, ip string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := recordThrottleFailure(tx, accountThrottleKey(username), accountThrottle); err != nil {
+			return err
+		}
+		if ip == "" {
+			return nil
+		}
+		return recordThrottleFailure(tx, ipThrottleKey(ip), ipThrottle)
+	})
+}
+
+func recordThrottleFailure(tx *gorm.DB, key string, policy throttlePolicy) error {
+	now := time.Now()
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Key: key, LastFailedAt: now}).Error; err != nil {
+		return err
+	}
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&throttle, "key = ?", key).Error; err != nil {
+		return err
+	}
+	if now.Sub(throttle.LastFailedAt) > policy.lockout {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailedAt = now
+	throttle.BlockedUntil = nil
+	if delay := policy.delayAfter(throttle.Failures); delay > 0 {
+		until := now.Add(delay)
+		throttle.BlockedUntil = &until
+	}
+	return tx.Save(&throttle).Error
+}
+
+// clearAccountThrottle forgets username's failed logins, after a successful
+// login or when an admin unlocks the account.
+func clearAccountThrottle(db *gorm.DB, username string) error {
+	return db.Where("key = ?", accountThrottleKey(username)).Delete(&models.LoginThrottle{}).Error
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// spendPasswordCheck does the work of checking a password against nothing,
+// so unknown usernames take as long to reject as wrong passwords.
+func spendPasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = utils.HashPassword("not-a-real-password")
+	})
+	_ = utils.CheckPassword(dummyHash, password)
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayAfter(t *testing.T) {
+	policy := throttlePolicy{freeAttempts: 3, maxFailures: 10, maxDelay: 5 * time.Second, lockout: time.Hour}
+	for failures, want := range map[int]time.Duration{
+		0:  0,
+		3:  0,
+		4:  time.Second,
+		5:  2 * time.Second,
+		6:  4 * time.Second,
+		7:  5 * time.Second,
+		9:  5 * time.Second,
+		10: time.Hour,
+		50: time.Hour,
+	} {
+		if got := policy.delayAfter(failures); got != want {
+			t.Errorf("delayAfter(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package controllers_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+// resetIPThrottles forgets failed logins by IP for the rest of the test.
+// Every test request comes from the same address, so tests that fail logins
+// on purpose would otherwise throttle each other.
+func resetIPThrottles(t *testing.T) {
+	t.Helper()
+	forget := func() {
+		if err := database.DB.Where("key LIKE ?", "ip:%").Delete(&models.LoginThrottle{}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	forget()
+	t.Cleanup(forget)
+}
+
+func TestLoginThrottle(t *testing.T) {
+	app := testdb.App(t)
+	resetIPThrottles(t)
+
+	admin, adminToken := testdb.SignUp(t, app)
+	member, _ := testdb.SignUp(t, app)
+	joinOrg(t, app, adminToken, personalOrg(t, admin), member, models.RoleClerk)
+
+	login := func(password string) (int, string) {
+		body := `{"username":"` + member.Username + `","password":"` + password + `"}`
+		req := httptest.NewRequest(fiber.MethodPost, "/login", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter)
+	}
+	for i := 0; i < 4; i++ {
+		if status, _ := login("wrong"); status != fiber.StatusUnauthorized {
+			t.Fatalf("wrong password %d: status %d, want 401", i+1, status)
+		}
+	}
+	status, retryAfter := login(testdb.Password)
+	if status != fiber.StatusTooManyRequests || retryAfter == "" {
+		t.Errorf("right password while throttled: status %d, Retry-After %q, want 429 with Retry-After", status, retryAfter)
+	}
+
+	path := "/admin/users/" + member.UserID.String() + "/unlock"
+	if status := testdb.Call(t, app, fiber.MethodPost, path, adminToken, nil, nil); status != fiber.StatusNoContent {
+		t.Fatalf("unlock: status %d", status)
+	}
+	if status, _ := login(testdb.Password); status != fiber.StatusOK {
+		t.Errorf("right password after unlock: status %d, want 200", status)
+	}
+}
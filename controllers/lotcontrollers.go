@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const lotFile = "LotController"
+
+// findOrCreateLot returns the product's lot with lotNumber, creating it with
+// expiresAt if it does not exist yet. An existing lot without an expiry date
+// takes expiresAt.
+func findOrCreateLot(tx *gorm.DB, productID uuid.UUID, lotNumber string, expiresAt *time.Time) (*models.Lot, error) {
+	var lot models.Lot
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND lot_number = ?", productID, lotNumber).First(&lot).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		lot = models.Lot{ProductID: productID, LotNumber: lotNumber, ExpiresAt: expiresAt}
+		if err := tx.Create(&lot).Error; err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case lot.ExpiresAt == nil && expiresAt != nil:
+		lot.ExpiresAt = expiresAt
+		if err := tx.Model(&lot).Update("expires_at", expiresAt).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &lot, nil
+}
+
+// pinLots marks qty units of a product's lots as reserved, first-expiry-first-out
+// over lots that are still good at goodUntil.
+func pinLots(tx *gorm.DB, productID uuid.UUID, qty int, goodUntil time.Time) error {
+	var lots []models.Lot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND quantity > reserved", productID).
+		Where("expires_at IS NULL OR expires_at > ?", goodUntil).
+		Order("expires_at ASC NULLS LAST, received_at ASC").Find(&lots).Error; err != nil {
+		return err
+	}
+	for i := range lots {
+		if qty == 0 {
+			break
+		}
+		lot := &lots[i]
+		take := min(qty, lot.Quantity-lot.Reserved)
+		if err := tx.Model(lot).Update("reserved", lot.Reserved+take).Error; err != nil {
+			return err
+		}
+		qty -= take
+	}
+	if qty > 0 {
+		return errInsufficientStock
+	}
+	return nil
+}
+
+// unpinLots releases up to qty reserved units of a product's lots. Units
+// pinned in lots that have since expired go first, as they cannot be shipped,
+// then first-expiry-first-out, so a shipment that releases its reservation
+// and then takes stock FEFO takes the units it had pinned.
+func unpinLots(tx *gorm.DB, productID uuid.UUID, qty int) error {
+	var lots []models.Lot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND reserved > 0", productID).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "COALESCE(expires_at <= ?, false) DESC, expires_at ASC NULLS LAST, received_at ASC",
+			Vars: []interface{}{time.Now()},
+		}}).
+		Find(&lots).Error; err != nil {
+		return err
+	}
+	for i := range lots {
+		if qty == 0 {
+			break
+		}
+		lot := &lots[i]
+		take := min(qty, lot.Reserved)
+		if err := tx.Model(lot).Update("reserved", lot.Reserved-take).Error; err != nil {
+			return err
+		}
+		qty -= take
+	}
+	return nil
+}
+
+// applyLotMovement books a movement of a lot-tracked product against its lots.
+// Increases go to movement.LotID, or the unnamed lot when none is set.
+// Decreases take from movement.LotID when set and otherwise first-expiry-first-out,
+// splitting the movement per lot consumed. They only take units not pinned by
+// reservations; shipping releases its pins first. Only adjustments may draw on
+// expired lots; everything else skips them, so a shipment whose pinned lot has
+// expired is made up from other lots.
+func applyLotMovement(tx *gorm.DB, movement *models.StockMovement) ([]*models.StockMovement, error) {
+	if movement.Quantity > 0 {
+		if movement.LotID == nil {
+			lot, err := findOrCreateLot(tx, movement.ProductID, "", nil)
+			if err != nil {
+				return nil, err
+			}
+			movement.LotID = &lot.ID
+		}
+		err := tx.Model(&models.Lot{}).Where("id = ? AND product_id = ?", *movement.LotID, movement.ProductID).
+			Update("quantity", gorm.Expr("quantity + ?", movement.Quantity)).Error
+		return []*models.StockMovement{movement}, err
+	}
+
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND quantity > reserved", movement.ProductID)
+	if movement.LotID != nil {
+		query = query.Where("id = ?", *movement.LotID)
+	} else if movement.Type != models.MovementAdjustment {
+		query = query.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+	var lots []models.Lot
+	if err := query.Order("expires_at ASC NULLS LAST, received_at ASC").Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	remaining := -movement.Quantity
+	var movements []*models.StockMovement
+	for i := range lots {
+		if remaining == 0 {
+			break
+		}
+		lot := &lots[i]
+		take := min(remaining, lot.Quantity-lot.Reserved)
+		if err := tx.Model(lot).Update("quantity", lot.Quantity-take).Error; err != nil {
+			return nil, err
+		}
+		split := *movement
+		split.Quantity = -take
+		split.LotID = &lot.ID
+		movements = append(movements, &split)
+		remaining -= take
+	}
+	if remaining > 0 {
+		return nil, errInsufficientStock
+	}
+	return movements, nil
+}
+
+// GetProductLots godoc
+// @Summary      List a product's lots
+// @Description  Lists the lots of a product with quantities and expiry dates, first-expiring first
+// @Tags         Lots
+// @Produce      json
+// @Param        id     path      string  true   "Product ID (UUID)"
+// @Param        empty  query     bool    false  "Include lots with no remaining quantity"
+// @Success      200    {array}   models.Lot
+// @Failure      404    {object}  map[string]string  "Product not found"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /products/{id}/lots [get]
+func GetProductLots(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	query := database.DB.Where("product_id = ?", product.ID)
+	if !c.QueryBool("empty") {
+		query = query.Where("quantity > 0")
+	}
+	lots := []models.Lot{}
+	if err := query.Order("expires_at ASC NULLS LAST, received_at ASC").Find(&lots).Error; err != nil {
+		logger.Log.Error("Package controllers File "+lotFile, zap.String("Function", "GetProductLots"), zap.String("Message", "Error retrieving lots"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving lots"})
+	}
+	return c.JSON(lots)
+}
+
+// GetExpiringLots godoc
+// @Summary      Expiring-soon report
+// @Description  Lists lots with stock that expire within the given number of days, including lots that have already expired
+// @Tags         Lots
+// @Produce      json
+// @Param        days  query     int  false  "Look-ahead window in days (default: 30)"
+// @Success      200   {array}   models.ExpiringLot
+// @Failure      401   {object}  map[string]string  "Unauthorized"
+// @Failure      500   {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /lots/expiring [get]
+func GetExpiringLots(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	days := c.QueryInt("days", 30)
+	if days < 0 {
+		days = 30
+	}
+	now := time.Now()
+
+	lots := []models.ExpiringLot{}
+	if err := database.DB.Table("lots").
+		Select("lots.*, p.name, p.sku, lots.expires_at <= ? AS expired", now).
+		Joins("JOIN products p ON p.id = lots.product_id").
+		Where("p.org_id = ? AND lots.quantity > 0 AND lots.expires_at <= ?", orgID, now.AddDate(0, 0, days)).
+		Order("lots.expires_at ASC").
+		Scan(&lots).Error; err != nil {
+		logger.Log.Error("Package controllers File "+lotFile, zap.String("Function", "GetExpiringLots"), zap.String("Message", "Error retrieving expiring lots"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving expiring lots"})
+	}
+	return c.JSON(lots)
+}
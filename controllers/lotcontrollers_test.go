@@ -0,0 +1,72 @@
+package controllers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestShipFirstExpiryFirstOut(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Yoghurt", SKU: "GY-500", Price: 2, LotTracked: true})
+
+	path := "/products/" + product.String() + "/quantity"
+	total := 0
+	receive := func(lotNumber string, quantity int, expiresIn time.Duration) {
+		t.Helper()
+		expiresAt := time.Now().Add(expiresIn)
+		total += quantity
+		body := models.QuantityUpdateRequest{Quantity: total, LotNumber: lotNumber, ExpiresAt: &expiresAt}
+		if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, body, nil); status != fiber.StatusOK {
+			t.Fatalf("receive lot %s: status %d", lotNumber, status)
+		}
+	}
+	receive("LATE", 5, 200*24*time.Hour)
+	receive("EARLY", 5, 100*24*time.Hour)
+	// Already expired, so it is neither reserved nor shipped.
+	receive("EXPIRED", 3, -time.Hour)
+
+	order := newSalesOrder(t, app, bearer, product, 7)
+	for _, step := range []string{"confirm", "pick", "pack", "ship"} {
+		if status := salesOrderStep(t, app, bearer, order, step); status != fiber.StatusOK {
+			t.Fatalf("%s: status %d", step, status)
+		}
+	}
+
+	var lots []models.Lot
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products/"+product.String()+"/lots", bearer, nil, &lots); status != fiber.StatusOK {
+		t.Fatalf("list lots: status %d", status)
+	}
+	remaining := make(map[string]int)
+	for _, lot := range lots {
+		remaining[lot.LotNumber] = lot.Quantity
+		if lot.Reserved != 0 {
+			t.Errorf("lot %s still has %d reserved", lot.LotNumber, lot.Reserved)
+		}
+	}
+	if len(remaining) != 2 || remaining["EXPIRED"] != 3 || remaining["LATE"] != 3 {
+		t.Errorf("lots left = %v, want EXPIRED untouched and 3 of LATE", remaining)
+	}
+
+	var shipped []int
+	if err := database.DB.Model(&models.StockMovement{}).Where("reference = ?", "so:"+order.ID.String()).
+		Order("quantity").Pluck("quantity", &shipped).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(shipped) != 2 || shipped[0] != -5 || shipped[1] != -2 {
+		t.Errorf("outbound movements = %v, want the shipment split per lot as [-5 -2]", shipped)
+	}
+
+	var expiring []models.ExpiringLot
+	if status := testdb.Call(t, app, fiber.MethodGet, "/lots/expiring?days=30", bearer, nil, &expiring); status != fiber.StatusOK {
+		t.Fatalf("expiring lots: status %d", status)
+	}
+	if len(expiring) != 1 || expiring[0].LotNumber != "EXPIRED" || !expiring[0].Expired {
+		t.Errorf("expiring = %+v, want only EXPIRED, flagged as expired", expiring)
+	}
+}
@@ -0,0 +1,391 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/mailer"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const meFile = "MeController"
+
+// errWrongCurrentPassword is the answer when current_password is wrong. It is
+// not 401, which clients take to mean their access token is no good.
+var errWrongCurrentPassword = fiber.NewError(fiber.StatusForbidden, "Current password is incorrect")
+
+// GetMe godoc
+// @Summary      Get your account
+// @Description  Returns the authenticated user's own account, whether it has a password, two-factor authentication and single sign-on, and their role and permissions in the active organization
+// @Tags         Me
+// @Produce      json
+// @Success      200  {object}  models.MeResponse
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /me [get]
+func GetMe(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var user models.User
+	if err := database.DB.First(&user, "user_id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	me, err := meResponse(c, &user)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+meFile, zap.String("Function", "GetMe"), zap.String("Message", "Failed to load account"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	return c.JSON(me)
+}
+
+// meResponse describes user to themselves, with their role and permissions
+// from the access token.
+func meResponse(c *fiber.Ctx, user *models.User) (*models.MeResponse, error) {
+	var twoFactor, identities int64
+	if err := database.DB.Model(&models.TwoFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", user.UserID).Count(&twoFactor).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Model(&models.ExternalIdentity{}).Where("user_id = ?", user.UserID).Count(&identities).Error; err != nil {
+		return nil, err
+	}
+	orgID, _ := currentOrgID(c)
+	role, _ := c.Locals("role").(string)
+	perms, _ := c.Locals("permissions").([]string)
+	return &models.MeResponse{
+		UserResponse:     user.Response(),
+		PasswordSet:      user.Password != "",
+		TwoFactorEnabled: twoFactor > 0,
+		SSOLinked:        identities > 0,
+		OrgID:            orgID,
+		Role:             role,
+		Permissions:      perms,
+	}, nil
+}
+
+// UpdateMe godoc
+// @Summary      Update your account
+// @Description  Changes the authenticated user's username, email address or password. Omitted fields are left alone. Changing the email address or password needs current_password; wrong guesses count towards the account's login throttle. A new email address must be verified again, and the old one is told about the change. A new password must meet the same policy as at registration, and every other session is signed out. Accounts created by single sign-on have no password; they can set one with /password/forgot.
+// @Tags         Me
+// @Accept       json
+// @Produce      json
+// @Param        changes  body      models.UpdateMeRequest  true  "Fields to change"
+// @Success      200      {object}  models.MeResponse
+// @Failure      400      {object}  map[string]string  "Invalid input or weak password"
+// @Failure      403      {object}  map[string]string  "Current password is incorrect"
+// @Failure      409      {object}  map[string]string  "Username or email already in use"
+// @Failure      429      {object}  map[string]string  "Too many wrong passwords; see Retry-After"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /me [patch]
+func UpdateMe(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var input models.UpdateMeRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Username == nil && input.Email == nil && input.NewPassword == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Nothing to change"})
+	}
+	sid, _ := c.Locals("sid").(string)
+	sessionID, _ := uuid.Parse(sid)
+
+	var user models.User
+	var oldEmail, verifyToken string
+	var retryAfter time.Duration
+	wrongPassword, passwordChanged := false, false
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "user_id = ?", userID).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid user ID")
+		}
+		before := user
+		if input.Email != nil || input.NewPassword != nil {
+			if user.Password == "" {
+				return fiber.NewError(fiber.StatusBadRequest, "Your account has no password; set one with /password/forgot first")
+			}
+			wait, err := loginRetryAfter(tx, user.Username, c.IP())
+			if err != nil {
+				return err
+			}
+			if wait > 0 {
+				retryAfter = wait
+				return errLoginThrottled
+			}
+			if utils.CheckPassword(user.Password, input.CurrentPassword) != nil {
+				wrongPassword = true
+				return errWrongCurrentPassword
+			}
+		}
+
+		updates := map[string]interface{}{}
+		if input.Username != nil {
+			username := strings.TrimSpace(*input.Username)
+			if !usernamePattern.MatchString(username) {
+				return fiber.NewError(fiber.StatusBadRequest, "username must be 3-32 letters, digits, '.', '_' or '-', starting with a letter or digit")
+			}
+			if username != user.Username {
+				if taken, err := takenByOther(tx, "username", username, userID); err != nil {
+					return err
+				} else if taken {
+					return fiber.NewError(fiber.StatusConflict, "Username already in use")
+				}
+				updates["username"] = username
+				user.Username = username
+			}
+		}
+		if input.Email != nil {
+			email := strings.ToLower(strings.TrimSpace(*input.Email))
+			if !validEmail(email) {
+				return fiber.NewError(fiber.StatusBadRequest, "email must be a valid email address")
+			}
+			if email != user.Email {
+				if taken, err := takenByOther(tx, "email", email, userID); err != nil {
+					return err
+				} else if taken {
+					return fiber.NewError(fiber.StatusConflict, "Email already in use")
+				}
+				oldEmail = user.Email
+				updates["email"] = email
+				updates["email_verified_at"] = nil
+				user.Email = email
+				user.EmailVerifiedAt = nil
+				token, err := issueAccountToken(tx, userID, models.TokenPurposeEmailVerification, emailVerificationTTL)
+				if err != nil {
+					return err
+				}
+				verifyToken = token
+			}
+		}
+		if input.NewPassword != nil {
+			// Checked against the new username and email, if they change too.
+			if msg := passwordProblemFor(&user, *input.NewPassword); msg != "" {
+				return fiber.NewError(fiber.StatusBadRequest, msg)
+			}
+			hashed, err := utils.HashPassword(*input.NewPassword)
+			if err != nil {
+				return err
+			}
+			updates["password"] = hashed
+			user.Password = hashed
+			passwordChanged = true
+			if err := tx.Model(&models.Session{}).
+				Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, sessionID).
+				Update("revoked_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.User{}).Where("user_id = ?", userID).Updates(updates).Error; err != nil {
+			return err
+		}
+		var extra map[string]models.AuditChange
+		if passwordChanged {
+			extra = passwordAudit(true)
+		}
+		return auditUser(tx, "user.update", &before, &user, extra, nil)
+	})
+	if wrongPassword {
+		if err := recordLoginFailure(database.DB, user.Username, c.IP()); err != nil {
+			logger.Log.Error("Package controllers File "+meFile, zap.String("Function", "UpdateMe"), zap.String("Message", "Failed to record wrong password"), zap.Error(err))
+		}
+	}
+	if retryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+meFile, zap.String("Function", "UpdateMe"), zap.String("Message", "Failed to update account"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	if verifyToken != "" {
+		go deliver("UpdateMe", verificationEmail(&user, verifyToken))
+		go deliver("UpdateMe", emailChangedNotice(&user, oldEmail))
+	}
+	if passwordChanged {
+		go deliver("UpdateMe", passwordChangedNotice(&user))
+	}
+	logger.Log.Info("Package controllers File "+meFile, zap.String("Function", "UpdateMe"), zap.String("Message", "Account updated"), zap.String("user_id", userID.String()), zap.Bool("email_changed", verifyToken != ""), zap.Bool("password_changed", passwordChanged))
+
+	me, err := meResponse(c, &user)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+meFile, zap.String("Function", "UpdateMe"), zap.String("Message", "Failed to load account"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	return c.JSON(me)
+}
+
+// takenByOther reports whether a user other than userID has value in column,
+// ignoring case.
+func takenByOther(tx *gorm.DB, column, value string, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := tx.Model(&models.User{}).
+		Where("LOWER("+column+") = LOWER(?) AND user_id <> ?", value, userID).Count(&count).Error
+	return count > 0, err
+}
+
+func emailChangedNotice(user *models.User, oldEmail string) mailer.Message {
+	return mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"The email address of your account was changed from this address to " + user.Email + ".\n\n" +
+			"If you did not do this, reset your password and contact your administrator.\n",
+	}
+}
+
+func passwordChangedNotice(user *models.User) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"The password of your account was just changed and your other sessions were signed out.\n\n" +
+			"If you did not do this, reset your password right away and contact your administrator.\n",
+	}
+}
+
+// GetMySessions godoc
+// @Summary      List your sessions
+// @Description  Lists the authenticated user's signed-in sessions, one per login, most recently used first. Each shows the device and IP it signed in from, when it was created and when it last refreshed its tokens. The session making the request is marked current.
+// @Tags         Me
+// @Produce      json
+// @Success      200  {array}   models.SessionInfo
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /me/sessions [get]
+func GetMySessions(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	sid, _ := c.Locals("sid").(string)
+
+	var sessions []models.Session
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		logger.Log.Error("Package controllers File "+meFile, zap.String("Function", "GetMySessions"), zap.String("Message", "Error retrieving sessions"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving sessions"})
+	}
+	infos := make([]models.SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, models.SessionInfo{
+			Session: s,
+			Device:  describeUserAgent(s.UserAgent),
+			Current: s.ID.String() == sid,
+		})
+	}
+	return c.JSON(infos)
+}
+
+// RevokeMySession godoc
+// @Summary      Revoke one of your sessions
+// @Description  Signs out one of the authenticated user's sessions, such as a lost device: its refresh token and access tokens stop working. Revoking the current session is the same as logging out.
+// @Tags         Me
+// @Produce      json
+// @Param        id   path      string  true  "Session ID (UUID)"
+// @Success      204
+// @Failure      400  {object}  map[string]string  "Invalid session ID"
+// @Failure      404  {object}  map[string]string  "Session not found"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /me/sessions/{id} [delete]
+func RevokeMySession(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid session ID format"})
+	}
+	sid, _ := c.Locals("sid").(string)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusNotFound, "Session not found")
+		}
+		if sessionID.String() == sid {
+			return revokeAccessToken(tx, c)
+		}
+		return nil
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+meFile, zap.String("Function", "RevokeMySession"), zap.String("Message", "Failed to revoke session"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+meFile, zap.String("Function", "RevokeMySession"), zap.String("Message", "Session revoked"), zap.String("session_id", sessionID.String()), zap.String("user_id", userID.String()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// describeUserAgent names the browser or client and operating system in a
+// user agent string, e.g. "Firefox on Windows", falling back to the string
+// itself.
+func describeUserAgent(ua string) string {
+	if ua == "" {
+		return "Unknown device"
+	}
+	client := ""
+	for _, c := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+		{"Safari/", "Safari"}, {"curl/", "curl"}, {"PostmanRuntime/", "Postman"}, {"Go-http-client/", "Go client"},
+	} {
+		if strings.Contains(ua, c.token) {
+			client = c.name
+			break
+		}
+	}
+	system := ""
+	for _, s := range []struct{ token, name string }{
+		{"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Android", "Android"}, {"Windows", "Windows"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, s.token) {
+			system = s.name
+			break
+		}
+	}
+	switch {
+	case client != "" && system != "":
+		return client + " on " + system
+	case client != "":
+		return client
+	case system != "":
+		return system
+	}
+	if len(ua) > 60 {
+		return ua[:60] + "…"
+	}
+	return ua
+}
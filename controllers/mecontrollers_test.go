@@ -0,0 +1,51 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestUpdateMe(t *testing.T) {
+	app := testdb.App(t)
+	user, bearer := testdb.SignUp(t, app)
+	other := testdb.Login(t, app, user.Username).AccessToken
+
+	var me models.MeResponse
+	if status := testdb.Call(t, app, fiber.MethodGet, "/me", bearer, nil, &me); status != fiber.StatusOK {
+		t.Fatalf("get me: status %d", status)
+	}
+	if me.Username != user.Username || me.Role != models.RoleAdmin || !me.PasswordSet || me.TwoFactorEnabled {
+		t.Errorf("me = %+v, want %s, admin of their organization, with a password and no 2FA", me, user.Username)
+	}
+
+	newPassword := "a new " + testdb.Password
+	change := models.UpdateMeRequest{NewPassword: &newPassword, CurrentPassword: "wrong"}
+	if status := testdb.Call(t, app, fiber.MethodPatch, "/me", bearer, change, nil); status != fiber.StatusForbidden {
+		t.Errorf("wrong current password: status %d, want 403", status)
+	}
+	change.CurrentPassword = testdb.Password
+	if status := testdb.Call(t, app, fiber.MethodPatch, "/me", bearer, change, nil); status != fiber.StatusOK {
+		t.Fatalf("change password: status %d", status)
+	}
+	// Changing the password keeps this session and signs out the rest.
+	if status := testdb.Call(t, app, fiber.MethodGet, "/me", other, nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("other session after the change: status %d, want 401", status)
+	}
+
+	var sessions []models.SessionInfo
+	if status := testdb.Call(t, app, fiber.MethodGet, "/me/sessions", bearer, nil, &sessions); status != fiber.StatusOK {
+		t.Fatalf("list sessions: status %d", status)
+	}
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Fatalf("sessions = %+v, want just the current one", sessions)
+	}
+	if status := testdb.Call(t, app, fiber.MethodDelete, "/me/sessions/"+sessions[0].ID.String(), bearer, nil, nil); status != fiber.StatusNoContent {
+		t.Fatalf("revoke session: status %d", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodGet, "/me", bearer, nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("revoked session: status %d, want 401", status)
+	}
+}
@@ -0,0 +1,394 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const orgFile = "OrgController"
+
+// invitationTTL is how long an invitation can be accepted.
+const invitationTTL = 7 * 24 * time.Hour
+
+// createPersonalOrg creates user's personal organization with them as its
+// admin.
+func createPersonalOrg(tx *gorm.DB, user *models.User) (*models.Membership, error) {
+	org := models.Organization{Name: user.Username, Personal: true, CreatedBy: user.UserID}
+	if err := tx.Create(&org).Error; err != nil {
+		return nil, err
+	}
+	membership := models.Membership{OrgID: org.ID, UserID: user.UserID, Role: models.RoleAdmin}
+	if err := tx.Create(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, auditMembership(tx, "membership.create", nil, &membership, &user.UserID)
+}
+
+// sessionMembership is user's membership of orgID, falling back to their
+// personal organization, then their oldest membership. A user left without
+// any organization gets a new personal one.
+func sessionMembership(tx *gorm.DB, user *models.User, orgID uuid.UUID) (*models.Membership, error) {
+	var membership models.Membership
+	err := tx.Where("user_id = ? AND org_id = ?", user.UserID, orgID).First(&membership).Error
+	if err == nil {
+		return &membership, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	err = tx.Joins("JOIN organizations o ON o.id = memberships.org_id").
+		Where("memberships.user_id = ?", user.UserID).
+		Order("o.personal DESC, memberships.created_at").First(&membership).Error
+	if err == nil {
+		return &membership, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return createPersonalOrg(tx, user)
+}
+
+// revokeOrgSessions revokes userID's sessions working in orgID, so a change
+// to their membership applies immediately.
+func revokeOrgSessions(tx *gorm.DB, userID, orgID uuid.UUID) error {
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND org_id = ? AND revoked_at IS NULL", userID, orgID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// CreateOrganization godoc
+// @Summary      Create an organization
+// @Description  Creates a shared organization with the caller as its admin. Switch to it to work in its inventory.
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Param        org  body      models.OrganizationRequest  true  "Organization"
+// @Success      201  {object}  models.Organization
+// @Failure      400  {object}  map[string]string  "Invalid input"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /orgs [post]
+func CreateOrganization(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.OrganizationRequest
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required"})
+	}
+
+	org := models.Organization{Name: strings.TrimSpace(input.Name), CreatedBy: userID}
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		membership := models.Membership{OrgID: org.ID, UserID: userID, Role: models.RoleAdmin}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		return auditMembership(tx, "membership.create", nil, &membership, nil)
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "CreateOrganization"), zap.String("Message", "Database error while creating organization"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving organization"})
+	}
+
+	logger.Log.Info("Package controllers File "+orgFile, zap.String("Function", "CreateOrganization"), zap.String("Message", "Organization created"), zap.String("org_id", org.ID.String()))
+	return c.Status(fiber.StatusCreated).JSON(org)
+}
+
+// GetOrganizations godoc
+// @Summary      List my organizations
+// @Description  Lists the organizations the caller belongs to, their role in each and which one is active
+// @Tags         Organizations
+// @Produce      json
+// @Success      200  {array}   models.OrganizationMembership
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /orgs [get]
+func GetOrganizations(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	orgID, _ := currentOrgID(c)
+
+	orgs := []models.OrganizationMembership{}
+	if err := database.DB.Table("memberships m").
+		Select("m.org_id, o.name, o.personal, o.require_2fa, m.role").
+		Joins("JOIN organizations o ON o.id = m.org_id").
+		Where("m.user_id = ?", userID).
+		Order("o.personal DESC, o.name").Scan(&orgs).Error; err != nil {
+		logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "GetOrganizations"), zap.String("Message", "Error retrieving organizations"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving organizations"})
+	}
+	for i := range orgs {
+		orgs[i].Active = orgs[i].OrgID == orgID
+	}
+	return c.JSON(orgs)
+}
+
+// SwitchOrganization godoc
+// @Summary      Switch active organization
+// @Description  Makes another of the caller's organizations active for this session and returns an access token for it, carrying the caller's role there. The refresh token stays the same and keeps the new organization.
+// @Tags         Organizations
+// @Produce      json
+// @Param        id   path      string  true  "Organization ID (UUID)"
+// @Success      200  {object}  models.TokenResponse
+// @Failure      400  {object}  map[string]string  "Invalid organization ID"
+// @Failure      404  {object}  map[string]string  "Not a member of the organization"
+// @Security     BearerAuth
+// @Router       /orgs/{id}/switch [post]
+func SwitchOrganization(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	orgID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid organization ID format"})
+	}
+	sid, _ := c.Locals("sid").(string)
+
+	var token string
+	err = audit.Transaction(database.DB, func(tx *gorm.DB) error {
+		var membership models.Membership
+		if err := tx.First(&membership, "user_id = ? AND org_id = ?", userID, orgID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Not a member of this organization")
+		}
+		var session models.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ? AND user_id = ?", sid, userID).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid session")
+		}
+		var user models.User
+		if err := tx.First(&user, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		session.OrgID = orgID
+		if err := tx.Model(&session).Update("org_id", orgID).Error; err != nil {
+			return err
+		}
+		var err error
+		if token, err = accessToken(tx, &user, &session); err != nil {
+			return err
+		}
+		// The old token still names the previous organization.
+		return revokeAccessToken(tx, c)
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "SwitchOrganization"), zap.String("Message", "Failed to switch organization"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	return c.JSON(models.TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(utils.AccessTokenTTL().Seconds()),
+	})
+}
+
+// CreateInvitation godoc
+// @Summary      Invite a member
+// @Description  Invites an email address into the active organization with a role. The returned token is shown once; the invitee accepts it while signed in with that email.
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Param        invitation  body      models.InvitationRequest  true  "Invitation"
+// @Success      201         {object}  models.CreatedInvitation
+// @Failure      400         {object}  map[string]string  "Invalid email or role"
+// @Failure      409         {object}  map[string]string  "Already a member"
+// @Security     BearerAuth
+// @Router       /admin/invitations [post]
+func CreateInvitation(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var input models.InvitationRequest
+	if err := c.BodyParser(&input); err != nil || !strings.Contains(input.Email, "@") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A valid email is required"})
+	}
+	if !models.ValidRole(input.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role must be one of admin, manager, clerk, viewer"})
+	}
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+
+	var members int64
+	if err := database.DB.Model(&models.Membership{}).
+		Joins("JOIN users u ON u.user_id = memberships.user_id").
+		Where("memberships.org_id = ? AND LOWER(u.email) = ?", orgID, email).
+		Count(&members).Error; err != nil {
+		logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "CreateInvitation"), zap.String("Message", "Error checking membership"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	if members > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Already a member of this organization"})
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	invitation := models.CreatedInvitation{
+		Invitation: models.Invitation{
+			OrgID:     orgID,
+			Email:     email,
+			Role:      input.Role,
+			TokenHash: hashToken(token),
+			InvitedBy: userID,
+			ExpiresAt: time.Now().Add(invitationTTL),
+		},
+		Token: token,
+	}
+	if err := database.DB.Create(&invitation.Invitation).Error; err != nil {
+		logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "CreateInvitation"), zap.String("Message", "Database error while creating invitation"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving invitation"})
+	}
+
+	logger.Log.Info("Package controllers File "+orgFile, zap.String("Function", "CreateInvitation"), zap.String("Message", "Invitation created"), zap.String("invitation_id", invitation.ID.String()), zap.String("org_id", orgID.String()))
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+// GetInvitations godoc
+// @Summary      List pending invitations
+// @Description  Lists the active organization's invitations that have not been accepted or expired
+// @Tags         Organizations
+// @Produce      json
+// @Success      200  {array}   models.Invitation
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /admin/invitations [get]
+func GetInvitations(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	invitations := []models.Invitation{}
+	if err := database.DB.Where("org_id = ? AND accepted_at IS NULL AND expires_at > ?", orgID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "GetInvitations"), zap.String("Message", "Error retrieving invitations"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving invitations"})
+	}
+	return c.JSON(invitations)
+}
+
+// DeleteInvitation godoc
+// @Summary      Withdraw an invitation
+// @Description  Deletes a pending invitation of the active organization
+// @Tags         Organizations
+// @Produce      json
+// @Param        id   path      string  true  "Invitation ID (UUID)"
+// @Success      204
+// @Failure      404  {object}  map[string]string  "Invitation not found"
+// @Security     BearerAuth
+// @Router       /admin/invitations/{id} [delete]
+func DeleteInvitation(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	invitationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid invitation ID format"})
+	}
+	result := database.DB.Where("id = ? AND org_id = ? AND accepted_at IS NULL", invitationID, orgID).Delete(&models.Invitation{})
+	if result.Error != nil {
+		logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "DeleteInvitation"), zap.String("Message", "Error deleting invitation"), zap.Error(result.Error))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error deleting invitation"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Invitation not found"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AcceptInvitation godoc
+// @Summary      Accept an invitation
+// @Description  Joins the inviting organization with the invited role. The caller must be signed in with the invited email address. Switch to the organization to start working in it.
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Param        invitation  body      models.AcceptInvitationRequest  true  "Invitation token"
+// @Success      200         {object}  models.Membership
+// @Failure      400         {object}  map[string]string  "Invalid input"
+// @Failure      403         {object}  map[string]string  "Invitation is for another email"
+// @Failure      404         {object}  map[string]string  "Invitation not found or expired"
+// @Failure      409         {object}  map[string]string  "Already a member"
+// @Security     BearerAuth
+// @Router       /invitations/accept [post]
+func AcceptInvitation(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var input models.AcceptInvitationRequest
+	if err := c.BodyParser(&input); err != nil || input.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token is required"})
+	}
+
+	var membership models.Membership
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&invitation, "token_hash = ? AND accepted_at IS NULL", hashToken(input.Token)).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Invitation not found")
+		}
+		if time.Now().After(invitation.ExpiresAt) {
+			return fiber.NewError(fiber.StatusNotFound, "Invitation has expired")
+		}
+		var user models.User
+		if err := tx.First(&user, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, invitation.Email) {
+			return fiber.NewError(fiber.StatusForbidden, "This invitation is for another email address")
+		}
+		var existing int64
+		if err := tx.Model(&models.Membership{}).Where("org_id = ? AND user_id = ?", invitation.OrgID, userID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Already a member of this organization")
+		}
+		membership = models.Membership{OrgID: invitation.OrgID, UserID: userID, Role: invitation.Role}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		if err := auditMembership(tx, "membership.create", nil, &membership, nil); err != nil {
+			return err
+		}
+		return tx.Model(&invitation).Update("accepted_at", time.Now()).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+orgFile, zap.String("Function", "AcceptInvitation"), zap.String("Message", "Failed to accept invitation"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+orgFile, zap.String("Function", "AcceptInvitation"), zap.String("Message", "Invitation accepted"), zap.String("org_id", membership.OrgID.String()), zap.String("user_id", userID.String()))
+	return c.JSON(membership)
+}
@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const pickListFile = "PickListController"
+
+// binSlot is a bin's remaining stock of one product while a pick list is
+// being built.
+type binSlot struct {
+	models.Bin
+	Quantity int
+}
+
+// pickStop is a pick list line together with the bin it is taken from, for
+// sorting along the walk.
+type pickStop struct {
+	line models.PickListLine
+	bin  *models.Bin
+}
+
+// findOrgPickList loads a pick list and its lines by the :id path parameter, scoped to the caller's organization.
+func findOrgPickList(db *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.PickList, error) {
+	pickListID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid pick list ID format")
+	}
+	var pickList models.PickList
+	if err := db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&pickList, "id = ? AND org_id = ?", pickListID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Pick list not found")
+	}
+	return &pickList, nil
+}
+
+// CreatePickList godoc
+// @Summary      Generate a pick list
+// @Description  Batches confirmed, fully reserved sales orders into one pick list. Each order line is taken from the bins holding the product, sharing bin stock across the batch, and the stops are sorted by walking path through the bins. Units not recorded in any bin are listed last without a bin. Bundle kits reserved from components are picked as their components.
+// @Tags         Bins
+// @Accept       json
+// @Produce      json
+// @Param        picklist  body      models.PickListRequest  true  "Orders to pick"
+// @Success      201       {object}  models.PickList
+// @Failure      400       {object}  map[string]string  "Invalid input"
+// @Failure      404       {object}  map[string]string  "Sales order not found"
+// @Failure      409       {object}  map[string]string  "Order not pickable or already on an open pick list"
+// @Security     BearerAuth
+// @Router       /pick-lists [post]
+func CreatePickList(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.PickListRequest
+	if err := c.BodyParser(&input); err != nil || len(input.SalesOrderIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "sales_order_ids is required"})
+	}
+
+	pickList := models.PickList{UserID: userID, OrgID: orgID, Status: models.PickListStatusOpen}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var orders []models.SalesOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").
+			Where("id IN ? AND org_id = ?", input.SalesOrderIDs, orgID).
+			Order("priority DESC, confirmed_at ASC").Find(&orders).Error; err != nil {
+			return err
+		}
+		if len(orders) != len(input.SalesOrderIDs) {
+			return fiber.NewError(fiber.StatusNotFound, "One or more sales orders not found")
+		}
+
+		slots := make(map[uuid.UUID][]*binSlot)
+		binSlots := func(productID uuid.UUID) ([]*binSlot, error) {
+			if s, ok := slots[productID]; ok {
+				return s, nil
+			}
+			var found []*binSlot
+			err := tx.Table("bins").Select("bins.*, s.quantity").
+				Joins("JOIN bin_stocks s ON s.bin_id = bins.id").
+				Where("bins.org_id = ? AND s.product_id = ? AND s.quantity > 0", orgID, productID).
+				Order(binPathOrder).Scan(&found).Error
+			slots[productID] = found
+			return found, err
+		}
+
+		var stops []pickStop
+		take := func(order *models.SalesOrder, line *models.SalesOrderLine, productID uuid.UUID, qty int) error {
+			found, err := binSlots(productID)
+			if err != nil {
+				return err
+			}
+			for _, slot := range found {
+				if qty == 0 {
+					break
+				}
+				n := min(qty, slot.Quantity)
+				if n == 0 {
+					continue
+				}
+				slot.Quantity -= n
+				qty -= n
+				stops = append(stops, pickStop{
+					line: models.PickListLine{BinID: &slot.ID, BinCode: slot.Code, ProductID: productID, SalesOrderID: order.ID, SalesOrderLineID: line.ID, Quantity: n},
+					bin:  &slot.Bin,
+				})
+			}
+			if qty > 0 {
+				stops = append(stops, pickStop{
+					line: models.PickListLine{ProductID: productID, SalesOrderID: order.ID, SalesOrderLineID: line.ID, Quantity: qty},
+				})
+			}
+			return nil
+		}
+
+		for i := range orders {
+			order := &orders[i]
+			if order.Status != models.SOStatusConfirmed {
+				return fiber.NewError(fiber.StatusConflict, "Sales order "+order.ID.String()+" is "+order.Status+", not confirmed")
+			}
+			for _, line := range order.Lines {
+				if line.BackorderedQuantity > 0 {
+					return fiber.NewError(fiber.StatusConflict, "Sales order "+order.ID.String()+" has backordered lines still waiting for stock")
+				}
+			}
+			var onList int64
+			if err := tx.Model(&models.PickListLine{}).
+				Joins("JOIN pick_lists p ON p.id = pick_list_lines.pick_list_id").
+				Where("pick_list_lines.sales_order_id = ? AND p.status = ?", order.ID, models.PickListStatusOpen).
+				Count(&onList).Error; err != nil {
+				return err
+			}
+			if onList > 0 {
+				return fiber.NewError(fiber.StatusConflict, "Sales order "+order.ID.String()+" is already on an open pick list")
+			}
+
+			for j := range order.Lines {
+				line := &order.Lines[j]
+				if own := line.ReservedQuantity - line.KitReservedQuantity; own > 0 {
+					if err := take(order, line, line.ProductID, own); err != nil {
+						return err
+					}
+				}
+				if line.KitReservedQuantity > 0 {
+					components, err := bundleComponents(tx, line.ProductID)
+					if err != nil {
+						return err
+					}
+					for _, component := range components {
+						if err := take(order, line, component.ComponentID, line.KitReservedQuantity*component.Quantity); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		if len(stops) == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Nothing to pick")
+		}
+
+		// Walk the bins in path order; stock outside any bin comes last.
+		sort.SliceStable(stops, func(i, j int) bool {
+			a, b := stops[i].bin, stops[j].bin
+			switch {
+			case a == nil || b == nil:
+				return a != nil && b == nil
+			case a.Sequence != b.Sequence:
+				return a.Sequence < b.Sequence
+			case a.Aisle != b.Aisle:
+				return a.Aisle < b.Aisle
+			case a.Rack != b.Rack:
+				return a.Rack < b.Rack
+			case a.Shelf != b.Shelf:
+				return a.Shelf < b.Shelf
+			default:
+				return a.Code < b.Code
+			}
+		})
+		for i, stop := range stops {
+			stop.line.Position = i + 1
+			pickList.Lines = append(pickList.Lines, stop.line)
+		}
+		return tx.Create(&pickList).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+pickListFile, zap.String("Function", "CreatePickList"), zap.String("Message", "Failed to create pick list"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+pickListFile, zap.String("Function", "CreatePickList"), zap.String("Message", "Pick list created"), zap.String("pick_list_id", pickList.ID.String()), zap.Int("stops", len(pickList.Lines)))
+	return c.Status(fiber.StatusCreated).JSON(pickList)
+}
+
+// GetPickList godoc
+// @Summary      Get a pick list
+// @Description  Retrieves a pick list with its stops in walking order
+// @Tags         Bins
+// @Produce      json
+// @Param        id   path      string  true  "Pick list ID (UUID)"
+// @Success      200  {object}  models.PickList
+// @Failure      404  {object}  map[string]string  "Pick list not found"
+// @Security     BearerAuth
+// @Router       /pick-lists/{id} [get]
+func GetPickList(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	pickList, err := findOrgPickList(database.DB, c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(pickList)
+}
+
+// CompletePickList godoc
+// @Summary      Complete a pick list
+// @Description  Records that the pick list has been walked: marks each order on the list picked and takes its units out of their bins. Orders cancelled or picked in the meantime are skipped and their units stay binned.
+// @Tags         Bins
+// @Produce      json
+// @Param        id   path      string  true  "Pick list ID (UUID)"
+// @Success      200  {object}  models.PickList
+// @Failure      404  {object}  map[string]string  "Pick list not found"
+// @Failure      409  {object}  map[string]string  "Pick list already completed"
+// @Security     BearerAuth
+// @Router       /pick-lists/{id}/complete [post]
+func CompletePickList(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var pickList *models.PickList
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if pickList, err = findOrgPickList(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if pickList.Status != models.PickListStatusOpen {
+			return fiber.NewError(fiber.StatusConflict, "Pick list is already "+pickList.Status)
+		}
+
+		// Orders are settled first: only the units of orders that actually
+		// move to picked leave their bins.
+		var orderIDs []uuid.UUID
+		seen := make(map[uuid.UUID]bool)
+		for _, line := range pickList.Lines {
+			if !seen[line.SalesOrderID] {
+				seen[line.SalesOrderID] = true
+				orderIDs = append(orderIDs, line.SalesOrderID)
+			}
+		}
+		picked := make(map[uuid.UUID]bool)
+		for _, orderID := range orderIDs {
+			var order models.SalesOrder
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").First(&order, "id = ?", orderID).Error; err != nil {
+				return err
+			}
+			if !order.CanTransitionTo(models.SOStatusPicked) {
+				continue
+			}
+			if err := checkPickable(&order); err != nil {
+				return err
+			}
+			if err := tx.Model(&order).Updates(map[string]interface{}{
+				"status":                 models.SOStatusPicked,
+				"reservation_expires_at": nil,
+			}).Error; err != nil {
+				return err
+			}
+			picked[orderID] = true
+		}
+
+		for _, line := range pickList.Lines {
+			if line.BinID == nil || !picked[line.SalesOrderID] {
+				continue
+			}
+			var stock models.BinStock
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("bin_id = ? AND product_id = ?", *line.BinID, line.ProductID).Limit(1).Find(&stock).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.BinStock{}).Where("bin_id = ? AND product_id = ?", *line.BinID, line.ProductID).
+				Update("quantity", gorm.Expr("GREATEST(quantity - ?, 0)", line.Quantity)).Error; err != nil {
+				return err
+			}
+			if err := auditBinStock(tx, "stock.pick", orgID, line.ProductID, line.BinCode, stock.Quantity, max(stock.Quantity-line.Quantity, 0)); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		pickList.Status = models.PickListStatusCompleted
+		pickList.CompletedAt = &now
+		return tx.Model(pickList).Updates(map[string]interface{}{
+			"status":       pickList.Status,
+			"completed_at": pickList.CompletedAt,
+		}).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+pickListFile, zap.String("Function", "CompletePickList"), zap.String("Message", "Failed to complete pick list"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+pickListFile, zap.String("Function", "CompletePickList"), zap.String("Message", "Pick list completed"), zap.String("pick_list_id", pickList.ID.String()))
+	return c.JSON(pickList)
+}
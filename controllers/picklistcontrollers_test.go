@@ -0,0 +1,102 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestPickListWalkOrder(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	shirt := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Price: 10, Quantity: 10})
+	socks := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Socks", SKU: "SO-1", Price: 2, Quantity: 5})
+
+	bins := make(map[string]models.Bin)
+	// Created out of walking order; A-1 and A-2 share a sequence and sort by rack.
+	for _, in := range []models.BinRequest{
+		{Code: "C-1", Aisle: "C", Rack: "01", Sequence: 30},
+		{Code: "A-2", Aisle: "A", Rack: "02", Sequence: 10},
+		{Code: "B-1", Aisle: "B", Rack: "01", Sequence: 20},
+		{Code: "A-1", Aisle: "A", Rack: "01", Sequence: 10},
+	} {
+		var bin models.Bin
+		if status := testdb.Call(t, app, fiber.MethodPost, "/bins", bearer, in, &bin); status != fiber.StatusCreated {
+			t.Fatalf("create bin %s: status %d", in.Code, status)
+		}
+		bins[in.Code] = bin
+	}
+	stock := func(code string, product uuid.UUID, quantity int) int {
+		t.Helper()
+		path := "/bins/" + bins[code].ID.String() + "/stock/" + product.String()
+		return testdb.Call(t, app, fiber.MethodPut, path, bearer, models.BinStockRequest{Quantity: quantity}, nil)
+	}
+	for _, s := range []struct {
+		code     string
+		product  uuid.UUID
+		quantity int
+	}{{"C-1", shirt, 4}, {"A-2", shirt, 2}, {"A-1", shirt, 1}, {"B-1", socks, 2}} {
+		if status := stock(s.code, s.product, s.quantity); status != fiber.StatusOK {
+			t.Fatalf("stock %s: status %d", s.code, status)
+		}
+	}
+	if status := stock("B-1", shirt, 4); status != fiber.StatusConflict {
+		t.Errorf("binning more shirts than on hand: status %d, want 409", status)
+	}
+
+	shirts := newSalesOrder(t, app, bearer, shirt, 5)
+	pairs := newSalesOrder(t, app, bearer, socks, 3)
+	for _, order := range []models.SalesOrder{shirts, pairs} {
+		if status := salesOrderStep(t, app, bearer, order, "confirm"); status != fiber.StatusOK {
+			t.Fatalf("confirm: status %d", status)
+		}
+	}
+
+	var pickList models.PickList
+	request := models.PickListRequest{SalesOrderIDs: []uuid.UUID{shirts.ID, pairs.ID}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/pick-lists", bearer, request, &pickList); status != fiber.StatusCreated {
+		t.Fatalf("create pick list: status %d", status)
+	}
+	want := []struct {
+		code     string
+		quantity int
+	}{{"A-1", 1}, {"A-2", 2}, {"B-1", 2}, {"C-1", 2}, {"", 1}}
+	if len(pickList.Lines) != len(want) {
+		t.Fatalf("pick list has %d stops, want %d: %+v", len(pickList.Lines), len(want), pickList.Lines)
+	}
+	for i, line := range pickList.Lines {
+		if line.Position != i+1 || line.BinCode != want[i].code || line.Quantity != want[i].quantity {
+			t.Errorf("stop %d = %d from %q, want %d from %q", line.Position, line.Quantity, line.BinCode, want[i].quantity, want[i].code)
+		}
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/pick-lists", bearer, request, nil); status != fiber.StatusConflict {
+		t.Errorf("orders already on an open pick list: status %d, want 409", status)
+	}
+
+	if status := testdb.Call(t, app, fiber.MethodPost, "/pick-lists/"+pickList.ID.String()+"/complete", bearer, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("complete pick list: status %d", status)
+	}
+	for _, order := range []models.SalesOrder{shirts, pairs} {
+		if status := salesOrderStep(t, app, bearer, order, "pack"); status != fiber.StatusOK {
+			t.Errorf("packing a picked order: status %d", status)
+		}
+	}
+	var left []models.ProductBin
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products/"+shirt.String()+"/bins", bearer, nil, &left); status != fiber.StatusOK {
+		t.Fatalf("product bins: status %d", status)
+	}
+	if len(left) != 1 || left[0].Code != "C-1" || left[0].Quantity != 2 {
+		t.Errorf("shirt bins after picking = %+v, want only C-1 with 2", left)
+	}
+
+	var suggestion models.PutawaySuggestion
+	if status := testdb.Call(t, app, fiber.MethodGet, "/bins/putaway?product_id="+shirt.String()+"&quantity=3", bearer, nil, &suggestion); status != fiber.StatusOK {
+		t.Fatalf("putaway: status %d", status)
+	}
+	if suggestion.Code != "C-1" {
+		t.Errorf("putaway = %+v, want C-1, which already holds shirts", suggestion)
+	}
+}
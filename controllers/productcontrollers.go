@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
 	"github.com/lokesh2201013/database"
 	"github.com/lokesh2201013/models"
 	"go.uber.org/zap"
-	"fmt"
+	"gorm.io/gorm"
 )
 
 // ProductInsert godoc
@@ -38,6 +42,18 @@ func ProductInsert(c *fiber.Ctx) error {
 		)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product fields"})
 	}
+	// Bundles are made by setting components, not by creating the product.
+	product.IsBundle = false
+	// Orders reserve stock and returns quarantine it; neither is set directly.
+	product.Reserved = 0
+	product.Quarantined = 0
+	product.BaseUnit = strings.TrimSpace(product.BaseUnit)
+	if product.BaseUnit == "" {
+		product.BaseUnit = models.DefaultBaseUnit
+	}
+	if product.SerialTracked && product.Quantity > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Serial-tracked products start at zero; receive or adjust stock with serials"})
+	}
 	userIDStr, ok := c.Locals("userID").(string)
 if !ok {
 	logger.Log.Error("Package controllers File "+file,
@@ -63,8 +79,36 @@ if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 	product.UserID = userID
+	if product.OrgID, err = currentOrgID(c); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
 
-	if err := database.DB.Create(&product).Error; err != nil {
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, audit.Entry{Action: "product.create", EntityType: "product", EntityID: product.ID.String(), After: product}); err != nil {
+			return err
+		}
+		if product.Quantity == 0 {
+			return nil
+		}
+		movement := models.StockMovement{
+			ProductID: product.ID,
+			UserID:    userID,
+			Type:      models.MovementInitial,
+			Quantity:  product.Quantity,
+		}
+		if product.LotTracked {
+			lot := models.Lot{ProductID: product.ID, Quantity: product.Quantity}
+			if err := tx.Create(&lot).Error; err != nil {
+				return err
+			}
+			movement.LotID = &lot.ID
+		}
+		return tx.Create(&movement).Error
+	})
+	if err != nil {
 		logger.Log.Error("Package controllers File "+file,zap.String("Function", "ProductInsert"),zap.String("Message", "Database error while creating product"),zap.Error(err),)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving product"})
 	}
@@ -79,7 +123,7 @@ if err != nil {
 
 // UpdateQuantity godoc
 // @Summary      Update product quantity
-// @Description  Update the quantity of an existing product by ID
+// @Description  Update the quantity of an existing product by ID. The quantity may be given in one of the product's pack units, which is converted to the base unit.
 // @Tags         Products
 // @Accept       json
 // @Produce      json
@@ -88,6 +132,7 @@ if err != nil {
 // @Success      200    {object}  models.Product
 // @Failure      400    {object}  map[string]string "Invalid input"
 // @Failure      404    {object}  map[string]string "Product not found"
+// @Failure      409    {object}  map[string]string "Product frozen by an open stocktake, or quantity below what is reserved for orders"
 // @Failure      500    {object}  map[string]string "Internal server error"
 // @Security     BearerAuth
 // @Router       /products/{id}/quantity [put]
@@ -95,9 +140,7 @@ func UpdateQuantity(c *fiber.Ctx) error {
 	const file = "ProductController"
 	productID := c.Params("id")
 
-	var input struct {
-		Quantity int `json:"quantity"`
-	}
+	var input models.QuantityUpdateRequest
 
 	if err := c.BodyParser(&input); err != nil {
 		logger.Log.Error("Package controllers File "+file,zap.String("Function", "UpdateQuantity"),zap.String("Message", "Failed to parse input"),zap.Error(err),)
@@ -107,24 +150,90 @@ func UpdateQuantity(c *fiber.Ctx) error {
     	logger.Log.Error("Package controllers File "+file,zap.String("Function", "UpdateQuantity"),zap.String("Message", "Quantity is lees < 0"),zap.String("product_id", productID),)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Quantity invalid"})
 	}
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
 	var product models.Product
-	if err := database.DB.First(&product, "id = ?", productID).Error; err != nil {
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", productID, orgID).Error; err != nil {
 		logger.Log.Error("Package controllers File "+file,zap.String("Function", "UpdateQuantity"),zap.String("Message", "Product not found"),zap.String("product_id", productID),)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
 	}
    
-	product.Quantity = input.Quantity
-
-	if err := database.DB.Save(&product).Error; err != nil {
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		locked, err := lockProduct(tx, product.ID)
+		if err != nil {
+			return err
+		}
+		frozen, err := inOpenStocktake(tx, locked.ID)
+		if err != nil {
+			return err
+		}
+		if frozen {
+			return fiber.NewError(fiber.StatusConflict, "Product is being counted in an open stocktake")
+		}
+		target, err := toBaseUnits(tx, locked, input.Unit, input.Quantity)
+		if err != nil {
+			return err
+		}
+		delta := target - locked.Quantity
+		if delta == 0 {
+			product = *locked
+			return nil
+		}
+		userID, _ := currentUserID(c)
+		movement := &models.StockMovement{
+			ProductID: product.ID,
+			UserID:    userID,
+			Type:      models.MovementAdjustment,
+			Quantity:  delta,
+		}
+		if delta > 0 && locked.LotTracked && input.LotNumber != "" {
+			lot, err := findOrCreateLot(tx, product.ID, input.LotNumber, input.ExpiresAt)
+			if err != nil {
+				return err
+			}
+			movement.LotID = &lot.ID
+		}
+		if locked.SerialTracked {
+			if len(input.Serials) != max(delta, -delta) {
+				return fiber.NewError(fiber.StatusBadRequest, "Serial-tracked products need one serial per unit added or removed")
+			}
+			if delta > 0 {
+				err = registerSerials(tx, userID, product.ID, input.Serials, models.SerialEventAdjusted, "", "")
+			} else {
+				err = moveSerials(tx, userID, product.ID, input.Serials, models.SerialInStock, models.SerialEventAdjusted, "", func(unit *models.SerialNumber) {
+					unit.Status = models.SerialRemoved
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		updated, err := applyMovement(tx, movement)
+		if err != nil {
+			return err
+		}
+		product = *updated
+		return nil
+	})
+	if err != nil {
 		logger.Log.Error("Package controllers File "+file,
 			zap.String("Function", "UpdateQuantity"),
 			zap.String("Message", "Failed to update product"),
 			zap.Error(err),
 		)
+		if errors.Is(err, errInsufficientStock) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Quantity would fall below what is reserved for orders"})
+		}
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			return sendError(c, err)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product"})
 	}
 
-	logger.Log.Info("Package controllers File "+file,zap.String("Function", "UpdateQuantity"),zap.String("Message", "Product quantity updated"),zap.String("product_id", productID),zap.Int("new_quantity", input.Quantity),
+	logger.Log.Info("Package controllers File "+file,zap.String("Function", "UpdateQuantity"),zap.String("Message", "Product quantity updated"),zap.String("product_id", productID),zap.Int("new_quantity", product.Quantity),
 	)
 
 	return c.Status(fiber.StatusOK).JSON(product)
@@ -132,7 +241,7 @@ func UpdateQuantity(c *fiber.Ctx) error {
 
 // GetAllUserProduct godoc
 // @Summary      Get all user products
-// @Description  Get paginated list of products created by the authenticated user
+// @Description  Get paginated list of the products of the caller's active organization
 // @Tags         Products
 // @Produce      json
 // @Param        pagenum  query     int  false  "Page number (default: 1)"
@@ -144,36 +253,9 @@ func UpdateQuantity(c *fiber.Ctx) error {
 // @Router       /products [get]
 func GetAllUserProduct(c *fiber.Ctx) error {
 	const file = "ProductController"
-	userIDStr, ok := c.Locals("userID").(string)
-
-if !ok {
-	logger.Log.Error("Package controllers File "+file,
-		zap.String("Function", "ProductInsert"),
-		zap.String("Message", "userID not found or not a string"),
-	)
-	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
-}
-
-userID, err := uuid.Parse(userIDStr)
-
-if err != nil {
-	logger.Log.Error("Package controllers File "+file,
-		zap.String("Function", "ProductInsert"),
-		zap.String("Message", "Invalid UUID format in userID"),
-		zap.Error(err),
-	)
-	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID format"})
-}
-
-userido:=c.Locals("userID")
-	//userID, ok := c.Locals("userID").(uuid.UUID)
-	   fmt.Println("---------------> USERIDO",userido)
-	      fmt.Println("---------------> USERID",userID)
-
-		  if !ok {
-		logger.Log.Error("Package controllers File "+file,zap.String("Function", "GetAllUserProduct"),zap.String("Message", "Invalid user ID in context"),
-		)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
 	}
 
 	pageNumber := c.QueryInt("pagenum", 1)
@@ -185,16 +267,14 @@ userido:=c.Locals("userID")
 	offset := (pageNumber - 1) * limit
 
 	var products []models.Product
-	if err := database.DB.Where("user_id = ?", userID).
+	if err := database.DB.Where("org_id = ?", orgID).
 		Limit(limit).Offset(offset).
 		Find(&products).Error; err != nil {
-		logger.Log.Error("Package controllers File "+file,zap.String("Function", "GetAllUserProduct"),zap.String("Message", "Error retrieving products"),zap.String("user_id", userID.String()),zap.Error(err),)
+		logger.Log.Error("Package controllers File "+file,zap.String("Function", "GetAllUserProduct"),zap.String("Message", "Error retrieving products"),zap.String("org_id", orgID.String()),zap.Error(err),)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving products"})
 	}
 
-	logger.Log.Info("Package controllers File "+file,zap.String("Function", "GetAllUserProduct"),zap.String("Message", "Products retrieved successfully"),zap.String("user_id", userID.String()),zap.Int("count", len(products)),
+	logger.Log.Info("Package controllers File "+file,zap.String("Function", "GetAllUserProduct"),zap.String("Message", "Products retrieved successfully"),zap.String("org_id", orgID.String()),zap.Int("count", len(products)),
 	)
-	
-	fmt.Println(products)
 	return c.JSON(products)
 }
@@ -0,0 +1,458 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const purchaseFile = "PurchaseController"
+
+// findOrgPurchaseOrder loads a PO and its lines by the :id path parameter, scoped to the caller's organization.
+func findOrgPurchaseOrder(db *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.PurchaseOrder, error) {
+	poID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid purchase order ID format")
+	}
+	var po models.PurchaseOrder
+	if err := db.Preload("Lines").First(&po, "id = ? AND org_id = ?", poID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Purchase order not found")
+	}
+	return &po, nil
+}
+
+// buildPurchaseOrderLines validates requested lines against the caller's
+// products, converting pack quantities to base units and falling back to the
+// supplier's catalogue cost when no unit cost is given.
+func buildPurchaseOrderLines(orgID, supplierID uuid.UUID, input []models.PurchaseOrderLineRequest) ([]models.PurchaseOrderLine, error) {
+	if len(input) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "At least one line is required")
+	}
+	lines := make([]models.PurchaseOrderLine, 0, len(input))
+	for _, in := range input {
+		if in.Quantity <= 0 || in.UnitCost < 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Line quantity must be positive and unit_cost non-negative")
+		}
+		var product models.Product
+		if err := database.DB.First(&product, "id = ? AND org_id = ?", in.ProductID, orgID).Error; err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Product "+in.ProductID.String()+" not found")
+		}
+		quantity, err := toBaseUnits(database.DB, &product, in.Unit, in.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		unitCost := in.UnitCost
+		if unitCost == 0 {
+			var link models.SupplierProduct
+			if err := database.DB.Where("supplier_id = ? AND product_id = ?", supplierID, in.ProductID).First(&link).Error; err == nil {
+				unitCost = link.CostPrice
+			}
+		}
+		lines = append(lines, models.PurchaseOrderLine{
+			ProductID: in.ProductID,
+			Quantity:  quantity,
+			UnitCost:  unitCost,
+		})
+	}
+	return lines, nil
+}
+
+// CreatePurchaseOrder godoc
+// @Summary      Create a purchase order
+// @Description  Creates a draft purchase order for a supplier. Lines without a unit cost use the supplier's catalogue cost price. Line quantities may be ordered in a pack unit and are stored in base units; unit costs are per base unit.
+// @Tags         Purchasing
+// @Accept       json
+// @Produce      json
+// @Param        order  body      models.PurchaseOrderRequest  true  "Purchase order"
+// @Success      201    {object}  models.PurchaseOrder
+// @Failure      400    {object}  map[string]string  "Invalid input"
+// @Failure      401    {object}  map[string]string  "Unauthorized"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /purchase-orders [post]
+func CreatePurchaseOrder(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.PurchaseOrderRequest
+	if err := c.BodyParser(&input); err != nil {
+		logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", "CreatePurchaseOrder"), zap.String("Message", "Failed to parse request body"), zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, "id = ? AND org_id = ?", input.SupplierID, orgID).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier not found"})
+	}
+	lines, err := buildPurchaseOrderLines(orgID, supplier.ID, input.Lines)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	po := models.PurchaseOrder{
+		UserID:       userID,
+		OrgID:        orgID,
+		SupplierID:   supplier.ID,
+		Status:       models.POStatusDraft,
+		ExpectedDate: input.ExpectedDate,
+		Notes:        input.Notes,
+		Lines:        lines,
+	}
+	if err := database.DB.Create(&po).Error; err != nil {
+		logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", "CreatePurchaseOrder"), zap.String("Message", "Database error while creating purchase order"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving purchase order"})
+	}
+
+	logger.Log.Info("Package controllers File "+purchaseFile, zap.String("Function", "CreatePurchaseOrder"), zap.String("Message", "Purchase order created"), zap.String("purchase_order_id", po.ID.String()))
+	return c.Status(fiber.StatusCreated).JSON(po)
+}
+
+// GetPurchaseOrders godoc
+// @Summary      List purchase orders
+// @Description  Get paginated list of the authenticated user's purchase orders, optionally filtered by status
+// @Tags         Purchasing
+// @Produce      json
+// @Param        status   query     string  false  "Filter by status"
+// @Param        pagenum  query     int     false  "Page number (default: 1)"
+// @Param        limit    query     int     false  "Items per page (default: 10)"
+// @Success      200      {array}   models.PurchaseOrder
+// @Failure      401      {object}  map[string]string  "Unauthorized"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /purchase-orders [get]
+func GetPurchaseOrders(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	query := database.DB.Preload("Lines").Where("org_id = ?", orgID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.PurchaseOrder
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&orders).Error; err != nil {
+		logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", "GetPurchaseOrders"), zap.String("Message", "Error retrieving purchase orders"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving purchase orders"})
+	}
+	return c.JSON(orders)
+}
+
+// GetPurchaseOrder godoc
+// @Summary      Get a purchase order
+// @Description  Retrieves a purchase order with its lines
+// @Tags         Purchasing
+// @Produce      json
+// @Param        id   path      string  true  "Purchase order ID (UUID)"
+// @Success      200  {object}  models.PurchaseOrder
+// @Failure      404  {object}  map[string]string  "Purchase order not found"
+// @Security     BearerAuth
+// @Router       /purchase-orders/{id} [get]
+func GetPurchaseOrder(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	po, err := findOrgPurchaseOrder(database.DB, c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(po)
+}
+
+// UpdatePurchaseOrder godoc
+// @Summary      Update a draft purchase order
+// @Description  Replaces the expected date, notes and lines of a purchase order that is still a draft
+// @Tags         Purchasing
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string                       true  "Purchase order ID (UUID)"
+// @Param        order  body      models.PurchaseOrderRequest  true  "Purchase order"
+// @Success      200    {object}  models.PurchaseOrder
+// @Failure      400    {object}  map[string]string  "Invalid input"
+// @Failure      404    {object}  map[string]string  "Purchase order not found"
+// @Failure      409    {object}  map[string]string  "Purchase order is not a draft"
+// @Security     BearerAuth
+// @Router       /purchase-orders/{id} [put]
+func UpdatePurchaseOrder(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	var input models.PurchaseOrderRequest
+	if err := c.BodyParser(&input); err != nil {
+		logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", "UpdatePurchaseOrder"), zap.String("Message", "Failed to parse request body"), zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	// The draft check holds the PO's row lock so a concurrent submit cannot
+	// slip in between it and the line rewrite.
+	var po *models.PurchaseOrder
+	var lines []models.PurchaseOrderLine
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = findOrgPurchaseOrder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if po.Status != models.POStatusDraft {
+			return fiber.NewError(fiber.StatusConflict, "Only draft purchase orders can be edited")
+		}
+		if lines, err = buildPurchaseOrderLines(orgID, po.SupplierID, input.Lines); err != nil {
+			return err
+		}
+		if err := tx.Where("purchase_order_id = ?", po.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].PurchaseOrderID = po.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+		return tx.Model(po).Updates(map[string]interface{}{
+			"expected_date": input.ExpectedDate,
+			"notes":         input.Notes,
+		}).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", "UpdatePurchaseOrder"), zap.String("Message", "Failed to update purchase order"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+	po.Lines = lines
+	return c.JSON(po)
+}
+
+// transitionPurchaseOrder moves the PO at :id to status, stamping timestamp when non-empty.
+func transitionPurchaseOrder(c *fiber.Ctx, function, status, timestamp string) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	// Lock the PO so two transitions, or a transition and a receipt, cannot
+	// both pass the status check.
+	var po *models.PurchaseOrder
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = findOrgPurchaseOrder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if !po.CanTransitionTo(status) {
+			return fiber.NewError(fiber.StatusConflict, "Cannot move purchase order from "+po.Status+" to "+status)
+		}
+		updates := map[string]interface{}{"status": status}
+		if timestamp != "" {
+			updates[timestamp] = time.Now()
+		}
+		return tx.Model(po).Updates(updates).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", function), zap.String("Message", "Failed to update purchase order status"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+	po.Status = status
+
+	logger.Log.Info("Package controllers File "+purchaseFile, zap.String("Function", function), zap.String("Message", "Purchase order status changed"), zap.String("purchase_order_id", po.ID.String()), zap.String("status", status))
+	return c.JSON(po)
+}
+
+// SubmitPurchaseOrder godoc
+// @Summary      Submit a purchase order
+// @Description  Moves a draft purchase order to submitted so it can be received against
+// @Tags         Purchasing
+// @Produce      json
+// @Param        id   path      string  true  "Purchase order ID (UUID)"
+// @Success      200  {object}  models.PurchaseOrder
+// @Failure      404  {object}  map[string]string  "Purchase order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /purchase-orders/{id}/submit [post]
+func SubmitPurchaseOrder(c *fiber.Ctx) error {
+	return transitionPurchaseOrder(c, "SubmitPurchaseOrder", models.POStatusSubmitted, "submitted_at")
+}
+
+// ClosePurchaseOrder godoc
+// @Summary      Close a purchase order
+// @Description  Closes a received or partially received purchase order, accepting any under-delivery as final
+// @Tags         Purchasing
+// @Produce      json
+// @Param        id   path      string  true  "Purchase order ID (UUID)"
+// @Success      200  {object}  models.PurchaseOrder
+// @Failure      404  {object}  map[string]string  "Purchase order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /purchase-orders/{id}/close [post]
+func ClosePurchaseOrder(c *fiber.Ctx) error {
+	return transitionPurchaseOrder(c, "ClosePurchaseOrder", models.POStatusClosed, "closed_at")
+}
+
+// CancelPurchaseOrder godoc
+// @Summary      Cancel a purchase order
+// @Description  Cancels a draft or submitted purchase order that has not received any stock
+// @Tags         Purchasing
+// @Produce      json
+// @Param        id   path      string  true  "Purchase order ID (UUID)"
+// @Success      200  {object}  models.PurchaseOrder
+// @Failure      404  {object}  map[string]string  "Purchase order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /purchase-orders/{id}/cancel [post]
+func CancelPurchaseOrder(c *fiber.Ctx) error {
+	return transitionPurchaseOrder(c, "CancelPurchaseOrder", models.POStatusCancelled, "closed_at")
+}
+
+// ReceivePurchaseOrder godoc
+// @Summary      Receive stock against a purchase order
+// @Description  Records a delivery for one or more PO lines. Each received quantity is added to product stock with an inbound movement at the line's (or overridden) unit cost, all in one transaction. Quantities above or below what was ordered are accepted. Lot-tracked products need a lot number and optionally an expiry date; serial-tracked products need one serial per unit received. Quantities may be given in a pack unit and are converted to the base unit. The response suggests a bin to put each received product away in.
+// @Tags         Purchasing
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                              true  "Purchase order ID (UUID)"
+// @Param        receipt  body      models.ReceivePurchaseOrderRequest  true  "Received quantities per line"
+// @Success      200      {object}  models.PurchaseOrder
+// @Failure      400      {object}  map[string]string  "Invalid input"
+// @Failure      404      {object}  map[string]string  "Purchase order not found"
+// @Failure      409      {object}  map[string]string  "Purchase order cannot be received"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /purchase-orders/{id}/receive [post]
+func ReceivePurchaseOrder(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.ReceivePurchaseOrderRequest
+	if err := c.BodyParser(&input); err != nil || len(input.Lines) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var po *models.PurchaseOrder
+	received := make(map[uuid.UUID]int)
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if po, err = findOrgPurchaseOrder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if !po.CanTransitionTo(models.POStatusReceived) {
+			return fiber.NewError(fiber.StatusConflict, "Cannot receive a purchase order in status "+po.Status)
+		}
+
+		lines := make(map[uuid.UUID]*models.PurchaseOrderLine, len(po.Lines))
+		for i := range po.Lines {
+			lines[po.Lines[i].ID] = &po.Lines[i]
+		}
+		for _, in := range input.Lines {
+			line, ok := lines[in.LineID]
+			if !ok {
+				return fiber.NewError(fiber.StatusBadRequest, "Line "+in.LineID.String()+" is not on this purchase order")
+			}
+			if in.Quantity <= 0 || in.UnitCost < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "Received quantity must be positive and unit_cost non-negative")
+			}
+			unitCost := line.UnitCost
+			if in.UnitCost > 0 {
+				unitCost = in.UnitCost
+			}
+			var product models.Product
+			if err := tx.First(&product, "id = ?", line.ProductID).Error; err != nil {
+				return err
+			}
+			quantity, err := toBaseUnits(tx, &product, in.Unit, in.Quantity)
+			if err != nil {
+				return err
+			}
+			movement := &models.StockMovement{
+				ProductID: line.ProductID,
+				UserID:    userID,
+				Type:      models.MovementInbound,
+				Quantity:  quantity,
+				UnitCost:  unitCost,
+				Reference: "po:" + po.ID.String(),
+			}
+			if product.LotTracked {
+				if in.LotNumber == "" {
+					return fiber.NewError(fiber.StatusBadRequest, "lot_number is required for lot-tracked product "+product.SKU)
+				}
+				lot, err := findOrCreateLot(tx, product.ID, in.LotNumber, in.ExpiresAt)
+				if err != nil {
+					return err
+				}
+				movement.LotID = &lot.ID
+			}
+			if product.SerialTracked {
+				if len(in.Serials) != quantity {
+					return fiber.NewError(fiber.StatusBadRequest, "One serial per unit is required for serial-tracked product "+product.SKU)
+				}
+				if err := registerSerials(tx, userID, product.ID, in.Serials, models.SerialEventReceived, "", movement.Reference); err != nil {
+					return err
+				}
+			}
+			if _, err := applyMovement(tx, movement); err != nil {
+				return err
+			}
+			line.ReceivedQuantity += quantity
+			received[line.ProductID] += quantity
+			if err := tx.Model(line).Update("received_quantity", line.ReceivedQuantity).Error; err != nil {
+				return err
+			}
+		}
+
+		status := models.POStatusReceived
+		for _, line := range po.Lines {
+			if line.ReceivedQuantity < line.Quantity {
+				status = models.POStatusPartiallyReceived
+				break
+			}
+		}
+		po.Status = status
+		return tx.Model(po).Update("status", status).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", "ReceivePurchaseOrder"), zap.String("Message", "Failed to receive purchase order"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	for _, line := range po.Lines {
+		qty, ok := received[line.ProductID]
+		if !ok {
+			continue
+		}
+		delete(received, line.ProductID)
+		suggestion, err := suggestPutaway(database.DB, orgID, line.ProductID, qty)
+		if err != nil {
+			logger.Log.Error("Package controllers File "+purchaseFile, zap.String("Function", "ReceivePurchaseOrder"), zap.String("Message", "Failed to suggest putaway"), zap.Error(err))
+			continue
+		}
+		po.Putaway = append(po.Putaway, suggestion)
+	}
+
+	logger.Log.Info("Package controllers File "+purchaseFile, zap.String("Function", "ReceivePurchaseOrder"), zap.String("Message", "Purchase order received"), zap.String("purchase_order_id", po.ID.String()), zap.String("status", po.Status))
+	return c.JSON(po)
+}
@@ -0,0 +1,91 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+// newSupplier creates a supplier called name for the bearer's user.
+func newSupplier(t *testing.T, app *fiber.App, bearer, name string) models.Supplier {
+	t.Helper()
+	var supplier models.Supplier
+	if status := testdb.Call(t, app, fiber.MethodPost, "/suppliers", bearer, models.SupplierRequest{Name: name}, &supplier); status != fiber.StatusCreated {
+		t.Fatalf("create supplier: status %d", status)
+	}
+	return supplier
+}
+
+// productQuantity reads a product's on-hand quantity from the database.
+func productQuantity(t *testing.T, productID uuid.UUID) int {
+	t.Helper()
+	var product models.Product
+	if err := database.DB.First(&product, "id = ?", productID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return product.Quantity
+}
+
+func TestReceivePurchaseOrder(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	supplier := newSupplier(t, app, bearer, "Acme")
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Price: 10})
+	path := "/suppliers/" + supplier.ID.String() + "/products/" + product.String()
+	if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, models.SupplierProductRequest{CostPrice: 7.5}, nil); status != fiber.StatusOK {
+		t.Fatalf("link product: status %d", status)
+	}
+
+	var po models.PurchaseOrder
+	order := models.PurchaseOrderRequest{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLineRequest{{ProductID: product, Quantity: 10}}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/purchase-orders", bearer, order, &po); status != fiber.StatusCreated {
+		t.Fatalf("create purchase order: status %d", status)
+	}
+	if po.Status != models.POStatusDraft || po.Lines[0].UnitCost != 7.5 {
+		t.Fatalf("created %s with unit cost %v, want a draft at the catalogue cost 7.5", po.Status, po.Lines[0].UnitCost)
+	}
+	poPath := "/purchase-orders/" + po.ID.String()
+	receive := func(quantity int, unitCost float64) (models.PurchaseOrder, int) {
+		var received models.PurchaseOrder
+		body := models.ReceivePurchaseOrderRequest{Lines: []models.ReceiveLineRequest{{LineID: po.Lines[0].ID, Quantity: quantity, UnitCost: unitCost}}}
+		status := testdb.Call(t, app, fiber.MethodPost, poPath+"/receive", bearer, body, &received)
+		return received, status
+	}
+
+	if _, status := receive(4, 0); status != fiber.StatusConflict {
+		t.Errorf("receiving a draft: status %d, want 409", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, poPath+"/submit", bearer, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("submit: status %d", status)
+	}
+	received, status := receive(4, 0)
+	if status != fiber.StatusOK || received.Status != models.POStatusPartiallyReceived {
+		t.Fatalf("first delivery: status %d, PO %s; want 200 and partially_received", status, received.Status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, poPath+"/cancel", bearer, nil, nil); status != fiber.StatusConflict {
+		t.Errorf("cancelling after a delivery: status %d, want 409", status)
+	}
+	received, status = receive(7, 8)
+	if status != fiber.StatusOK || received.Status != models.POStatusReceived || received.Lines[0].ReceivedQuantity != 11 {
+		t.Fatalf("second delivery: status %d, PO %s with %d received; want 200, received and 11", status, received.Status, received.Lines[0].ReceivedQuantity)
+	}
+	if got := productQuantity(t, product); got != 11 {
+		t.Errorf("quantity = %d, want the 11 delivered", got)
+	}
+
+	var costs []float64
+	if err := database.DB.Model(&models.StockMovement{}).Where("reference = ?", "po:"+po.ID.String()).
+		Order("created_at").Pluck("unit_cost", &costs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(costs) != 2 || costs[0] != 7.5 || costs[1] != 8 {
+		t.Errorf("inbound movement costs = %v, want [7.5 8]", costs)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, poPath+"/close", bearer, nil, nil); status != fiber.StatusOK {
+		t.Errorf("close: status %d", status)
+	}
+}
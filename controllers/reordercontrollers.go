@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const reorderFile = "ReorderController"
+
+// openPOStatuses are the statuses whose outstanding quantities count as on order.
+var openPOStatuses = []string{models.POStatusDraft, models.POStatusSubmitted, models.POStatusPartiallyReceived}
+
+type reorderCandidate struct {
+	models.Product
+	OnOrder     int `gorm:"column:on_order"`
+	Backordered int `gorm:"column:backordered"`
+}
+
+// projected is the stock a candidate will have free once open purchase
+// orders arrive and reserved and backordered sales are filled from it.
+func (p reorderCandidate) projected() int {
+	return p.Quantity - p.Reserved + p.OnOrder - p.Backordered
+}
+
+// orderQuantity is how many units to buy for a candidate, before supplier rounding.
+func (p reorderCandidate) orderQuantity() int {
+	if p.ReorderQuantity > 0 {
+		return p.ReorderQuantity
+	}
+	return p.ReorderPoint - p.projected() + 1
+}
+
+// roundToSupplierTerms lifts qty to the supplier's minimum order quantity and
+// then up to a whole number of packs.
+func roundToSupplierTerms(qty int, link models.SupplierProduct) int {
+	if qty < link.MinOrderQty {
+		qty = link.MinOrderQty
+	}
+	if link.PackSize > 1 {
+		if rem := qty % link.PackSize; rem != 0 {
+			qty += link.PackSize - rem
+		}
+	}
+	return qty
+}
+
+// suggestPurchaseOrders builds one draft PO per supplier covering the
+// organization's products whose projected stock is at or below their
+// reorder point, recorded as created by createdBy. Bundles are never
+// bought; their components are reordered on their own rules.
+// Each product is sourced from its preferred supplier, or the cheapest linked
+// one when none is preferred; products with no supplier are returned as unsourced.
+func suggestPurchaseOrders(orgID, createdBy uuid.UUID) ([]models.PurchaseOrder, []models.Product, error) {
+	var candidates []reorderCandidate
+	err := database.DB.Raw(`
+		SELECT p.*, COALESCE(oo.on_order, 0) AS on_order, COALESCE(bo.backordered, 0) AS backordered
+		FROM products p
+		LEFT JOIN (
+			SELECT l.product_id, SUM(GREATEST(l.quantity - l.received_quantity, 0)) AS on_order
+			FROM purchase_order_lines l
+			JOIN purchase_orders po ON po.id = l.purchase_order_id
+			WHERE po.org_id = ? AND po.status IN ?
+			GROUP BY l.product_id
+		) oo ON oo.product_id = p.id
+		LEFT JOIN (
+			SELECT l.product_id, SUM(l.backordered_quantity) AS backordered
+			FROM sales_order_lines l
+			JOIN sales_orders o ON o.id = l.sales_order_id
+			WHERE o.org_id = ? AND o.status = ?
+			GROUP BY l.product_id
+		) bo ON bo.product_id = p.id
+		WHERE p.org_id = ? AND p.reorder_point > 0 AND NOT p.is_bundle
+		  AND p.quantity - p.reserved + COALESCE(oo.on_order, 0) - COALESCE(bo.backordered, 0) <= p.reorder_point
+		ORDER BY p.name`, orgID, openPOStatuses, orgID, models.SOStatusConfirmed, orgID).Scan(&candidates).Error
+	if err != nil || len(candidates) == 0 {
+		return nil, nil, err
+	}
+
+	productIDs := make([]uuid.UUID, len(candidates))
+	for i, p := range candidates {
+		productIDs[i] = p.ID
+	}
+	var links []models.SupplierProduct
+	if err := database.DB.Where("product_id IN ?", productIDs).
+		Order("preferred DESC, cost_price ASC").Find(&links).Error; err != nil {
+		return nil, nil, err
+	}
+	sources := make(map[uuid.UUID]models.SupplierProduct, len(candidates))
+	for _, link := range links {
+		if _, ok := sources[link.ProductID]; !ok {
+			sources[link.ProductID] = link
+		}
+	}
+
+	var orders []models.PurchaseOrder
+	bySupplier := make(map[uuid.UUID]int)
+	unsourced := []models.Product{}
+	for _, p := range candidates {
+		link, ok := sources[p.ID]
+		if !ok {
+			unsourced = append(unsourced, p.Product)
+			continue
+		}
+		idx, ok := bySupplier[link.SupplierID]
+		if !ok {
+			idx = len(orders)
+			bySupplier[link.SupplierID] = idx
+			orders = append(orders, models.PurchaseOrder{
+				UserID:     createdBy,
+				OrgID:      orgID,
+				SupplierID: link.SupplierID,
+				Status:     models.POStatusDraft,
+				Notes:      "Suggested from reorder rules",
+			})
+		}
+		orders[idx].Lines = append(orders[idx].Lines, models.PurchaseOrderLine{
+			ProductID: p.ID,
+			Quantity:  roundToSupplierTerms(p.orderQuantity(), link),
+			UnitCost:  link.CostPrice,
+		})
+	}
+	return orders, unsourced, nil
+}
+
+// SuggestPurchaseOrders godoc
+// @Summary      Suggest purchase orders from reorder rules
+// @Description  Finds products at or below their reorder point, counting stock as on hand minus reserved plus open purchase orders minus backorders, groups them by preferred supplier, applies minimum order quantities and pack sizes, and creates draft purchase orders. Bundles are skipped. With dry_run=true nothing is saved and the would-be orders are returned.
+// @Tags         Purchasing
+// @Produce      json
+// @Param        dry_run  query     bool  false  "Return the suggestions without creating purchase orders"
+// @Success      200      {object}  models.PurchaseSuggestion  "Dry run"
+// @Success      201      {object}  models.PurchaseSuggestion  "Draft purchase orders created"
+// @Failure      401      {object}  map[string]string  "Unauthorized"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /purchasing/suggest [post]
+func SuggestPurchaseOrders(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	dryRun := c.QueryBool("dry_run")
+
+	orders, unsourced, err := suggestPurchaseOrders(orgID, userID)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+reorderFile, zap.String("Function", "SuggestPurchaseOrders"), zap.String("Message", "Failed to build suggestions"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build suggestions"})
+	}
+	if orders == nil {
+		orders = []models.PurchaseOrder{}
+	}
+	if unsourced == nil {
+		unsourced = []models.Product{}
+	}
+
+	result := models.PurchaseSuggestion{DryRun: dryRun, Orders: orders, Unsourced: unsourced}
+	if dryRun || len(orders) == 0 {
+		return c.JSON(result)
+	}
+
+	if err := database.DB.Create(&result.Orders).Error; err != nil {
+		logger.Log.Error("Package controllers File "+reorderFile, zap.String("Function", "SuggestPurchaseOrders"), zap.String("Message", "Failed to create suggested purchase orders"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create purchase orders"})
+	}
+
+	logger.Log.Info("Package controllers File "+reorderFile, zap.String("Function", "SuggestPurchaseOrders"), zap.String("Message", "Suggested purchase orders created"), zap.Int("count", len(result.Orders)))
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+// UpdateReorderRule godoc
+// @Summary      Set a product's reorder rule
+// @Description  Sets the reorder point and reorder quantity used by low-stock reporting and purchase order suggestions
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string                     true  "Product ID (UUID)"
+// @Param        rule   body      models.ReorderRuleRequest  true  "Reorder rule"
+// @Success      200    {object}  models.Product
+// @Failure      400    {object}  map[string]string  "Invalid input"
+// @Failure      404    {object}  map[string]string  "Product not found"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /products/{id}/reorder-rule [put]
+func UpdateReorderRule(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var input models.ReorderRuleRequest
+	if err := c.BodyParser(&input); err != nil || input.ReorderPoint < 0 || input.ReorderQuantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	before := product
+	product.ReorderPoint = input.ReorderPoint
+	product.ReorderQuantity = input.ReorderQuantity
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Updates(map[string]interface{}{
+			"reorder_point":    input.ReorderPoint,
+			"reorder_quantity": input.ReorderQuantity,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{Action: "product.update", EntityType: "product", EntityID: product.ID.String(), Before: before, After: product})
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+reorderFile, zap.String("Function", "UpdateReorderRule"), zap.String("Message", "Failed to update reorder rule"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product"})
+	}
+	return c.JSON(product)
+}
+
+// StartPurchaseSuggestionJob periodically creates suggested draft purchase
+// orders for every organization with products at or below their reorder
+// point, on behalf of the organization's creator.
+func StartPurchaseSuggestionJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runPurchaseSuggestions()
+		}
+	}()
+}
+
+func runPurchaseSuggestions() {
+	var orgs []models.Organization
+	if err := database.DB.Where("id IN (?)", database.DB.Model(&models.Product{}).
+		Where("reorder_point > 0 AND quantity <= reorder_point").
+		Distinct().Select("org_id")).Find(&orgs).Error; err != nil {
+		logger.Log.Error("Package controllers File "+reorderFile, zap.String("Function", "runPurchaseSuggestions"), zap.String("Message", "Failed to list organizations to reorder for"), zap.Error(err))
+		return
+	}
+	for _, org := range orgs {
+		orders, _, err := suggestPurchaseOrders(org.ID, org.CreatedBy)
+		if err == nil && len(orders) > 0 {
+			err = database.DB.Create(&orders).Error
+		}
+		if err != nil {
+			logger.Log.Error("Package controllers File "+reorderFile, zap.String("Function", "runPurchaseSuggestions"), zap.String("Message", "Failed to suggest purchase orders"), zap.String("org_id", org.ID.String()), zap.Error(err))
+			continue
+		}
+		if len(orders) > 0 {
+			logger.Log.Info("Package controllers File "+reorderFile, zap.String("Function", "runPurchaseSuggestions"), zap.String("Message", "Suggested purchase orders created"), zap.String("org_id", org.ID.String()), zap.Int("count", len(orders)))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/lokesh2201013/models"
+)
+
+func TestOrderQuantity(t *testing.T) {
+	tests := []struct {
+		name string
+		p    reorderCandidate
+		want int
+	}{
+		{"reorder quantity set", reorderCandidate{Product: models.Product{Quantity: 2, ReorderPoint: 10, ReorderQuantity: 50}}, 50},
+		{"just clears the reorder point", reorderCandidate{Product: models.Product{Quantity: 2, ReorderPoint: 10}}, 9},
+		{"counts stock on order", reorderCandidate{Product: models.Product{Quantity: 2, ReorderPoint: 10}, OnOrder: 5}, 4},
+		{"at the reorder point", reorderCandidate{Product: models.Product{Quantity: 10, ReorderPoint: 10}}, 1},
+		{"reserved stock is not available", reorderCandidate{Product: models.Product{Quantity: 10, Reserved: 6, ReorderPoint: 10}}, 7},
+		{"backorders are owed first", reorderCandidate{Product: models.Product{Quantity: 4, Reserved: 4, ReorderPoint: 10}, OnOrder: 5, Backordered: 3}, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.orderQuantity(); got != tt.want {
+				t.Errorf("orderQuantity = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundToSupplierTerms(t *testing.T) {
+	tests := []struct {
+		name     string
+		qty      int
+		min      int
+		packSize int
+		want     int
+	}{
+		{"no terms", 7, 1, 1, 7},
+		{"below the minimum", 3, 10, 1, 10},
+		{"above the minimum", 15, 10, 1, 15},
+		{"rounded up to whole packs", 13, 1, 12, 24},
+		{"already whole packs", 24, 1, 12, 24},
+		{"minimum then packs", 3, 10, 6, 12},
+		{"zero pack size", 5, 1, 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := models.SupplierProduct{MinOrderQty: tt.min, PackSize: tt.packSize}
+			if got := roundToSupplierTerms(tt.qty, link); got != tt.want {
+				t.Errorf("roundToSupplierTerms(%d) = %d, want %d", tt.qty, got, tt.want)
+			}
+		})
+	}
+}
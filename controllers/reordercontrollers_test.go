@@ -0,0 +1,84 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestSuggestPurchaseOrders(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	acme := newSupplier(t, app, bearer, "Acme")
+	globex := newSupplier(t, app, bearer, "Globex")
+
+	low := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Low", SKU: "LO-1", Price: 1, Quantity: 2, ReorderPoint: 5})
+	unsourced := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Unsourced", SKU: "UN-1", Price: 1, Quantity: 3, ReorderPoint: 3})
+	stocked := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Stocked", SKU: "ST-1", Price: 1, Quantity: 10, ReorderPoint: 5})
+	onOrder := testdb.CreateProduct(t, app, bearer, models.Product{Name: "On order", SKU: "OO-1", Price: 1, Quantity: 1, ReorderPoint: 5})
+	sold := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Sold", SKU: "SO-1", Price: 1, Quantity: 8, ReorderPoint: 5})
+	kit := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Kit", SKU: "KT-1", Price: 1, ReorderPoint: 5})
+	link := func(supplier models.Supplier, product uuid.UUID, terms models.SupplierProductRequest) {
+		t.Helper()
+		path := "/suppliers/" + supplier.ID.String() + "/products/" + product.String()
+		if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, terms, nil); status != fiber.StatusOK {
+			t.Fatalf("link product: status %d", status)
+		}
+	}
+	// Globex is cheaper, but Acme is preferred.
+	link(acme, low, models.SupplierProductRequest{CostPrice: 3, MinOrderQty: 10, PackSize: 12, Preferred: true})
+	link(globex, low, models.SupplierProductRequest{CostPrice: 2})
+	link(globex, stocked, models.SupplierProductRequest{CostPrice: 2})
+	link(globex, onOrder, models.SupplierProductRequest{CostPrice: 2})
+	link(globex, sold, models.SupplierProductRequest{CostPrice: 2})
+	link(globex, kit, models.SupplierProductRequest{CostPrice: 2})
+	order := models.PurchaseOrderRequest{SupplierID: globex.ID, Lines: []models.PurchaseOrderLineRequest{{ProductID: onOrder, Quantity: 6}}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/purchase-orders", bearer, order, nil); status != fiber.StatusCreated {
+		t.Fatalf("create purchase order: status %d", status)
+	}
+	// Sold has 8 on hand, all of them reserved and 2 more backordered.
+	if status := salesOrderStep(t, app, bearer, newSalesOrder(t, app, bearer, sold, 10), "confirm"); status != fiber.StatusOK {
+		t.Fatalf("confirm: status %d", status)
+	}
+	// Kit is a bundle, so its components are bought instead.
+	components := models.BundleRequest{Components: []models.BundleComponentRequest{{ProductID: stocked, Quantity: 1}}}
+	if status := testdb.Call(t, app, fiber.MethodPut, "/products/"+kit.String()+"/components", bearer, components, nil); status != fiber.StatusOK {
+		t.Fatalf("set components: status %d", status)
+	}
+
+	var suggestion models.PurchaseSuggestion
+	if status := testdb.Call(t, app, fiber.MethodPost, "/purchasing/suggest?dry_run=true", bearer, nil, &suggestion); status != fiber.StatusOK {
+		t.Fatalf("dry run: status %d", status)
+	}
+	if len(suggestion.Orders) != 2 || suggestion.Orders[0].SupplierID != acme.ID || suggestion.Orders[1].SupplierID != globex.ID {
+		t.Fatalf("orders = %+v, want one for Acme and one for Globex", suggestion.Orders)
+	}
+	// 4 clears the reorder point of 5, lifted to the minimum of 10 and a whole pack of 12.
+	if lines := suggestion.Orders[0].Lines; len(lines) != 1 || lines[0].ProductID != low || lines[0].Quantity != 12 || lines[0].UnitCost != 3 {
+		t.Errorf("Acme lines = %+v, want 12 of Low at 3", lines)
+	}
+	// Sold projects to -2 once its reservations and backorder are filled, so 8 clears the reorder point.
+	if lines := suggestion.Orders[1].Lines; len(lines) != 1 || lines[0].ProductID != sold || lines[0].Quantity != 8 {
+		t.Errorf("Globex lines = %+v, want only 8 of Sold", lines)
+	}
+	if len(suggestion.Unsourced) != 1 || suggestion.Unsourced[0].ID != unsourced {
+		t.Errorf("unsourced = %+v, want only Unsourced", suggestion.Unsourced)
+	}
+
+	if status := testdb.Call(t, app, fiber.MethodPost, "/purchasing/suggest", bearer, nil, &suggestion); status != fiber.StatusCreated {
+		t.Fatalf("suggest: status %d", status)
+	}
+	if len(suggestion.Orders) != 2 || suggestion.Orders[0].ID == uuid.Nil || suggestion.Orders[0].Status != models.POStatusDraft {
+		t.Fatalf("orders = %+v, want two saved drafts", suggestion.Orders)
+	}
+	// Low and Sold are now on order, so they are not suggested again.
+	if status := testdb.Call(t, app, fiber.MethodPost, "/purchasing/suggest?dry_run=true", bearer, nil, &suggestion); status != fiber.StatusOK {
+		t.Fatalf("dry run: status %d", status)
+	}
+	if len(suggestion.Orders) != 0 {
+		t.Errorf("orders after suggesting = %+v, want none", suggestion.Orders)
+	}
+}
@@ -0,0 +1,505 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const returnFile = "ReturnController"
+
+// findOrgReturn loads an RMA by the :id path parameter, scoped to the caller's organization.
+func findOrgReturn(db *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.ReturnAuthorization, error) {
+	rmaID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid return ID format")
+	}
+	var rma models.ReturnAuthorization
+	if err := db.First(&rma, "id = ? AND org_id = ?", rmaID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Return not found")
+	}
+	return &rma, nil
+}
+
+// returnStep runs step against the locked RMA at :id inside a transaction,
+// then saves it in status.
+func returnStep(c *fiber.Ctx, function, status string, step func(tx *gorm.DB, rma *models.ReturnAuthorization, userID uuid.UUID) error) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var rma *models.ReturnAuthorization
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if rma, err = findOrgReturn(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if !rma.CanTransitionTo(status) {
+			return fiber.NewError(fiber.StatusConflict, "Cannot move return from "+rma.Status+" to "+status)
+		}
+		if step != nil {
+			if err := step(tx, rma, userID); err != nil {
+				return err
+			}
+		}
+		rma.Status = status
+		return tx.Save(rma).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+returnFile, zap.String("Function", function), zap.String("Message", "Failed to update return"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+returnFile, zap.String("Function", function), zap.String("Message", "Return status changed"), zap.String("return_id", rma.ID.String()), zap.String("status", status))
+	return c.JSON(rma)
+}
+
+// CreateReturn godoc
+// @Summary      Authorise a customer return
+// @Description  Creates an RMA against a shipped sales order line. The quantity cannot exceed what was shipped on the line less what is already being returned.
+// @Tags         Returns
+// @Accept       json
+// @Produce      json
+// @Param        rma  body      models.ReturnRequest  true  "Return request"
+// @Success      201  {object}  models.ReturnAuthorization
+// @Failure      400  {object}  map[string]string  "Invalid input or quantity exceeds shipped"
+// @Failure      404  {object}  map[string]string  "Order line not found"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /returns [post]
+func CreateReturn(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.ReturnRequest
+	if err := c.BodyParser(&input); err != nil || input.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var rma models.ReturnAuthorization
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var line models.SalesOrderLine
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "sales_order_lines"}}).
+			Joins("JOIN sales_orders o ON o.id = sales_order_lines.sales_order_id").
+			Where("sales_order_lines.id = ? AND o.org_id = ?", input.SalesOrderLineID, orgID).
+			First(&line).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Sales order line not found")
+		}
+
+		var alreadyReturned int64
+		if err := tx.Model(&models.ReturnAuthorization{}).
+			Where("sales_order_line_id = ? AND status <> ?", line.ID, models.RMAStatusCancelled).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&alreadyReturned).Error; err != nil {
+			return err
+		}
+		if int64(input.Quantity) > int64(line.ShippedQuantity)-alreadyReturned {
+			return fiber.NewError(fiber.StatusBadRequest, "Return quantity exceeds shipped quantity not yet returned")
+		}
+
+		rma = models.ReturnAuthorization{
+			UserID:           userID,
+			OrgID:            orgID,
+			SalesOrderID:     line.SalesOrderID,
+			SalesOrderLineID: line.ID,
+			ProductID:        line.ProductID,
+			Quantity:         input.Quantity,
+			Reason:           input.Reason,
+			Status:           models.RMAStatusAuthorized,
+		}
+		return tx.Create(&rma).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+returnFile, zap.String("Function", "CreateReturn"), zap.String("Message", "Database error while creating return"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+returnFile, zap.String("Function", "CreateReturn"), zap.String("Message", "Return authorised"), zap.String("return_id", rma.ID.String()))
+	return c.Status(fiber.StatusCreated).JSON(rma)
+}
+
+// GetReturns godoc
+// @Summary      List returns
+// @Description  Get paginated list of the authenticated user's RMAs, optionally filtered by status or disposition
+// @Tags         Returns
+// @Produce      json
+// @Param        status       query     string  false  "Filter by status"
+// @Param        disposition  query     string  false  "Filter by disposition"
+// @Param        pagenum      query     int     false  "Page number (default: 1)"
+// @Param        limit        query     int     false  "Items per page (default: 10)"
+// @Success      200          {array}   models.ReturnAuthorization
+// @Failure      401          {object}  map[string]string  "Unauthorized"
+// @Failure      500          {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /returns [get]
+func GetReturns(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	query := database.DB.Where("org_id = ?", orgID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if disposition := c.Query("disposition"); disposition != "" {
+		query = query.Where("disposition = ?", disposition)
+	}
+
+	var returns []models.ReturnAuthorization
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&returns).Error; err != nil {
+		logger.Log.Error("Package controllers File "+returnFile, zap.String("Function", "GetReturns"), zap.String("Message", "Error retrieving returns"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving returns"})
+	}
+	return c.JSON(returns)
+}
+
+// GetReturn godoc
+// @Summary      Get a return
+// @Description  Retrieves an RMA by ID
+// @Tags         Returns
+// @Produce      json
+// @Param        id   path      string  true  "Return ID (UUID)"
+// @Success      200  {object}  models.ReturnAuthorization
+// @Failure      404  {object}  map[string]string  "Return not found"
+// @Security     BearerAuth
+// @Router       /returns/{id} [get]
+func GetReturn(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	rma, err := findOrgReturn(database.DB, c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(rma)
+}
+
+// ReceiveReturn godoc
+// @Summary      Receive returned goods
+// @Description  Records that the returned items have arrived. Stock is not changed until a disposition is chosen.
+// @Tags         Returns
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                       true  "Return ID (UUID)"
+// @Param        receipt  body      models.ReturnReceiveRequest  false "Received quantity and, for serial-tracked products, the returned serials"
+// @Success      200      {object}  models.ReturnAuthorization
+// @Failure      400      {object}  map[string]string  "Invalid quantity"
+// @Failure      404      {object}  map[string]string  "Return not found"
+// @Failure      409      {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /returns/{id}/receive [post]
+func ReceiveReturn(c *fiber.Ctx) error {
+	var input models.ReturnReceiveRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+	return returnStep(c, "ReceiveReturn", models.RMAStatusReceived, func(tx *gorm.DB, rma *models.ReturnAuthorization, userID uuid.UUID) error {
+		qty := input.Quantity
+		if qty == 0 {
+			qty = rma.Quantity
+		}
+		if qty < 0 || qty > rma.Quantity {
+			return fiber.NewError(fiber.StatusBadRequest, "Received quantity must be between 1 and the authorised quantity")
+		}
+		var product models.Product
+		if err := tx.First(&product, "id = ?", rma.ProductID).Error; err != nil {
+			return err
+		}
+		if product.SerialTracked {
+			if len(input.Serials) != qty {
+				return fiber.NewError(fiber.StatusBadRequest, "One serial per returned unit is required for serial-tracked product "+product.SKU)
+			}
+			if err := moveSerials(tx, userID, rma.ProductID, input.Serials, models.SerialSold, models.SerialEventReturned, "rma:"+rma.ID.String(), func(unit *models.SerialNumber) {
+				unit.Status = models.SerialReturned
+				unit.ReturnAuthorizationID = &rma.ID
+			}); err != nil {
+				return err
+			}
+			var foreign int64
+			if err := tx.Model(&models.SerialNumber{}).
+				Where("return_authorization_id = ? AND (sales_order_line_id IS NULL OR sales_order_line_id <> ?)", rma.ID, rma.SalesOrderLineID).
+				Count(&foreign).Error; err != nil {
+				return err
+			}
+			if foreign > 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "Returned serials were not shipped on this order line")
+			}
+		}
+		now := time.Now()
+		rma.ReceivedQuantity = qty
+		rma.ReceivedAt = &now
+		return nil
+	})
+}
+
+// InspectReturn godoc
+// @Summary      Inspect returned goods
+// @Description  Records the condition of the received items
+// @Tags         Returns
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string                       true  "Return ID (UUID)"
+// @Param        inspection  body      models.ReturnInspectRequest  true  "Inspection result"
+// @Success      200         {object}  models.ReturnAuthorization
+// @Failure      400         {object}  map[string]string  "Invalid input"
+// @Failure      404         {object}  map[string]string  "Return not found"
+// @Failure      409         {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /returns/{id}/inspect [post]
+func InspectReturn(c *fiber.Ctx) error {
+	var input models.ReturnInspectRequest
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Condition) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "condition is required"})
+	}
+	return returnStep(c, "InspectReturn", models.RMAStatusInspected, func(tx *gorm.DB, rma *models.ReturnAuthorization, _ uuid.UUID) error {
+		now := time.Now()
+		rma.Condition = strings.TrimSpace(input.Condition)
+		rma.InspectionNotes = input.Notes
+		rma.InspectedAt = &now
+		return nil
+	})
+}
+
+// DisposeReturn godoc
+// @Summary      Choose a disposition for inspected goods
+// @Description  restock adds the units back to product quantity with a return movement and write_off removes them from inventory; both complete the return. quarantine holds them at the given location in the product's quarantined count until they are released. Serials of returned units follow the disposition.
+// @Tags         Returns
+// @Accept       json
+// @Produce      json
+// @Param        id           path      string                           true  "Return ID (UUID)"
+// @Param        disposition  body      models.ReturnDispositionRequest  true  "Disposition"
+// @Success      200          {object}  models.ReturnAuthorization
+// @Failure      400          {object}  map[string]string  "Invalid disposition"
+// @Failure      404          {object}  map[string]string  "Return not found"
+// @Failure      409          {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /returns/{id}/dispose [post]
+func DisposeReturn(c *fiber.Ctx) error {
+	var input models.ReturnDispositionRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	input.Location = strings.TrimSpace(input.Location)
+	status := models.RMAStatusCompleted
+	switch input.Disposition {
+	case models.DispositionRestock, models.DispositionWriteOff:
+	case models.DispositionQuarantine:
+		if input.Location == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "location is required for quarantine"})
+		}
+		status = models.RMAStatusQuarantined
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "disposition must be restock, quarantine or write_off"})
+	}
+
+	return returnStep(c, "DisposeReturn", status, func(tx *gorm.DB, rma *models.ReturnAuthorization, userID uuid.UUID) error {
+		if err := disposeReturnedSerials(tx, rma, userID, models.SerialReturned, input.Disposition, input.Location); err != nil {
+			return err
+		}
+		switch input.Disposition {
+		case models.DispositionRestock:
+			if err := restockReturn(tx, rma, userID); err != nil {
+				return err
+			}
+		case models.DispositionQuarantine:
+			if err := changeQuarantined(tx, rma, "stock.quarantine", rma.ReceivedQuantity); err != nil {
+				return err
+			}
+			rma.Location = input.Location
+		}
+		rma.Disposition = input.Disposition
+		if status == models.RMAStatusCompleted {
+			now := time.Now()
+			rma.CompletedAt = &now
+		}
+		return nil
+	})
+}
+
+// ReleaseReturn godoc
+// @Summary      Release quarantined goods
+// @Description  Takes a quarantined return's units out of the product's quarantined count and either restocks them with a return movement or writes them off, completing the return. The choice is recorded as release_disposition; disposition stays quarantine. Serials follow.
+// @Tags         Returns
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                        true  "Return ID (UUID)"
+// @Param        release  body      models.ReturnReleaseRequest  true  "What becomes of the goods"
+// @Success      200      {object}  models.ReturnAuthorization
+// @Failure      400      {object}  map[string]string  "Invalid disposition"
+// @Failure      404      {object}  map[string]string  "Return not found"
+// @Failure      409      {object}  map[string]string  "Return is not in quarantine"
+// @Security     BearerAuth
+// @Router       /returns/{id}/release [post]
+func ReleaseReturn(c *fiber.Ctx) error {
+	var input models.ReturnReleaseRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Disposition != models.DispositionRestock && input.Disposition != models.DispositionWriteOff {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "disposition must be restock or write_off"})
+	}
+
+	return returnStep(c, "ReleaseReturn", models.RMAStatusCompleted, func(tx *gorm.DB, rma *models.ReturnAuthorization, userID uuid.UUID) error {
+		// Inspected returns may also complete, but only through DisposeReturn.
+		if rma.Status != models.RMAStatusQuarantined {
+			return fiber.NewError(fiber.StatusConflict, "Return is not in quarantine")
+		}
+		if err := disposeReturnedSerials(tx, rma, userID, models.SerialQuarantined, input.Disposition, ""); err != nil {
+			return err
+		}
+		if err := changeQuarantined(tx, rma, "stock.release", -rma.ReceivedQuantity); err != nil {
+			return err
+		}
+		if input.Disposition == models.DispositionRestock {
+			if err := restockReturn(tx, rma, userID); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		rma.ReleaseDisposition = input.Disposition
+		rma.ReleasedAt = &now
+		rma.CompletedAt = &now
+		return nil
+	})
+}
+
+// restockReturn puts an RMA's received units back into stock with a return
+// movement.
+func restockReturn(tx *gorm.DB, rma *models.ReturnAuthorization, userID uuid.UUID) error {
+	if rma.ReceivedQuantity == 0 {
+		return nil
+	}
+	_, err := applyMovement(tx, &models.StockMovement{
+		ProductID: rma.ProductID,
+		UserID:    userID,
+		Type:      models.MovementReturn,
+		Quantity:  rma.ReceivedQuantity,
+		Reference: "rma:" + rma.ID.String(),
+	})
+	return err
+}
+
+// changeQuarantined adds delta to the product's quarantined count for an
+// RMA's units and audits it as action.
+func changeQuarantined(tx *gorm.DB, rma *models.ReturnAuthorization, action string, delta int) error {
+	product, err := lockProduct(tx, rma.ProductID)
+	if err != nil {
+		return err
+	}
+	quarantined := max(product.Quarantined+delta, 0)
+	if err := tx.Model(product).Update("quarantined", quarantined).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, audit.Entry{
+		OrgID:      &product.OrgID,
+		Action:     action,
+		EntityType: "stock",
+		EntityID:   product.ID.String(),
+		Changes: map[string]models.AuditChange{
+			"quarantined": {Before: product.Quarantined, After: quarantined},
+			"reference":   {After: "rma:" + rma.ID.String()},
+		},
+	})
+}
+
+// CancelReturn godoc
+// @Summary      Cancel a return
+// @Description  Cancels an RMA whose goods have not been received
+// @Tags         Returns
+// @Produce      json
+// @Param        id   path      string  true  "Return ID (UUID)"
+// @Success      200  {object}  models.ReturnAuthorization
+// @Failure      404  {object}  map[string]string  "Return not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /returns/{id}/cancel [post]
+func CancelReturn(c *fiber.Ctx) error {
+	return returnStep(c, "CancelReturn", models.RMAStatusCancelled, nil)
+}
+
+// GetReturnReport godoc
+// @Summary      Return rates per product
+// @Description  For each product with shipments, reports units shipped, units returned, the return rate and how returned units were disposed of. Released quarantine counts as restocked or written off, so quarantined is what is still held.
+// @Tags         Returns
+// @Produce      json
+// @Success      200  {array}   models.ReturnRateReport
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /returns/report [get]
+func GetReturnReport(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	report := []models.ReturnRateReport{}
+	err = database.DB.Raw(`
+		WITH shipped AS (
+			SELECT l.product_id, SUM(l.shipped_quantity) AS shipped
+			FROM sales_order_lines l
+			JOIN sales_orders o ON o.id = l.sales_order_id
+			WHERE o.org_id = ? AND l.shipped_quantity > 0
+			GROUP BY l.product_id
+		), returned AS (
+			SELECT product_id,
+			       SUM(received_quantity) AS returned,
+			       SUM(received_quantity) FILTER (WHERE outcome = ?) AS restocked,
+			       SUM(received_quantity) FILTER (WHERE outcome = ?) AS quarantined,
+			       SUM(received_quantity) FILTER (WHERE outcome = ?) AS written_off
+			FROM (
+				SELECT *, COALESCE(NULLIF(release_disposition, ''), disposition) AS outcome
+				FROM return_authorizations
+			) r
+			WHERE org_id = ? AND status <> ?
+			GROUP BY product_id
+		)
+		SELECT p.id AS product_id, p.name, p.sku,
+		       s.shipped,
+		       COALESCE(r.returned, 0) AS returned,
+		       COALESCE(r.returned, 0)::float / s.shipped AS return_rate,
+		       COALESCE(r.restocked, 0) AS restocked,
+		       COALESCE(r.quarantined, 0) AS quarantined,
+		       COALESCE(r.written_off, 0) AS written_off
+		FROM shipped s
+		JOIN products p ON p.id = s.product_id
+		LEFT JOIN returned r ON r.product_id = s.product_id
+		ORDER BY return_rate DESC, p.name`,
+		orgID,
+		models.DispositionRestock, models.DispositionQuarantine, models.DispositionWriteOff,
+		orgID, models.RMAStatusCancelled).Scan(&report).Error
+	if err != nil {
+		logger.Log.Error("Package controllers File "+returnFile, zap.String("Function", "GetReturnReport"), zap.String("Message", "Error computing return report"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error computing return report"})
+	}
+	return c.JSON(report)
+}
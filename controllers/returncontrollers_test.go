@@ -0,0 +1,96 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestQuarantinedReturn(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Price: 10, Quantity: 5})
+	order := newSalesOrder(t, app, bearer, product, 3)
+	for _, step := range []string{"confirm", "pick", "pack", "ship"} {
+		if status := salesOrderStep(t, app, bearer, order, step); status != fiber.StatusOK {
+			t.Fatalf("%s: status %d", step, status)
+		}
+	}
+
+	request := models.ReturnRequest{SalesOrderLineID: order.Lines[0].ID, Quantity: 4, Reason: "Wrong size"}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/returns", bearer, request, nil); status != fiber.StatusBadRequest {
+		t.Errorf("returning more than shipped: status %d, want 400", status)
+	}
+	var rma models.ReturnAuthorization
+	request.Quantity = 2
+	if status := testdb.Call(t, app, fiber.MethodPost, "/returns", bearer, request, &rma); status != fiber.StatusCreated {
+		t.Fatalf("create return: status %d", status)
+	}
+	path := "/returns/" + rma.ID.String()
+	quarantine := models.ReturnDispositionRequest{Disposition: models.DispositionQuarantine}
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/dispose", bearer, quarantine, nil); status != fiber.StatusConflict {
+		t.Errorf("disposing before receipt: status %d, want 409", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/receive", bearer, models.ReturnReceiveRequest{}, nil); status != fiber.StatusOK {
+		t.Fatalf("receive: status %d", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/inspect", bearer, models.ReturnInspectRequest{Condition: "opened"}, nil); status != fiber.StatusOK {
+		t.Fatalf("inspect: status %d", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/dispose", bearer, quarantine, nil); status != fiber.StatusBadRequest {
+		t.Errorf("quarantine without a location: status %d, want 400", status)
+	}
+	quarantine.Location = "QUARANTINE-A"
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/dispose", bearer, quarantine, &rma); status != fiber.StatusOK || rma.Status != models.RMAStatusQuarantined {
+		t.Fatalf("quarantine: status %d, RMA %s; want 200 and quarantined", status, rma.Status)
+	}
+	if p := loadProduct(t, product); p.Quantity != 2 || p.Quarantined != 2 {
+		t.Errorf("in quarantine: quantity %d, quarantined %d; want 2 and 2", p.Quantity, p.Quarantined)
+	}
+	returnReport := func() models.ReturnRateReport {
+		t.Helper()
+		var report []models.ReturnRateReport
+		if status := testdb.Call(t, app, fiber.MethodGet, "/returns/report", bearer, nil, &report); status != fiber.StatusOK {
+			t.Fatalf("report: status %d", status)
+		}
+		if len(report) != 1 || report[0].Shipped != 3 || report[0].Returned != 2 {
+			t.Fatalf("report = %+v, want 2 of 3 returned", report)
+		}
+		return report[0]
+	}
+	if r := returnReport(); r.Quarantined != 2 || r.Restocked != 0 {
+		t.Errorf("report in quarantine = %+v, want 2 quarantined", r)
+	}
+
+	release := models.ReturnReleaseRequest{Disposition: models.DispositionRestock}
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/release", bearer, release, &rma); status != fiber.StatusOK || rma.Status != models.RMAStatusCompleted {
+		t.Fatalf("release: status %d, RMA %s; want 200 and completed", status, rma.Status)
+	}
+	if rma.Disposition != models.DispositionQuarantine || rma.ReleaseDisposition != models.DispositionRestock {
+		t.Errorf("released with disposition %q and release disposition %q, want quarantine and restock", rma.Disposition, rma.ReleaseDisposition)
+	}
+	if p := loadProduct(t, product); p.Quantity != 4 || p.Quarantined != 0 {
+		t.Errorf("after release: quantity %d, quarantined %d; want 4 and 0", p.Quantity, p.Quarantined)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/release", bearer, release, nil); status != fiber.StatusConflict {
+		t.Errorf("releasing twice: status %d, want 409", status)
+	}
+	// Released units count as restocked and are no longer held.
+	if r := returnReport(); r.Quarantined != 0 || r.Restocked != 2 {
+		t.Errorf("report after release = %+v, want 2 restocked", r)
+	}
+}
+
+// loadProduct reads a product from the database.
+func loadProduct(t *testing.T, productID uuid.UUID) models.Product {
+	t.Helper()
+	var product models.Product
+	if err := database.DB.First(&product, "id = ?", productID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const roleFile = "RoleController"
+
+// GetRoles godoc
+// @Summary      List roles
+// @Description  Lists the roles and the permissions each one grants
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {array}   models.RoleInfo
+// @Failure      403  {object}  map[string]string  "Missing permission users:manage"
+// @Security     BearerAuth
+// @Router       /admin/roles [get]
+func GetRoles(c *fiber.Ctx) error {
+	return c.JSON(models.Roles())
+}
+
+// GetUsers godoc
+// @Summary      List members and their roles
+// @Description  Get paginated list of the active organization's members with their roles, optionally filtered by role
+// @Tags         Admin
+// @Produce      json
+// @Param        role     query     string  false  "Filter by role"
+// @Param        pagenum  query     int     false  "Page number (default: 1)"
+// @Param        limit    query     int     false  "Items per page (default: 10)"
+// @Success      200      {array}   models.UserRole
+// @Failure      403      {object}  map[string]string  "Missing permission users:manage"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /admin/users [get]
+func GetUsers(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	query := database.DB.Table("memberships m").
+		Joins("JOIN users u ON u.user_id = m.user_id").
+		Where("m.org_id = ?", orgID)
+	if role := c.Query("role"); role != "" {
+		query = query.Where("m.role = ?", role)
+	}
+
+	users := []models.UserRole{}
+	if err := query.Select("u.user_id, u.username, u.email, m.role").Order("m.created_at DESC").
+		Limit(limit).Offset(offset).Scan(&users).Error; err != nil {
+		logger.Log.Error("Package controllers File "+roleFile, zap.String("Function", "GetUsers"), zap.String("Message", "Error retrieving users"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving users"})
+	}
+	return c.JSON(users)
+}
+
+// findOrgMembership locks the active organization's membership of the user
+// at :id.
+func findOrgMembership(tx *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.Membership, error) {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID format")
+	}
+	var membership models.Membership
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&membership, "org_id = ? AND user_id = ?", orgID, userID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "User is not a member of this organization")
+	}
+	return &membership, nil
+}
+
+// checkNotLastAdmin refuses to take away the organization's only admin.
+func checkNotLastAdmin(tx *gorm.DB, membership *models.Membership) error {
+	if membership.Role != models.RoleAdmin {
+		return nil
+	}
+	// Lock every admin so two demotions cannot both pass the check.
+	var admins []models.Membership
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("org_id = ? AND role = ?", membership.OrgID, models.RoleAdmin).Find(&admins).Error; err != nil {
+		return err
+	}
+	if len(admins) <= 1 {
+		return fiber.NewError(fiber.StatusConflict, "Cannot remove the organization's last admin")
+	}
+	return nil
+}
+
+// SetUserRole godoc
+// @Summary      Assign a role
+// @Description  Changes a member's role in the active organization. Their sessions in the organization are revoked so the new permissions apply immediately. The last admin cannot be demoted.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string              true  "User ID (UUID)"
+// @Param        role  body      models.RoleRequest  true  "New role"
+// @Success      200   {object}  models.Membership
+// @Failure      400   {object}  map[string]string  "Invalid role"
+// @Failure      403   {object}  map[string]string  "Missing permission users:manage"
+// @Failure      404   {object}  map[string]string  "Not a member"
+// @Failure      409   {object}  map[string]string  "Would leave no admin"
+// @Security     BearerAuth
+// @Router       /admin/users/{id}/role [put]
+func SetUserRole(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	var input models.RoleRequest
+	if err := c.BodyParser(&input); err != nil || !models.ValidRole(input.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role must be one of admin, manager, clerk, viewer"})
+	}
+
+	var membership *models.Membership
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if membership, err = findOrgMembership(tx, c, orgID); err != nil {
+			return err
+		}
+		if membership.Role == input.Role {
+			return nil
+		}
+		if input.Role != models.RoleAdmin {
+			if err := checkNotLastAdmin(tx, membership); err != nil {
+				return err
+			}
+		}
+		before := *membership
+		membership.Role = input.Role
+		if err := tx.Model(membership).Update("role", membership.Role).Error; err != nil {
+			return err
+		}
+		if err := auditMembership(tx, "membership.update", &before, membership, nil); err != nil {
+			return err
+		}
+		return revokeOrgSessions(tx, membership.UserID, orgID)
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+roleFile, zap.String("Function", "SetUserRole"), zap.String("Message", "Failed to change role"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	actorID, _ := currentUserID(c)
+	logger.Log.Info("Package controllers File "+roleFile, zap.String("Function", "SetUserRole"), zap.String("Message", "Role changed"), zap.String("user_id", membership.UserID.String()), zap.String("org_id", orgID.String()), zap.String("role", membership.Role), zap.String("by", actorID.String()))
+	return c.JSON(membership)
+}
+
+// RemoveMember godoc
+// @Summary      Remove a member
+// @Description  Removes a user from the active organization and revokes their sessions in it. The last admin cannot be removed.
+// @Tags         Admin
+// @Produce      json
+// @Param        id   path      string  true  "User ID (UUID)"
+// @Success      204
+// @Failure      404  {object}  map[string]string  "Not a member"
+// @Failure      409  {object}  map[string]string  "Would leave no admin"
+// @Security     BearerAuth
+// @Router       /admin/users/{id} [delete]
+func RemoveMember(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var membership *models.Membership
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if membership, err = findOrgMembership(tx, c, orgID); err != nil {
+			return err
+		}
+		if err := checkNotLastAdmin(tx, membership); err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ? AND user_id = ?", orgID, membership.UserID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		if err := auditMembership(tx, "membership.delete", membership, nil, nil); err != nil {
+			return err
+		}
+		return revokeOrgSessions(tx, membership.UserID, orgID)
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+roleFile, zap.String("Function", "RemoveMember"), zap.String("Message", "Failed to remove member"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+roleFile, zap.String("Function", "RemoveMember"), zap.String("Message", "Member removed"), zap.String("user_id", membership.UserID.String()), zap.String("org_id", orgID.String()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// UnlockUser godoc
+// @Summary      Unlock a member's login
+// @Description  Clears the failed login count of a member of the active organization, lifting any login delay or lockout on their account. Lockouts of IP addresses expire on their own.
+// @Tags         Admin
+// @Produce      json
+// @Param        id   path      string  true  "User ID (UUID)"
+// @Success      204
+// @Failure      403  {object}  map[string]string  "Missing permission users:manage"
+// @Failure      404  {object}  map[string]string  "Not a member"
+// @Security     BearerAuth
+// @Router       /admin/users/{id}/unlock [post]
+func UnlockUser(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var user models.User
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		membership, err := findOrgMembership(tx, c, orgID)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, "user_id = ?", membership.UserID).Error; err != nil {
+			return err
+		}
+		if err := clearAccountThrottle(tx, user.Username); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{Action: "user.unlock", EntityType: "user", EntityID: user.UserID.String()})
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+roleFile, zap.String("Function", "UnlockUser"), zap.String("Message", "Failed to unlock user"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	actorID, _ := currentUserID(c)
+	logger.Log.Info("Package controllers File "+roleFile, zap.String("Function", "UnlockUser"), zap.String("Message", "Login unlocked"), zap.String("user_id", user.UserID.String()), zap.String("by", actorID.String()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
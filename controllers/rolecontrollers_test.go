@@ -0,0 +1,100 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+// personalOrg returns the ID of user's personal organization.
+func personalOrg(t *testing.T, user *models.User) uuid.UUID {
+	t.Helper()
+	var org models.Organization
+	if err := database.DB.First(&org, "created_by = ? AND personal", user.UserID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return org.ID
+}
+
+// joinOrg invites member into the organization adminToken is active in with
+// role, accepts the invitation and returns member's token switched into it.
+func joinOrg(t *testing.T, app *fiber.App, adminToken string, orgID uuid.UUID, member *models.User, role string) string {
+	t.Helper()
+	var invitation models.CreatedInvitation
+	request := models.InvitationRequest{Email: member.Email, Role: role}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/admin/invitations", adminToken, request, &invitation); status != fiber.StatusCreated {
+		t.Fatalf("invite: status %d", status)
+	}
+	memberToken := testdb.Login(t, app, member.Username).AccessToken
+	accept := models.AcceptInvitationRequest{Token: invitation.Token}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/invitations/accept", memberToken, accept, nil); status != fiber.StatusOK {
+		t.Fatalf("accept: status %d", status)
+	}
+	return switchOrg(t, app, memberToken, orgID)
+}
+
+// switchOrg makes orgID active for bearer's session and returns the new token.
+func switchOrg(t *testing.T, app *fiber.App, bearer string, orgID uuid.UUID) string {
+	t.Helper()
+	var tokens models.TokenResponse
+	if status := testdb.Call(t, app, fiber.MethodPost, "/orgs/"+orgID.String()+"/switch", bearer, nil, &tokens); status != fiber.StatusOK {
+		t.Fatalf("switch: status %d", status)
+	}
+	return tokens.AccessToken
+}
+
+func TestSetUserRole(t *testing.T) {
+	app := testdb.App(t)
+	admin, adminToken := testdb.SignUp(t, app)
+	orgID := personalOrg(t, admin)
+
+	member, _ := testdb.SignUp(t, app)
+	memberToken := joinOrg(t, app, adminToken, orgID, member, models.RoleManager)
+	if status := testdb.Call(t, app, fiber.MethodGet, "/admin/roles", memberToken, nil, nil); status != fiber.StatusForbidden {
+		t.Errorf("manager listing roles: status %d, want 403", status)
+	}
+
+	path := "/admin/users/" + member.UserID.String() + "/role"
+	if status := testdb.Call(t, app, fiber.MethodPut, path, adminToken, models.RoleRequest{Role: "owner"}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("unknown role: status %d, want 400", status)
+	}
+	self := "/admin/users/" + admin.UserID.String() + "/role"
+	if status := testdb.Call(t, app, fiber.MethodPut, self, adminToken, models.RoleRequest{Role: models.RoleClerk}, nil); status != fiber.StatusConflict {
+		t.Errorf("demoting the last admin: status %d, want 409", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPut, path, adminToken, models.RoleRequest{Role: models.RoleClerk}, nil); status != fiber.StatusOK {
+		t.Fatalf("set role: status %d", status)
+	}
+	// The old token still claims manager, so the change signs the user out.
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products", memberToken, nil, nil); status != fiber.StatusUnauthorized {
+		t.Errorf("token from before the change: status %d, want 401", status)
+	}
+
+	clerkToken := switchOrg(t, app, testdb.Login(t, app, member.Username).AccessToken, orgID)
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products", clerkToken, nil, nil); status != fiber.StatusOK {
+		t.Errorf("clerk listing products: status %d, want 200", status)
+	}
+	product := models.Product{Name: "Shirt", SKU: "SH-1", Price: 10}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/products", clerkToken, product, nil); status != fiber.StatusForbidden {
+		t.Errorf("clerk creating a product: status %d, want 403", status)
+	}
+}
+
+func TestOrgScopedProducts(t *testing.T) {
+	app := testdb.App(t)
+	_, ownerToken := testdb.SignUp(t, app)
+	id := testdb.CreateProduct(t, app, ownerToken, models.Product{Name: "Mug", SKU: "MUG-1", Price: 4, Quantity: 3})
+	path := "/products/by-id?product_id=" + id.String()
+	if status := testdb.Call(t, app, fiber.MethodGet, path, ownerToken, nil, nil); status != fiber.StatusOK {
+		t.Errorf("own product: status %d, want 200", status)
+	}
+
+	_, otherToken := testdb.SignUp(t, app)
+	if status := testdb.Call(t, app, fiber.MethodGet, path, otherToken, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("product of another organization: status %d, want 404", status)
+	}
+}
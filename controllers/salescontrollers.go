@@ -0,0 +1,503 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const salesFile = "SalesController"
+
+const defaultReservationTTL = 72 * time.Hour
+
+// reservationTTL is how long a confirmed order holds its stock before it
+// expires, configured by RESERVATION_TTL (e.g. "48h").
+func reservationTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("RESERVATION_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultReservationTTL
+}
+
+// findOrgSalesOrder loads a sales order and its lines by the :id path parameter, scoped to the caller's organization.
+func findOrgSalesOrder(db *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.SalesOrder, error) {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid sales order ID format")
+	}
+	var order models.SalesOrder
+	if err := db.Preload("Lines").First(&order, "id = ? AND org_id = ?", orderID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Sales order not found")
+	}
+	return &order, nil
+}
+
+// releaseOrderReservations gives back every unit still reserved by the order's
+// lines, including the components of bundle kits, drops any outstanding
+// backorders, and offers the freed stock to other orders waiting on the same
+// products.
+func releaseOrderReservations(tx *gorm.DB, order *models.SalesOrder) error {
+	// Reread the lines under lock: allocateBackorders may have filled some
+	// since they were loaded, and what it reserved must be released too.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("sales_order_id = ?", order.ID).Order("id").Find(&order.Lines).Error; err != nil {
+		return err
+	}
+	freed := make(map[uuid.UUID]int)
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		freed[line.ProductID] += line.ReservedQuantity - line.KitReservedQuantity
+		if line.KitReservedQuantity > 0 {
+			if err := kitComponentQuantities(tx, line.ProductID, line.KitReservedQuantity, freed); err != nil {
+				return err
+			}
+		}
+		line.ReservedQuantity = 0
+		line.BackorderedQuantity = 0
+		line.KitReservedQuantity = 0
+		if err := tx.Model(line).Updates(map[string]interface{}{
+			"reserved_quantity":     0,
+			"backordered_quantity":  0,
+			"kit_reserved_quantity": 0,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	for productID, qty := range freed {
+		if qty == 0 {
+			continue
+		}
+		if err := releaseStock(tx, productID, qty); err != nil {
+			return err
+		}
+		product, err := lockProduct(tx, productID)
+		if err != nil {
+			return err
+		}
+		if err := allocateBackorders(tx, product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateSalesOrder godoc
+// @Summary      Create a sales order
+// @Description  Creates a draft customer order. Lines without a unit price use the product's price. Line quantities may be ordered in a pack unit and are stored in base units; unit prices are per base unit. No stock is reserved until the order is confirmed.
+// @Tags         Sales
+// @Accept       json
+// @Produce      json
+// @Param        order  body      models.SalesOrderRequest  true  "Sales order"
+// @Success      201    {object}  models.SalesOrder
+// @Failure      400    {object}  map[string]string  "Invalid input"
+// @Failure      401    {object}  map[string]string  "Unauthorized"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /sales-orders [post]
+func CreateSalesOrder(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.SalesOrderRequest
+	if err := c.BodyParser(&input); err != nil {
+		logger.Log.Error("Package controllers File "+salesFile, zap.String("Function", "CreateSalesOrder"), zap.String("Message", "Failed to parse request body"), zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Customer == "" || len(input.Lines) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "customer and at least one line are required"})
+	}
+
+	order := models.SalesOrder{
+		UserID:   userID,
+		OrgID:    orgID,
+		Customer: input.Customer,
+		Priority: input.Priority,
+		Status:   models.SOStatusDraft,
+	}
+	for _, in := range input.Lines {
+		if in.Quantity <= 0 || in.UnitPrice < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Line quantity must be positive and unit_price non-negative"})
+		}
+		var product models.Product
+		if err := database.DB.First(&product, "id = ? AND org_id = ?", in.ProductID, orgID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Product " + in.ProductID.String() + " not found"})
+		}
+		quantity, err := toBaseUnits(database.DB, &product, in.Unit, in.Quantity)
+		if err != nil {
+			return sendError(c, err)
+		}
+		unitPrice := in.UnitPrice
+		if unitPrice == 0 {
+			unitPrice = product.Price
+		}
+		order.Lines = append(order.Lines, models.SalesOrderLine{
+			ProductID: product.ID,
+			Quantity:  quantity,
+			UnitPrice: unitPrice,
+		})
+	}
+
+	if err := database.DB.Create(&order).Error; err != nil {
+		logger.Log.Error("Package controllers File "+salesFile, zap.String("Function", "CreateSalesOrder"), zap.String("Message", "Database error while creating sales order"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving sales order"})
+	}
+
+	logger.Log.Info("Package controllers File "+salesFile, zap.String("Function", "CreateSalesOrder"), zap.String("Message", "Sales order created"), zap.String("sales_order_id", order.ID.String()))
+	return c.Status(fiber.StatusCreated).JSON(order)
+}
+
+// GetSalesOrders godoc
+// @Summary      List sales orders
+// @Description  Get paginated list of the authenticated user's sales orders, optionally filtered by status
+// @Tags         Sales
+// @Produce      json
+// @Param        status   query     string  false  "Filter by status"
+// @Param        pagenum  query     int     false  "Page number (default: 1)"
+// @Param        limit    query     int     false  "Items per page (default: 10)"
+// @Success      200      {array}   models.SalesOrder
+// @Failure      401      {object}  map[string]string  "Unauthorized"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /sales-orders [get]
+func GetSalesOrders(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	query := database.DB.Preload("Lines").Where("org_id = ?", orgID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.SalesOrder
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&orders).Error; err != nil {
+		logger.Log.Error("Package controllers File "+salesFile, zap.String("Function", "GetSalesOrders"), zap.String("Message", "Error retrieving sales orders"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving sales orders"})
+	}
+	return c.JSON(orders)
+}
+
+// GetSalesOrder godoc
+// @Summary      Get a sales order
+// @Description  Retrieves a sales order with its lines
+// @Tags         Sales
+// @Produce      json
+// @Param        id   path      string  true  "Sales order ID (UUID)"
+// @Success      200  {object}  models.SalesOrder
+// @Failure      404  {object}  map[string]string  "Sales order not found"
+// @Security     BearerAuth
+// @Router       /sales-orders/{id} [get]
+func GetSalesOrder(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	order, err := findOrgSalesOrder(database.DB, c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(order)
+}
+
+// salesOrderStep runs step against the locked order at :id inside a
+// transaction, then moves the order to status.
+func salesOrderStep(c *fiber.Ctx, function, status string, step func(tx *gorm.DB, order *models.SalesOrder, userID uuid.UUID) error) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var order *models.SalesOrder
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if order, err = findOrgSalesOrder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if !order.CanTransitionTo(status) {
+			return fiber.NewError(fiber.StatusConflict, "Cannot move sales order from "+order.Status+" to "+status)
+		}
+		if step != nil {
+			if err := step(tx, order, userID); err != nil {
+				return err
+			}
+		}
+		order.Status = status
+		return tx.Model(order).Updates(map[string]interface{}{
+			"status":                 order.Status,
+			"reservation_expires_at": order.ReservationExpiresAt,
+			"confirmed_at":           order.ConfirmedAt,
+			"shipped_at":             order.ShippedAt,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errInsufficientStock) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Insufficient available stock"})
+		}
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+salesFile, zap.String("Function", function), zap.String("Message", "Failed to update sales order"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+salesFile, zap.String("Function", function), zap.String("Message", "Sales order status changed"), zap.String("sales_order_id", order.ID.String()), zap.String("status", status))
+	return c.JSON(order)
+}
+
+// ConfirmSalesOrder godoc
+// @Summary      Confirm a sales order
+// @Description  Reserves stock for every line so it is no longer available to other orders, without decrementing on-hand quantity. Bundles reserve assembled kits first and then the components of further kits. Lot-tracked products reserve units of lots that will not expire within the reservation window, first-expiry-first-out. Any shortfall is kept as a backorder and filled as stock arrives (for bundles, as kits are assembled). Once fully reserved, the reservation expires after RESERVATION_TTL unless the order is picked.
+// @Tags         Sales
+// @Produce      json
+// @Param        id   path      string  true  "Sales order ID (UUID)"
+// @Success      200  {object}  models.SalesOrder
+// @Failure      404  {object}  map[string]string  "Sales order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /sales-orders/{id}/confirm [post]
+func ConfirmSalesOrder(c *fiber.Ctx) error {
+	return salesOrderStep(c, "ConfirmSalesOrder", models.SOStatusConfirmed, func(tx *gorm.DB, order *models.SalesOrder, _ uuid.UUID) error {
+		backordered := false
+		for i := range order.Lines {
+			line := &order.Lines[i]
+			reserved, err := reserveUpTo(tx, line.ProductID, line.Quantity)
+			if err != nil {
+				return err
+			}
+			kits, err := reserveKits(tx, line.ProductID, line.Quantity-reserved)
+			if err != nil {
+				return err
+			}
+			line.ReservedQuantity = reserved + kits
+			line.KitReservedQuantity = kits
+			line.BackorderedQuantity = line.Quantity - line.ReservedQuantity
+			backordered = backordered || line.BackorderedQuantity > 0
+			if err := tx.Model(line).Updates(map[string]interface{}{
+				"reserved_quantity":     line.ReservedQuantity,
+				"kit_reserved_quantity": line.KitReservedQuantity,
+				"backordered_quantity":  line.BackorderedQuantity,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		order.ConfirmedAt = &now
+		order.ReservationExpiresAt = nil
+		if !backordered {
+			expiresAt := now.Add(reservationTTL())
+			order.ReservationExpiresAt = &expiresAt
+		}
+		return nil
+	})
+}
+
+// PickSalesOrder godoc
+// @Summary      Mark a sales order picked
+// @Description  Records that the reserved stock has been picked. Orders with outstanding backorders cannot be picked. Picked orders no longer expire.
+// @Tags         Sales
+// @Produce      json
+// @Param        id   path      string  true  "Sales order ID (UUID)"
+// @Success      200  {object}  models.SalesOrder
+// @Failure      404  {object}  map[string]string  "Sales order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition or backorders outstanding"
+// @Security     BearerAuth
+// @Router       /sales-orders/{id}/pick [post]
+func PickSalesOrder(c *fiber.Ctx) error {
+	return salesOrderStep(c, "PickSalesOrder", models.SOStatusPicked, func(tx *gorm.DB, order *models.SalesOrder, _ uuid.UUID) error {
+		return checkPickable(order)
+	})
+}
+
+// checkPickable rejects orders still waiting on backorders and stops a
+// pickable order's reservation from expiring.
+func checkPickable(order *models.SalesOrder) error {
+	for _, line := range order.Lines {
+		if line.BackorderedQuantity > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Sales order has backordered lines still waiting for stock")
+		}
+	}
+	order.ReservationExpiresAt = nil
+	return nil
+}
+
+// PackSalesOrder godoc
+// @Summary      Mark a sales order packed
+// @Description  Records that a picked order has been packed
+// @Tags         Sales
+// @Produce      json
+// @Param        id   path      string  true  "Sales order ID (UUID)"
+// @Success      200  {object}  models.SalesOrder
+// @Failure      404  {object}  map[string]string  "Sales order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /sales-orders/{id}/pack [post]
+func PackSalesOrder(c *fiber.Ctx) error {
+	return salesOrderStep(c, "PackSalesOrder", models.SOStatusPacked, nil)
+}
+
+// ShipSalesOrder godoc
+// @Summary      Ship a sales order
+// @Description  Consumes each line's reservation, decrementing product quantity with an outbound stock movement that takes the lots it reserved, or other unexpired lots if those have expired; bundle kits sold from components decrement each component. Lines of serial-tracked products must list the serials shipped, one per unit.
+// @Tags         Sales
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string                        true   "Sales order ID (UUID)"
+// @Param        ship  body      models.ShipSalesOrderRequest  false  "Serials shipped per line"
+// @Success      200   {object}  models.SalesOrder
+// @Failure      400   {object}  map[string]string  "Missing or unknown serials"
+// @Failure      404   {object}  map[string]string  "Sales order not found"
+// @Failure      409   {object}  map[string]string  "Invalid status transition, insufficient stock or serial not in stock"
+// @Security     BearerAuth
+// @Router       /sales-orders/{id}/ship [post]
+func ShipSalesOrder(c *fiber.Ctx) error {
+	var input models.ShipSalesOrderRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+	serials := make(map[uuid.UUID][]string, len(input.Lines))
+	for _, line := range input.Lines {
+		serials[line.LineID] = append(serials[line.LineID], line.Serials...)
+	}
+
+	return salesOrderStep(c, "ShipSalesOrder", models.SOStatusShipped, func(tx *gorm.DB, order *models.SalesOrder, userID uuid.UUID) error {
+		reference := "so:" + order.ID.String()
+		for i := range order.Lines {
+			line := &order.Lines[i]
+			qty := line.ReservedQuantity
+			product, err := lockProduct(tx, line.ProductID)
+			if err != nil {
+				return err
+			}
+			if product.SerialTracked {
+				if len(serials[line.ID]) != qty {
+					return fiber.NewError(fiber.StatusBadRequest, "Line for "+product.SKU+" needs one serial per unit shipped")
+				}
+				if err := moveSerials(tx, userID, line.ProductID, serials[line.ID], models.SerialInStock, models.SerialEventSold, reference, func(unit *models.SerialNumber) {
+					unit.Status = models.SerialSold
+					unit.Customer = order.Customer
+					unit.SalesOrderLineID = &line.ID
+				}); err != nil {
+					return err
+				}
+			}
+			if own := qty - line.KitReservedQuantity; own > 0 {
+				if err := releaseStock(tx, line.ProductID, own); err != nil {
+					return err
+				}
+				if _, err := applyMovement(tx, &models.StockMovement{
+					ProductID: line.ProductID,
+					UserID:    userID,
+					Type:      models.MovementOutbound,
+					Quantity:  -own,
+					Reference: reference,
+				}); err != nil {
+					return err
+				}
+			}
+			if line.KitReservedQuantity > 0 {
+				if err := shipKits(tx, line.ProductID, line.KitReservedQuantity, userID, reference); err != nil {
+					return err
+				}
+			}
+			line.ShippedQuantity += qty
+			line.ReservedQuantity = 0
+			line.KitReservedQuantity = 0
+			if err := tx.Model(line).Updates(map[string]interface{}{
+				"shipped_quantity":      line.ShippedQuantity,
+				"reserved_quantity":     0,
+				"kit_reserved_quantity": 0,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		order.ShippedAt = &now
+		return nil
+	})
+}
+
+// CancelSalesOrder godoc
+// @Summary      Cancel a sales order
+// @Description  Cancels an order that has not shipped and releases any stock it has reserved
+// @Tags         Sales
+// @Produce      json
+// @Param        id   path      string  true  "Sales order ID (UUID)"
+// @Success      200  {object}  models.SalesOrder
+// @Failure      404  {object}  map[string]string  "Sales order not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /sales-orders/{id}/cancel [post]
+func CancelSalesOrder(c *fiber.Ctx) error {
+	return salesOrderStep(c, "CancelSalesOrder", models.SOStatusCancelled, func(tx *gorm.DB, order *models.SalesOrder, _ uuid.UUID) error {
+		order.ReservationExpiresAt = nil
+		return releaseOrderReservations(tx, order)
+	})
+}
+
+// StartReservationExpiryJob releases the reservations of confirmed orders
+// whose window has passed, checking on the given interval.
+func StartReservationExpiryJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			expireReservations()
+		}
+	}()
+}
+
+func expireReservations() {
+	var orderIDs []uuid.UUID
+	if err := database.DB.Model(&models.SalesOrder{}).
+		Where("status = ? AND reservation_expires_at < ?", models.SOStatusConfirmed, time.Now()).
+		Pluck("id", &orderIDs).Error; err != nil {
+		logger.Log.Error("Package controllers File "+salesFile, zap.String("Function", "expireReservations"), zap.String("Message", "Failed to list expired reservations"), zap.Error(err))
+		return
+	}
+
+	for _, orderID := range orderIDs {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var order models.SalesOrder
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").First(&order, "id = ?", orderID).Error; err != nil {
+				return err
+			}
+			// The order may have been picked or cancelled since we listed it.
+			if order.Status != models.SOStatusConfirmed {
+				return nil
+			}
+			if err := releaseOrderReservations(tx, &order); err != nil {
+				return err
+			}
+			return tx.Model(&order).Updates(map[string]interface{}{
+				"status":                 models.SOStatusExpired,
+				"reservation_expires_at": nil,
+			}).Error
+		})
+		if err != nil {
+			logger.Log.Error("Package controllers File "+salesFile, zap.String("Function", "expireReservations"), zap.String("Message", "Failed to expire reservation"), zap.String("sales_order_id", orderID.String()), zap.Error(err))
+			continue
+		}
+		logger.Log.Info("Package controllers File "+salesFile, zap.String("Function", "expireReservations"), zap.String("Message", "Sales order reservation expired"), zap.String("sales_order_id", orderID.String()))
+	}
+}
@@ -0,0 +1,105 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+// newSalesOrder creates a draft order for quantity units of product.
+func newSalesOrder(t *testing.T, app *fiber.App, bearer string, product uuid.UUID, quantity int) models.SalesOrder {
+	t.Helper()
+	var order models.SalesOrder
+	body := models.SalesOrderRequest{Customer: "Jane Doe", Lines: []models.SalesOrderLineRequest{{ProductID: product, Quantity: quantity}}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/sales-orders", bearer, body, &order); status != fiber.StatusCreated {
+		t.Fatalf("create sales order: status %d", status)
+	}
+	return order
+}
+
+// salesOrderStep posts to /sales-orders/:id/<step> and returns the status code.
+func salesOrderStep(t *testing.T, app *fiber.App, bearer string, order models.SalesOrder, step string) int {
+	t.Helper()
+	return testdb.Call(t, app, fiber.MethodPost, "/sales-orders/"+order.ID.String()+"/"+step, bearer, nil, nil)
+}
+
+// stockLevels reads a product's on-hand and reserved quantities from the database.
+func stockLevels(t *testing.T, productID uuid.UUID) (quantity, reserved int) {
+	t.Helper()
+	var product models.Product
+	if err := database.DB.First(&product, "id = ?", productID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return product.Quantity, product.Reserved
+}
+
+func TestSalesOrderReservation(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Price: 10, Quantity: 10})
+
+	first := newSalesOrder(t, app, bearer, product, 6)
+	if status := salesOrderStep(t, app, bearer, first, "confirm"); status != fiber.StatusOK {
+		t.Fatalf("confirm: status %d", status)
+	}
+	if quantity, reserved := stockLevels(t, product); quantity != 10 || reserved != 6 {
+		t.Errorf("after confirming: quantity %d, reserved %d; want 10 and 6", quantity, reserved)
+	}
+	// Reserved stock cannot be adjusted away.
+	path := "/products/" + product.String() + "/quantity"
+	if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, models.QuantityUpdateRequest{Quantity: 5}, nil); status != fiber.StatusConflict {
+		t.Errorf("setting quantity below reserved: status %d, want 409", status)
+	}
+
+	// The shortfall is backordered and must arrive before the order is picked.
+	second := newSalesOrder(t, app, bearer, product, 5)
+	if status := salesOrderStep(t, app, bearer, second, "confirm"); status != fiber.StatusOK {
+		t.Fatalf("confirm: status %d", status)
+	}
+	if _, reserved := stockLevels(t, product); reserved != 10 {
+		t.Errorf("reserved with a backorder = %d, want 10", reserved)
+	}
+	if status := salesOrderStep(t, app, bearer, second, "pick"); status != fiber.StatusConflict {
+		t.Errorf("picking with a backorder: status %d, want 409", status)
+	}
+	// Cancelling the first order frees its stock and fills the backorder.
+	if status := salesOrderStep(t, app, bearer, first, "cancel"); status != fiber.StatusOK {
+		t.Fatalf("cancel: status %d", status)
+	}
+	if _, reserved := stockLevels(t, product); reserved != 5 {
+		t.Errorf("reserved after cancelling = %d, want the second order's 5", reserved)
+	}
+
+	if status := salesOrderStep(t, app, bearer, second, "ship"); status != fiber.StatusConflict {
+		t.Errorf("shipping an unpicked order: status %d, want 409", status)
+	}
+	for _, step := range []string{"pick", "pack", "ship"} {
+		if status := salesOrderStep(t, app, bearer, second, step); status != fiber.StatusOK {
+			t.Fatalf("%s: status %d", step, status)
+		}
+	}
+	if quantity, reserved := stockLevels(t, product); quantity != 5 || reserved != 0 {
+		t.Errorf("after shipping: quantity %d, reserved %d; want 5 and 0", quantity, reserved)
+	}
+}
+
+func TestNewProductHasNothingReserved(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	id := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Desk", SKU: "DSK-1", Price: 90, Quantity: 4, Reserved: 4, Quarantined: 2})
+	if product := loadProduct(t, id); product.Quantity != 4 || product.Reserved != 0 || product.Quarantined != 0 {
+		t.Errorf("quantity %d, reserved %d, quarantined %d; want 4, 0, 0", product.Quantity, product.Reserved, product.Quarantined)
+	}
+	// All four are free to sell.
+	order := newSalesOrder(t, app, bearer, id, 4)
+	if status := salesOrderStep(t, app, bearer, order, "confirm"); status != fiber.StatusOK {
+		t.Fatalf("confirm: status %d", status)
+	}
+	if _, reserved := stockLevels(t, id); reserved != 4 {
+		t.Errorf("reserved %d, want 4", reserved)
+	}
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const serialFile = "SerialController"
+
+var errSerialMismatch = errors.New("quantity does not match in-stock serials")
+
+// normaliseSerials trims the serials and rejects blanks and duplicates.
+func normaliseSerials(serials []string) ([]string, error) {
+	seen := make(map[string]bool, len(serials))
+	out := make([]string, 0, len(serials))
+	for _, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Serial numbers cannot be blank")
+		}
+		if seen[serial] {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Serial "+serial+" is listed twice")
+		}
+		seen[serial] = true
+		out = append(out, serial)
+	}
+	return out, nil
+}
+
+// registerSerials adds new in_stock units of a product. Serials that already
+// exist for the product are rejected unless they were removed earlier.
+func registerSerials(tx *gorm.DB, userID, productID uuid.UUID, serials []string, event, location, reference string) error {
+	serials, err := normaliseSerials(serials)
+	if err != nil {
+		return err
+	}
+	for _, serial := range serials {
+		var unit models.SerialNumber
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id = ? AND serial = ?", productID, serial).First(&unit).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			unit = models.SerialNumber{ProductID: productID, Serial: serial}
+		case err != nil:
+			return err
+		case unit.Status != models.SerialRemoved:
+			return fiber.NewError(fiber.StatusConflict, "Serial "+serial+" is already registered")
+		}
+		unit.Status = models.SerialInStock
+		unit.Location = location
+		unit.Customer = ""
+		unit.SalesOrderLineID = nil
+		unit.ReturnAuthorizationID = nil
+		if err := tx.Save(&unit).Error; err != nil {
+			return err
+		}
+		if err := recordSerialEvent(tx, &unit, userID, event, reference); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveSerials locks the named serials of a product, checks each is currently
+// in fromStatus, applies update and records event against each.
+func moveSerials(tx *gorm.DB, userID, productID uuid.UUID, serials []string, fromStatus, event, reference string, update func(*models.SerialNumber)) error {
+	serials, err := normaliseSerials(serials)
+	if err != nil {
+		return err
+	}
+	var units []models.SerialNumber
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND serial IN ?", productID, serials).Find(&units).Error; err != nil {
+		return err
+	}
+	if len(units) != len(serials) {
+		return fiber.NewError(fiber.StatusBadRequest, "One or more serials are not registered for this product")
+	}
+	for i := range units {
+		unit := &units[i]
+		if unit.Status != fromStatus {
+			return fiber.NewError(fiber.StatusConflict, "Serial "+unit.Serial+" is "+unit.Status+", expected "+fromStatus)
+		}
+		update(unit)
+		if err := tx.Save(unit).Error; err != nil {
+			return err
+		}
+		if err := recordSerialEvent(tx, unit, userID, event, reference); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordSerialEvent(tx *gorm.DB, unit *models.SerialNumber, userID uuid.UUID, event, reference string) error {
+	return tx.Create(&models.SerialEvent{
+		SerialNumberID: unit.ID,
+		UserID:         userID,
+		Event:          event,
+		Location:       unit.Location,
+		Customer:       unit.Customer,
+		Reference:      reference,
+	}).Error
+}
+
+// disposeReturnedSerials moves the serials of an RMA that are in status from,
+// returned or quarantined, according to its disposition: restocked units go
+// back in stock, the rest are quarantined at location or written off.
+func disposeReturnedSerials(tx *gorm.DB, rma *models.ReturnAuthorization, userID uuid.UUID, from, disposition, location string) error {
+	var serials []string
+	if err := tx.Model(&models.SerialNumber{}).
+		Where("return_authorization_id = ? AND status = ?", rma.ID, from).
+		Pluck("serial", &serials).Error; err != nil || len(serials) == 0 {
+		return err
+	}
+
+	status, event := models.SerialInStock, models.SerialEventRestocked
+	switch disposition {
+	case models.DispositionQuarantine:
+		status, event = models.SerialQuarantined, models.SerialEventQuarantined
+	case models.DispositionWriteOff:
+		status, event = models.SerialWrittenOff, models.SerialEventWrittenOff
+		location = ""
+	}
+	return moveSerials(tx, userID, rma.ProductID, serials, from, event, "rma:"+rma.ID.String(), func(unit *models.SerialNumber) {
+		unit.Status = status
+		unit.Location = location
+		if status == models.SerialInStock {
+			unit.Customer = ""
+			unit.SalesOrderLineID = nil
+		}
+	})
+}
+
+// checkSerialCount enforces that a serial-tracked product's quantity matches
+// its in-stock serials.
+func checkSerialCount(tx *gorm.DB, product *models.Product) error {
+	var inStock int64
+	if err := tx.Model(&models.SerialNumber{}).
+		Where("product_id = ? AND status = ?", product.ID, models.SerialInStock).
+		Count(&inStock).Error; err != nil {
+		return err
+	}
+	if inStock != int64(product.Quantity) {
+		return errSerialMismatch
+	}
+	return nil
+}
+
+// GetSerial godoc
+// @Summary      Serial number history
+// @Description  Shows a unit's current status, location and customer with its full history (received, transferred, sold, returned). Pass product_id when the same serial exists on more than one product.
+// @Tags         Serials
+// @Produce      json
+// @Param        serial      path      string  true   "Serial number"
+// @Param        product_id  query     string  false  "Product ID (UUID) to disambiguate"
+// @Success      200         {object}  models.SerialNumber
+// @Failure      404         {object}  map[string]string  "Serial not found"
+// @Failure      409         {object}  map[string]string  "Serial exists on several products"
+// @Security     BearerAuth
+// @Router       /serials/{serial} [get]
+func GetSerial(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	query := database.DB.
+		Joins("JOIN products p ON p.id = serial_numbers.product_id").
+		Where("p.org_id = ? AND serial_numbers.serial = ?", orgID, c.Params("serial"))
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("serial_numbers.product_id = ?", productID)
+	}
+
+	var units []models.SerialNumber
+	if err := query.Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Limit(2).Find(&units).Error; err != nil {
+		logger.Log.Error("Package controllers File "+serialFile, zap.String("Function", "GetSerial"), zap.String("Message", "Error retrieving serial"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving serial"})
+	}
+	switch len(units) {
+	case 0:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Serial not found"})
+	case 1:
+		return c.JSON(units[0])
+	default:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Serial exists on several products; pass product_id"})
+	}
+}
+
+// TransferSerials godoc
+// @Summary      Transfer serialised units
+// @Description  Moves the named in-stock serials of a product to another location, recording a transfer in each unit's history
+// @Tags         Serials
+// @Accept       json
+// @Produce      json
+// @Param        transfer  body      models.SerialTransferRequest  true  "Serials and destination"
+// @Success      200       {object}  map[string]interface{}
+// @Failure      400       {object}  map[string]string  "Invalid input"
+// @Failure      404       {object}  map[string]string  "Product not found"
+// @Failure      409       {object}  map[string]string  "Serial not in stock"
+// @Security     BearerAuth
+// @Router       /serials/transfer [post]
+func TransferSerials(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.SerialTransferRequest
+	if err := c.BodyParser(&input); err != nil || len(input.Serials) == 0 || strings.TrimSpace(input.Location) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "serials and location are required"})
+	}
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ? AND serial_tracked", input.ProductID, orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Serial-tracked product not found"})
+	}
+
+	location := strings.TrimSpace(input.Location)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return moveSerials(tx, userID, product.ID, input.Serials, models.SerialInStock, models.SerialEventTransferred, "", func(unit *models.SerialNumber) {
+			unit.Location = location
+		})
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+serialFile, zap.String("Function", "TransferSerials"), zap.String("Message", "Failed to transfer serials"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+serialFile, zap.String("Function", "TransferSerials"), zap.String("Message", "Serials transferred"), zap.String("product_id", product.ID.String()), zap.Int("count", len(input.Serials)))
+	return c.JSON(fiber.Map{"message": "Serials transferred", "count": len(input.Serials), "location": location})
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormaliseSerials(t *testing.T) {
+	tests := []struct {
+		name    string
+		serials []string
+		want    []string
+		wantErr bool
+	}{
+		{"trimmed", []string{" SN-1", "SN-2 "}, []string{"SN-1", "SN-2"}, false},
+		{"empty list", nil, []string{}, false},
+		{"blank", []string{"SN-1", "  "}, nil, true},
+		{"duplicate after trimming", []string{"SN-1", "SN-1 "}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normaliseSerials(tt.serials)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normaliseSerials = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
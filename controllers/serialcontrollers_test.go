@@ -0,0 +1,71 @@
+package controllers_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestSerialLifecycle(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	if status := testdb.Call(t, app, fiber.MethodPost, "/products", bearer, models.Product{Name: "Laptop", SKU: "LT-1", Price: 900, Quantity: 2, SerialTracked: true}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("creating serial-tracked stock without serials: status %d, want 400", status)
+	}
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Laptop", SKU: "LT-1", Price: 900, SerialTracked: true})
+
+	path := "/products/" + product.String() + "/quantity"
+	if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, models.QuantityUpdateRequest{Quantity: 3, Serials: []string{"SN-A", "SN-B"}}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("adding 3 units with 2 serials: status %d, want 400", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, models.QuantityUpdateRequest{Quantity: 2, Serials: []string{"SN-A", "SN-B"}}, nil); status != fiber.StatusOK {
+		t.Fatalf("add serials: status %d", status)
+	}
+	transfer := models.SerialTransferRequest{ProductID: product, Serials: []string{"SN-A"}, Location: "Store 4"}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/serials/transfer", bearer, transfer, nil); status != fiber.StatusOK {
+		t.Fatalf("transfer: status %d", status)
+	}
+
+	order := newSalesOrder(t, app, bearer, product, 1)
+	for _, step := range []string{"confirm", "pick", "pack"} {
+		if status := salesOrderStep(t, app, bearer, order, step); status != fiber.StatusOK {
+			t.Fatalf("%s: status %d", step, status)
+		}
+	}
+	shipPath := "/sales-orders/" + order.ID.String() + "/ship"
+	ship := func(serials ...string) int {
+		body := models.ShipSalesOrderRequest{Lines: []models.ShipLineRequest{{LineID: order.Lines[0].ID, Serials: serials}}}
+		return testdb.Call(t, app, fiber.MethodPost, shipPath, bearer, body, nil)
+	}
+	if status := ship(); status != fiber.StatusBadRequest {
+		t.Errorf("shipping without serials: status %d, want 400", status)
+	}
+	if status := ship("SN-Z"); status != fiber.StatusBadRequest {
+		t.Errorf("shipping an unknown serial: status %d, want 400", status)
+	}
+	if status := ship("SN-A"); status != fiber.StatusOK {
+		t.Fatalf("ship: status %d", status)
+	}
+	if got := productQuantity(t, product); got != 1 {
+		t.Errorf("quantity = %d, want the 1 serial left in stock", got)
+	}
+
+	var unit models.SerialNumber
+	if status := testdb.Call(t, app, fiber.MethodGet, "/serials/SN-A?product_id="+product.String(), bearer, nil, &unit); status != fiber.StatusOK {
+		t.Fatalf("get serial: status %d", status)
+	}
+	if unit.Status != models.SerialSold || unit.Customer != "Jane Doe" || unit.Location != "Store 4" {
+		t.Errorf("serial = %s to %q at %q, want sold to Jane Doe from Store 4", unit.Status, unit.Customer, unit.Location)
+	}
+	var events []string
+	for _, event := range unit.Events {
+		events = append(events, event.Event)
+	}
+	want := []string{models.SerialEventAdjusted, models.SerialEventTransferred, models.SerialEventSold}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("history = %v, want %v", events, want)
+	}
+}
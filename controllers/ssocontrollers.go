@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/oidc"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const ssoFile = "SSOController"
+
+// ssoLoginTTL is how long a user has to sign in at the identity provider.
+const ssoLoginTTL = 10 * time.Minute
+
+// ssoStateCookie binds a login to the browser that started it, so nobody can
+// finish a login they started in someone else's browser.
+const ssoStateCookie = "oidc_state"
+
+var errSSODisabled = fiber.NewError(fiber.StatusNotFound, "Single sign-on is not configured")
+
+// SSOLogin godoc
+// @Summary      Sign in with single sign-on
+// @Description  Redirects the browser to the company identity provider to sign in, using the authorization code flow with PKCE. The provider sends the browser back to /auth/oidc/callback. Open this in the browser itself (not with fetch), as it sets a short-lived cookie that the callback checks.
+// @Tags         Auth
+// @Success      302
+// @Failure      404  {object}  map[string]string  "Single sign-on is not configured"
+// @Failure      502  {object}  map[string]string  "Identity provider unavailable"
+// @Router       /auth/oidc/login [get]
+func SSOLogin(c *fiber.Ctx) error {
+	provider := oidc.Default
+	if provider == nil {
+		return sendError(c, errSSODisabled)
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return ssoInternalError(c, "SSOLogin", "Failed to generate state", err)
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return ssoInternalError(c, "SSOLogin", "Failed to generate nonce", err)
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return ssoInternalError(c, "SSOLogin", "Failed to generate PKCE verifier", err)
+	}
+	authURL, err := provider.AuthURL(c.UserContext(), state, nonce, challenge)
+	if err != nil {
+		logger.Log.Error("Package controllers File "+ssoFile, zap.String("Function", "SSOLogin"), zap.String("Message", "Identity provider discovery failed"), zap.Error(err))
+		return ssoFail(c, fiber.NewError(fiber.StatusBadGateway, "Identity provider is unavailable"))
+	}
+	if err := database.DB.Create(&models.SSOLoginState{
+		StateHash: hashToken(state),
+		Verifier:  verifier,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(ssoLoginTTL),
+	}).Error; err != nil {
+		return ssoInternalError(c, "SSOLogin", "Failed to save login state", err)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     ssoStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc",
+		MaxAge:   int(ssoLoginTTL.Seconds()),
+		Secure:   strings.HasPrefix(provider.RedirectURL, "https://"),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// SSOCallback godoc
+// @Summary      Finish signing in with single sign-on
+// @Description  The identity provider sends the browser here after sign-in. The authorization code is exchanged for an ID token, which is verified, and the user is found by their provider account, linked by an email address both the provider and an existing account have verified, or created. Roles in the SSO organization can be mapped from a provider claim such as groups. Answers like /login: tokens, or a challenge for /login/2fa when the user has local two-factor authentication and the provider did not report a second factor. When OIDC_POST_LOGIN_URL is set the browser is redirected there instead, with the same fields (or error) in the URL fragment.
+// @Tags         Auth
+// @Produce      json
+// @Param        code   query     string  true  "Authorization code"
+// @Param        state  query     string  true  "State from /auth/oidc/login"
+// @Success      200    {object}  models.TokenResponse
+// @Success      202    {object}  models.MFAChallenge  "Second factor required"
+// @Success      302    "Redirect to OIDC_POST_LOGIN_URL"
+// @Failure      400    {object}  map[string]string  "Missing, expired or mismatched state, or sign-in refused by the provider"
+// @Failure      401    {object}  map[string]string  "ID token rejected"
+// @Failure      403    {object}  map[string]string  "Provider did not share an email address"
+// @Failure      409    {object}  map[string]string  "An account with the email exists and it or the provider has not verified it"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Router       /auth/oidc/callback [get]
+func SSOCallback(c *fiber.Ctx) error {
+	provider := oidc.Default
+	if provider == nil {
+		return sendError(c, errSSODisabled)
+	}
+	cookie := c.Cookies(ssoStateCookie)
+	c.Cookie(&fiber.Cookie{Name: ssoStateCookie, Path: "/auth/oidc", Expires: time.Unix(0, 0), HTTPOnly: true, SameSite: fiber.CookieSameSiteLaxMode})
+
+	if c.Query("error") != "" {
+		logger.Log.Warn("Package controllers File "+ssoFile, zap.String("Function", "SSOCallback"), zap.String("Message", "Identity provider refused sign-in"), zap.String("error", c.Query("error")), zap.String("description", c.Query("error_description")))
+		return ssoFail(c, fiber.NewError(fiber.StatusBadRequest, "Sign-in was cancelled or refused by the identity provider"))
+	}
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		return ssoFail(c, fiber.NewError(fiber.StatusBadRequest, "code and state are required"))
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(cookie)) != 1 {
+		return ssoFail(c, fiber.NewError(fiber.StatusBadRequest, "Sign-in was started in another browser or has expired; start again"))
+	}
+
+	var login models.SSOLoginState
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&login, "state_hash = ?", hashToken(state)).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Sign-in has expired; start again")
+		}
+		if err := tx.Delete(&login).Error; err != nil {
+			return err
+		}
+		if time.Now().After(login.ExpiresAt) {
+			return fiber.NewError(fiber.StatusBadRequest, "Sign-in has expired; start again")
+		}
+		return nil
+	})
+	if err != nil {
+		return ssoFailOrLog(c, "Failed to consume login state", err)
+	}
+
+	ctx := c.UserContext()
+	tokens, err := provider.Exchange(ctx, code, login.Verifier)
+	if err != nil {
+		logger.Log.Warn("Package controllers File "+ssoFile, zap.String("Function", "SSOCallback"), zap.String("Message", "Code exchange failed"), zap.Error(err))
+		return ssoFail(c, fiber.NewError(fiber.StatusBadRequest, "Identity provider did not accept the sign-in; start again"))
+	}
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, login.Nonce)
+	if err != nil {
+		logger.Log.Warn("Package controllers File "+ssoFile, zap.String("Function", "SSOCallback"), zap.String("Message", "ID token rejected"), zap.Error(err))
+		return ssoFail(c, fiber.NewError(fiber.StatusUnauthorized, "Identity provider's response could not be verified"))
+	}
+	// Some providers only put email and groups in the userinfo response.
+	if claims.String("email") == "" || (provider.Provisioning.SyncsRoles() && claims[provider.Provisioning.RoleClaim] == nil) {
+		if err := mergeUserInfo(c, provider, tokens.AccessToken, claims); err != nil {
+			logger.Log.Warn("Package controllers File "+ssoFile, zap.String("Function", "SSOCallback"), zap.String("Message", "Userinfo request failed"), zap.Error(err))
+		}
+	}
+
+	md, err := provider.Metadata(ctx)
+	if err != nil {
+		return ssoInternalError(c, "SSOCallback", "Identity provider discovery failed", err)
+	}
+	var user *models.User
+	var verifyToken string
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		user, verifyToken, err = provisionSSOUser(tx, md.Issuer, claims, provider.Provisioning)
+		return err
+	})
+	if err != nil {
+		return ssoFailOrLog(c, "Failed to provision user", err)
+	}
+	if verifyToken != "" {
+		deliver("SSOCallback", verificationEmail(user, verifyToken))
+	}
+
+	mfa := oidc.MultiFactor(claims)
+	if !mfa {
+		challenge, err := startLoginChallenge(user)
+		if err != nil {
+			return ssoInternalError(c, "SSOCallback", "Failed to start login challenge", err)
+		}
+		if challenge != nil {
+			return ssoRespond(c, fiber.StatusAccepted, challenge, url.Values{
+				"mfa_required":    {"true"},
+				"challenge_token": {challenge.ChallengeToken},
+				"expires_in":      {strconv.Itoa(challenge.ExpiresIn)},
+			})
+		}
+	}
+	var session *models.TokenResponse
+	err = audit.Transaction(database.DB, func(tx *gorm.DB) error {
+		var err error
+		session, err = openSessionIn(tx, c, user, provider.Provisioning.OrgID, mfa)
+		return err
+	})
+	if err != nil {
+		return ssoInternalError(c, "SSOCallback", "Failed to start session", err)
+	}
+
+	logger.Log.Info("Package controllers File "+ssoFile, zap.String("Function", "SSOCallback"), zap.String("Message", "Signed in with single sign-on"), zap.String("user_id", user.UserID.String()), zap.Bool("mfa", mfa), zap.String("ip", c.IP()))
+	return ssoRespond(c, fiber.StatusOK, session, url.Values{
+		"access_token":  {session.AccessToken},
+		"refresh_token": {session.RefreshToken},
+		"token_type":    {session.TokenType},
+		"expires_in":    {strconv.Itoa(session.ExpiresIn)},
+	})
+}
+
+// mergeUserInfo adds the claims from the userinfo endpoint that the ID token
+// lacks, provided they are about the same subject.
+func mergeUserInfo(c *fiber.Ctx, provider *oidc.Provider, accessToken string, claims oidc.Claims) error {
+	if accessToken == "" {
+		return nil
+	}
+	info, err := provider.UserInfo(c.UserContext(), accessToken)
+	if err != nil || info == nil {
+		return err
+	}
+	if info.String("sub") != claims.String("sub") {
+		return errors.New("userinfo is about a different subject")
+	}
+	for name, value := range info {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+	return nil
+}
+
+// provisionSSOUser finds the user claims are about, by their provider
+// account or, the first time, by verified email, creating them if there is
+// none, and gives them their role in the SSO organization. A user created
+// with an unverified email also gets a verification token, to email once the
+// transaction commits.
+func provisionSSOUser(tx *gorm.DB, issuer string, claims oidc.Claims, prov oidc.Provisioning) (*models.User, string, error) {
+	subject := claims.String("sub")
+	email := strings.ToLower(strings.TrimSpace(claims.String("email")))
+	verified := claims.Bool("email_verified")
+	now := time.Now()
+
+	var user models.User
+	var identity models.ExternalIdentity
+	var verifyToken string
+	var created, linked bool
+	err := tx.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	switch {
+	case err == nil:
+		if err := tx.First(&user, "user_id = ?", identity.UserID).Error; err != nil {
+			return nil, "", err
+		}
+		if err := tx.Model(&identity).Updates(map[string]interface{}{"email": email, "last_login_at": now}).Error; err != nil {
+			return nil, "", err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !validEmail(email) {
+			return nil, "", fiber.NewError(fiber.StatusForbidden, "The identity provider did not share a valid email address")
+		}
+		err := tx.Where("LOWER(email) = ?", email).First(&user).Error
+		switch {
+		case err == nil:
+			// Linking by email trusts the provider's word that the address
+			// is theirs; without it the account could belong to someone else.
+			if !verified {
+				return nil, "", fiber.NewError(fiber.StatusConflict, "An account with this email address already exists; the identity provider must verify the address to link it")
+			}
+			// Nor is an account that never proved the address its owner's:
+			// someone may have registered it first to take over the SSO
+			// login, keeping a password the real owner does not know.
+			if user.EmailVerifiedAt == nil {
+				return nil, "", fiber.NewError(fiber.StatusConflict, "An account with this email address already exists but has not verified it; verify the address from that account first")
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if verifyToken, err = createSSOUser(tx, &user, email, verified, claims, prov); err != nil {
+				return nil, "", err
+			}
+			created = true
+		default:
+			return nil, "", err
+		}
+		identity = models.ExternalIdentity{UserID: user.UserID, Issuer: issuer, Subject: subject, Email: email, LastLoginAt: now}
+		if err := tx.Create(&identity).Error; err != nil {
+			return nil, "", err
+		}
+		linked = true
+	default:
+		return nil, "", err
+	}
+
+	original := user
+	if verified && user.EmailVerifiedAt == nil && strings.EqualFold(user.Email, email) {
+		user.EmailVerifiedAt = &now
+		if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			return nil, "", err
+		}
+	}
+	if prov.OrgID != uuid.Nil {
+		if err := syncSSOMembership(tx, &user, claims, prov); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// Nobody is signed in yet; the changes are made by the user signing in.
+	provider := map[string]models.AuditChange{"identity_provider": {After: issuer}}
+	switch {
+	case created:
+		err = auditUser(tx, "user.create", nil, &user, provider, &user.UserID)
+	case linked:
+		err = auditUser(tx, "user.update", &original, &user, provider, &user.UserID)
+	default:
+		err = auditUser(tx, "user.update", &original, &user, nil, &user.UserID)
+	}
+	return &user, verifyToken, err
+}
+
+// createSSOUser creates the user claims describe, without a password: they
+// sign in through the identity provider. Without an SSO organization they
+// get a personal one, as if they had registered. Unless the provider
+// verified the email, it returns a token for the verification email.
+func createSSOUser(tx *gorm.DB, user *models.User, email string, verified bool, claims oidc.Claims, prov oidc.Provisioning) (string, error) {
+	username, err := ssoUsername(tx, claims, email)
+	if err != nil {
+		return "", err
+	}
+	*user = models.User{Username: username, Email: email}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return "", err
+	}
+	if prov.OrgID == uuid.Nil {
+		if _, err := createPersonalOrg(tx, user); err != nil {
+			return "", err
+		}
+	}
+	var token string
+	if !verified {
+		if token, err = issueAccountToken(tx, user.UserID, models.TokenPurposeEmailVerification, emailVerificationTTL); err != nil {
+			return "", err
+		}
+	}
+	logger.Log.Info("Package controllers File "+ssoFile, zap.String("Function", "createSSOUser"), zap.String("Message", "User provisioned from identity provider"), zap.String("user_id", user.UserID.String()), zap.String("username", username))
+	return token, nil
+}
+
+// syncSSOMembership makes the user a member of the SSO organization with the
+// role their claims map to. Existing members keep their role unless roles
+// come from the provider.
+func syncSSOMembership(tx *gorm.DB, user *models.User, claims oidc.Claims, prov oidc.Provisioning) error {
+	role := prov.RoleFor(claims)
+	var membership models.Membership
+	err := tx.Where("org_id = ? AND user_id = ?", prov.OrgID, user.UserID).First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var org models.Organization
+		if err := tx.Select("id").First(&org, "id = ?", prov.OrgID).Error; err != nil {
+			return errors.New("OIDC_ORG_ID " + prov.OrgID.String() + " is not an organization: " + err.Error())
+		}
+		membership = models.Membership{OrgID: prov.OrgID, UserID: user.UserID, Role: role}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		return auditMembership(tx, "membership.create", nil, &membership, &user.UserID)
+	}
+	if err != nil {
+		return err
+	}
+	if prov.SyncsRoles() && membership.Role != role {
+		logger.Log.Info("Package controllers File "+ssoFile, zap.String("Function", "syncSSOMembership"), zap.String("Message", "Role updated from identity provider"), zap.String("user_id", user.UserID.String()), zap.String("from", membership.Role), zap.String("to", role))
+		if err := tx.Model(&models.Membership{}).Where("org_id = ? AND user_id = ?", prov.OrgID, user.UserID).Update("role", role).Error; err != nil {
+			return err
+		}
+		updated := membership
+		updated.Role = role
+		return auditMembership(tx, "membership.update", &membership, &updated, &user.UserID)
+	}
+	return nil
+}
+
+var usernameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// ssoUsername picks an unused username from the preferred_username claim or
+// the email address, adding a number if it is taken.
+func ssoUsername(tx *gorm.DB, claims oidc.Claims, email string) (string, error) {
+	base, _, _ := strings.Cut(claims.String("preferred_username"), "@")
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = strings.TrimLeft(usernameUnsafe.ReplaceAllString(base, ""), "_.-")
+	if len(base) > 28 {
+		base = base[:28]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = base + strconv.Itoa(i)
+		}
+		var count int64
+		if err := tx.Model(&models.User{}).Where("LOWER(username) = LOWER(?)", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	suffix, err := randomToken(3)
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + suffix, nil
+}
+
+// ssoRespond sends the outcome of a sign-in as JSON or, when
+// OIDC_POST_LOGIN_URL is set, redirects the browser there with the outcome
+// in the URL fragment, which browsers do not send to servers.
+func ssoRespond(c *fiber.Ctx, status int, body interface{}, fragment url.Values) error {
+	if target := os.Getenv("OIDC_POST_LOGIN_URL"); target != "" {
+		return c.Redirect(target+"#"+fragment.Encode(), fiber.StatusFound)
+	}
+	return c.Status(status).JSON(body)
+}
+
+// ssoFail reports a failed sign-in the way ssoRespond reports success.
+func ssoFail(c *fiber.Ctx, fe *fiber.Error) error {
+	return ssoRespond(c, fe.Code, fiber.Map{"error": fe.Message}, url.Values{"error": {fe.Message}})
+}
+
+// ssoFailOrLog reports err to the user if it is a *fiber.Error and otherwise
+// logs it and reports an internal error.
+func ssoFailOrLog(c *fiber.Ctx, message string, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return ssoFail(c, fe)
+	}
+	return ssoInternalError(c, "SSOCallback", message, err)
+}
+
+func ssoInternalError(c *fiber.Ctx, function, message string, err error) error {
+	logger.Log.Error("Package controllers File "+ssoFile, zap.String("Function", function), zap.String("Message", message), zap.Error(err))
+	return ssoFail(c, fiber.NewError(fiber.StatusInternalServerError, "Internal server error"))
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInsufficientStock = errors.New("insufficient stock")
+
+// lockProduct loads a product inside tx with a row lock so concurrent stock
+// changes to the same product are serialised.
+func lockProduct(tx *gorm.DB, productID uuid.UUID) (*models.Product, error) {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, "id = ?", productID).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// applyMovement is the single place stock quantities change. It locks the
+// product, applies movement.Quantity as a delta and records the movement, all
+// inside tx. For lot-tracked products the movement is booked against lots and
+// may be split into one movement per lot consumed. For serial-tracked products
+// the caller moves the serials first and the new quantity must match the
+// in-stock serial count. Outgoing stock is taken out of the product's bins if
+// they would otherwise hold more than is on hand. Incoming stock is offered
+// to waiting backorders straight away. Every movement is audited. A decrease
+// that would leave less on hand than is reserved for orders fails with
+// errInsufficientStock, so reserved stock can only leave by shipping, which
+// releases its reservation first.
+func applyMovement(tx *gorm.DB, movement *models.StockMovement) (*models.Product, error) {
+	product, err := lockProduct(tx, movement.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if movement.Quantity < 0 && product.Quantity+movement.Quantity < product.Reserved {
+		return nil, errInsufficientStock
+	}
+	before := product.Quantity
+	product.Quantity += movement.Quantity
+	if err := tx.Model(product).Update("quantity", product.Quantity).Error; err != nil {
+		return nil, err
+	}
+	if err := auditMovement(tx, product, movement, before); err != nil {
+		return nil, err
+	}
+
+	if product.SerialTracked {
+		if err := checkSerialCount(tx, product); err != nil {
+			return nil, err
+		}
+	}
+
+	movements := []*models.StockMovement{movement}
+	if product.LotTracked {
+		if movements, err = applyLotMovement(tx, movement); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range movements {
+		if err := tx.Create(m).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if movement.Quantity < 0 {
+		if err := trimBins(tx, product); err != nil {
+			return nil, err
+		}
+	}
+	if movement.Quantity > 0 {
+		if err := allocateBackorders(tx, product); err != nil {
+			return nil, err
+		}
+	}
+	return product, nil
+}
+
+// auditMovement records a change of a product's on-hand quantity as a
+// stock.<movement type> entry against the product.
+func auditMovement(tx *gorm.DB, product *models.Product, movement *models.StockMovement, before int) error {
+	changes := map[string]models.AuditChange{
+		"quantity": {Before: before, After: product.Quantity},
+	}
+	if movement.Reference != "" {
+		changes["reference"] = models.AuditChange{After: movement.Reference}
+	}
+	if movement.LotID != nil {
+		changes["lot_id"] = models.AuditChange{After: movement.LotID}
+	}
+	entry := audit.Entry{
+		OrgID:      &product.OrgID,
+		Action:     "stock." + movement.Type,
+		EntityType: "stock",
+		EntityID:   product.ID.String(),
+		Changes:    changes,
+	}
+	if movement.UserID != uuid.Nil {
+		entry.ActorID = &movement.UserID
+	}
+	return audit.Record(tx, entry)
+}
+
+// availableQuantity is how much of a product is free to use now: on-hand
+// less reserved, not counting stock in expired lots.
+func availableQuantity(db *gorm.DB, product *models.Product) (int, error) {
+	return freeQuantity(db, product, time.Now())
+}
+
+// reservableQuantity is how much of a product can still be reserved for a
+// sales order. Lots that expire within the reservation window are left out,
+// so a reservation is not left holding stock that can no longer be shipped.
+func reservableQuantity(db *gorm.DB, product *models.Product) (int, error) {
+	return freeQuantity(db, product, time.Now().Add(reservationTTL()))
+}
+
+// freeQuantity is the product's unreserved stock, counting for lot-tracked
+// products only the unpinned units of lots still good after goodUntil.
+func freeQuantity(db *gorm.DB, product *models.Product, goodUntil time.Time) (int, error) {
+	if !product.LotTracked {
+		return product.Available(), nil
+	}
+	var free int
+	err := db.Model(&models.Lot{}).
+		Where("product_id = ? AND (expires_at IS NULL OR expires_at > ?)", product.ID, goodUntil).
+		Select("COALESCE(SUM(GREATEST(quantity - reserved, 0)), 0)").Scan(&free).Error
+	// Reservations made before lots were pinned are only on the product.
+	return min(free, product.Available()), err
+}
+
+// holdStock reserves qty units of a product locked by tx. Lot-tracked
+// products also pin the units to lots, so shipping takes the same stock.
+func holdStock(tx *gorm.DB, product *models.Product, qty int) error {
+	if qty == 0 {
+		return nil
+	}
+	product.Reserved += qty
+	if err := tx.Model(product).Update("reserved", product.Reserved).Error; err != nil {
+		return err
+	}
+	if !product.LotTracked {
+		return nil
+	}
+	return pinLots(tx, product.ID, qty, time.Now().Add(reservationTTL()))
+}
+
+// reserveUpTo holds as many of qty units of a product as are available for a
+// sales order and returns how many it reserved.
+func reserveUpTo(tx *gorm.DB, productID uuid.UUID, qty int) (int, error) {
+	product, err := lockProduct(tx, productID)
+	if err != nil {
+		return 0, err
+	}
+	avail, err := reservableQuantity(tx, product)
+	if err != nil {
+		return 0, err
+	}
+	reserved := min(qty, max(avail, 0))
+	return reserved, holdStock(tx, product, reserved)
+}
+
+// releaseStock returns qty previously reserved units to available stock,
+// unpinning them from lots.
+func releaseStock(tx *gorm.DB, productID uuid.UUID, qty int) error {
+	if qty == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.Product{}).Where("id = ?", productID).
+		Update("reserved", gorm.Expr("GREATEST(reserved - ?, 0)", qty)).Error; err != nil {
+		return err
+	}
+	return unpinLots(tx, productID, qty)
+}
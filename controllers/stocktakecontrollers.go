@@ -0,0 +1,391 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const stocktakeFile = "StocktakeController"
+
+// findOrgStocktake loads a stocktake and its lines by the :id path parameter, scoped to the caller's organization.
+func findOrgStocktake(db *gorm.DB, c *fiber.Ctx, orgID uuid.UUID) (*models.Stocktake, error) {
+	stocktakeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid stocktake ID format")
+	}
+	var stocktake models.Stocktake
+	if err := db.Preload("Lines").First(&stocktake, "id = ? AND org_id = ?", stocktakeID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Stocktake not found")
+	}
+	return &stocktake, nil
+}
+
+// inOpenStocktake reports whether a product is frozen by an open stocktake.
+func inOpenStocktake(db *gorm.DB, productID uuid.UUID) (bool, error) {
+	var count int64
+	err := db.Model(&models.StocktakeLine{}).
+		Joins("JOIN stocktakes s ON s.id = stocktake_lines.stocktake_id").
+		Where("stocktake_lines.product_id = ? AND s.status = ?", productID, models.StocktakeStatusOpen).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// stocktakeStep runs step against the locked stocktake at :id inside a
+// transaction, then saves it in status.
+func stocktakeStep(c *fiber.Ctx, function, status string, step func(tx *gorm.DB, stocktake *models.Stocktake, userID uuid.UUID) error) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var stocktake *models.Stocktake
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var err error
+		if stocktake, err = findOrgStocktake(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, orgID); err != nil {
+			return err
+		}
+		if !stocktake.CanTransitionTo(status) {
+			return fiber.NewError(fiber.StatusConflict, "Cannot move stocktake from "+stocktake.Status+" to "+status)
+		}
+		if step != nil {
+			if err := step(tx, stocktake, userID); err != nil {
+				return err
+			}
+		}
+		stocktake.Status = status
+		return tx.Model(stocktake).Updates(map[string]interface{}{
+			"status":       stocktake.Status,
+			"approved_at":  stocktake.ApprovedAt,
+			"cancelled_at": stocktake.CancelledAt,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errInsufficientStock) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Posting the variance would take stock below what is reserved for orders"})
+		}
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+stocktakeFile, zap.String("Function", function), zap.String("Message", "Failed to update stocktake"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+stocktakeFile, zap.String("Function", function), zap.String("Message", "Stocktake status changed"), zap.String("stocktake_id", stocktake.ID.String()), zap.String("status", status))
+	return c.JSON(stocktake)
+}
+
+// CreateStocktake godoc
+// @Summary      Open a stocktake
+// @Description  Freezes a set of products for counting and snapshots their system quantities. Products are taken from product_ids, or all products of the given type, or all products. Serial-tracked products are counted by serial and cannot be included. A product can only be in one open stocktake, and its quantity cannot be overwritten while it is.
+// @Tags         Stocktakes
+// @Accept       json
+// @Produce      json
+// @Param        stocktake  body      models.StocktakeRequest  true  "Stocktake scope"
+// @Success      201        {object}  models.Stocktake
+// @Failure      400        {object}  map[string]string  "Invalid input"
+// @Failure      409        {object}  map[string]string  "Product already in an open stocktake"
+// @Failure      500        {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /stocktakes [post]
+func CreateStocktake(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.StocktakeRequest
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required"})
+	}
+
+	stocktake := models.Stocktake{
+		UserID:   userID,
+		OrgID:    orgID,
+		Name:     strings.TrimSpace(input.Name),
+		Location: strings.TrimSpace(input.Location),
+		Status:   models.StocktakeStatusOpen,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("org_id = ?", orgID)
+		switch {
+		case len(input.ProductIDs) > 0:
+			query = query.Where("id IN ?", input.ProductIDs)
+		case input.Type != "":
+			query = query.Where("type = ?", input.Type)
+		}
+		var products []models.Product
+		if err := query.Order("id").Find(&products).Error; err != nil {
+			return err
+		}
+		if len(input.ProductIDs) > 0 && len(products) != len(input.ProductIDs) {
+			return fiber.NewError(fiber.StatusBadRequest, "One or more products not found")
+		}
+
+		for _, product := range products {
+			if product.SerialTracked {
+				if len(input.ProductIDs) > 0 {
+					return fiber.NewError(fiber.StatusBadRequest, "Serial-tracked product "+product.SKU+" cannot be stocktaken by quantity")
+				}
+				continue
+			}
+			frozen, err := inOpenStocktake(tx, product.ID)
+			if err != nil {
+				return err
+			}
+			if frozen {
+				return fiber.NewError(fiber.StatusConflict, "Product "+product.SKU+" is already in an open stocktake")
+			}
+			stocktake.Lines = append(stocktake.Lines, models.StocktakeLine{
+				ProductID:        product.ID,
+				SnapshotQuantity: product.Quantity,
+			})
+		}
+		if len(stocktake.Lines) == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "No products to count")
+		}
+		return tx.Create(&stocktake).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+stocktakeFile, zap.String("Function", "CreateStocktake"), zap.String("Message", "Database error while creating stocktake"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+stocktakeFile, zap.String("Function", "CreateStocktake"), zap.String("Message", "Stocktake opened"), zap.String("stocktake_id", stocktake.ID.String()), zap.Int("lines", len(stocktake.Lines)))
+	return c.Status(fiber.StatusCreated).JSON(stocktake)
+}
+
+// GetStocktakes godoc
+// @Summary      List stocktakes
+// @Description  Get paginated list of the authenticated user's stocktakes, optionally filtered by status
+// @Tags         Stocktakes
+// @Produce      json
+// @Param        status   query     string  false  "Filter by status"
+// @Param        pagenum  query     int     false  "Page number (default: 1)"
+// @Param        limit    query     int     false  "Items per page (default: 10)"
+// @Success      200      {array}   models.Stocktake
+// @Failure      401      {object}  map[string]string  "Unauthorized"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /stocktakes [get]
+func GetStocktakes(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	query := database.DB.Preload("Lines").Where("org_id = ?", orgID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var stocktakes []models.Stocktake
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&stocktakes).Error; err != nil {
+		logger.Log.Error("Package controllers File "+stocktakeFile, zap.String("Function", "GetStocktakes"), zap.String("Message", "Error retrieving stocktakes"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving stocktakes"})
+	}
+	return c.JSON(stocktakes)
+}
+
+// GetStocktake godoc
+// @Summary      Get a stocktake
+// @Description  Retrieves a stocktake with each line's snapshot quantity, expected quantity at count time, counted quantity, variance and the individual counts submitted
+// @Tags         Stocktakes
+// @Produce      json
+// @Param        id   path      string  true  "Stocktake ID (UUID)"
+// @Success      200  {object}  models.Stocktake
+// @Failure      404  {object}  map[string]string  "Stocktake not found"
+// @Security     BearerAuth
+// @Router       /stocktakes/{id} [get]
+func GetStocktake(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	stocktake, err := findOrgStocktake(database.DB.Preload("Lines.Counts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}), c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(stocktake)
+}
+
+// SubmitStocktakeCounts godoc
+// @Summary      Submit counted quantities
+// @Description  Records counts for products in an open stocktake. Counts from different devices add up, so several counters can split the same product between them; a later count from the same device replaces its earlier one. Every submission is kept. Quantities may be counted in a pack unit and are converted to the base unit. The line's variance is taken against the system quantity at the time of the count.
+// @Tags         Stocktakes
+// @Accept       json
+// @Produce      json
+// @Param        id      path      string                        true  "Stocktake ID (UUID)"
+// @Param        counts  body      models.StocktakeCountRequest  true  "Counted quantities"
+// @Success      200     {object}  models.Stocktake
+// @Failure      400     {object}  map[string]string  "Invalid input or product not in stocktake"
+// @Failure      404     {object}  map[string]string  "Stocktake not found"
+// @Failure      409     {object}  map[string]string  "Stocktake is not open"
+// @Security     BearerAuth
+// @Router       /stocktakes/{id}/counts [post]
+func SubmitStocktakeCounts(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.StocktakeCountRequest
+	if err := c.BodyParser(&input); err != nil || len(input.Counts) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	device := strings.TrimSpace(input.Device)
+	if device == "" {
+		device = "default"
+	}
+
+	var stocktake *models.Stocktake
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		// A shared lock lets devices submit concurrently while blocking
+		// approval or cancellation until they finish.
+		if stocktake, err = findOrgStocktake(tx.Clauses(clause.Locking{Strength: "SHARE"}), c, orgID); err != nil {
+			return err
+		}
+		if stocktake.Status != models.StocktakeStatusOpen {
+			return fiber.NewError(fiber.StatusConflict, "Stocktake is "+stocktake.Status)
+		}
+
+		lines := make(map[uuid.UUID]*models.StocktakeLine, len(stocktake.Lines))
+		for i := range stocktake.Lines {
+			lines[stocktake.Lines[i].ProductID] = &stocktake.Lines[i]
+		}
+		for _, in := range input.Counts {
+			line, ok := lines[in.ProductID]
+			if !ok {
+				return fiber.NewError(fiber.StatusBadRequest, "Product "+in.ProductID.String()+" is not in this stocktake")
+			}
+			if in.Quantity < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "Counted quantity cannot be negative")
+			}
+			var product models.Product
+			if err := tx.First(&product, "id = ?", line.ProductID).Error; err != nil {
+				return err
+			}
+			quantity, err := toBaseUnits(tx, &product, in.Unit, in.Quantity)
+			if err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(line, "id = ?", line.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.StocktakeCount{
+				StocktakeLineID: line.ID,
+				UserID:          userID,
+				Device:          device,
+				Quantity:        quantity,
+			}).Error; err != nil {
+				return err
+			}
+
+			var counted int
+			if err := tx.Raw(`
+				SELECT COALESCE(SUM(quantity), 0) FROM (
+					SELECT DISTINCT ON (device) quantity
+					FROM stocktake_counts
+					WHERE stocktake_line_id = ?
+					ORDER BY device, created_at DESC
+				) latest`, line.ID).Scan(&counted).Error; err != nil {
+				return err
+			}
+			line.CountedQuantity = &counted
+			line.ExpectedQuantity = &product.Quantity
+			line.Variance = counted - product.Quantity
+			if err := tx.Model(line).Updates(map[string]interface{}{
+				"expected_quantity": product.Quantity,
+				"counted_quantity":  counted,
+				"variance":          line.Variance,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+stocktakeFile, zap.String("Function", "SubmitStocktakeCounts"), zap.String("Message", "Failed to record counts"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+stocktakeFile, zap.String("Function", "SubmitStocktakeCounts"), zap.String("Message", "Counts recorded"), zap.String("stocktake_id", stocktake.ID.String()), zap.String("device", device), zap.Int("count", len(input.Counts)))
+	return c.JSON(stocktake)
+}
+
+// ApproveStocktake godoc
+// @Summary      Approve a stocktake
+// @Description  Posts an adjustment movement for every counted line with a variance. The variance (counted less the system quantity when the line was last counted) is applied to the current quantity, so movements made after counting are kept and movements made before it are not applied twice. Uncounted lines are left unchanged. Unfreezes the products.
+// @Tags         Stocktakes
+// @Produce      json
+// @Param        id   path      string  true  "Stocktake ID (UUID)"
+// @Success      200  {object}  models.Stocktake
+// @Failure      404  {object}  map[string]string  "Stocktake not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition or variance would take stock below what is reserved"
+// @Security     BearerAuth
+// @Router       /stocktakes/{id}/approve [post]
+func ApproveStocktake(c *fiber.Ctx) error {
+	return stocktakeStep(c, "ApproveStocktake", models.StocktakeStatusApproved, func(tx *gorm.DB, stocktake *models.Stocktake, userID uuid.UUID) error {
+		for _, line := range stocktake.Lines {
+			if line.CountedQuantity == nil || line.Variance == 0 {
+				continue
+			}
+			if _, err := applyMovement(tx, &models.StockMovement{
+				ProductID: line.ProductID,
+				UserID:    userID,
+				Type:      models.MovementAdjustment,
+				Quantity:  line.Variance,
+				Reference: "stocktake:" + stocktake.ID.String(),
+			}); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		stocktake.ApprovedAt = &now
+		return nil
+	})
+}
+
+// CancelStocktake godoc
+// @Summary      Cancel a stocktake
+// @Description  Abandons an open stocktake without changing stock and unfreezes its products. Submitted counts are kept for reference.
+// @Tags         Stocktakes
+// @Produce      json
+// @Param        id   path      string  true  "Stocktake ID (UUID)"
+// @Success      200  {object}  models.Stocktake
+// @Failure      404  {object}  map[string]string  "Stocktake not found"
+// @Failure      409  {object}  map[string]string  "Invalid status transition"
+// @Security     BearerAuth
+// @Router       /stocktakes/{id}/cancel [post]
+func CancelStocktake(c *fiber.Ctx) error {
+	return stocktakeStep(c, "CancelStocktake", models.StocktakeStatusCancelled, func(tx *gorm.DB, stocktake *models.Stocktake, _ uuid.UUID) error {
+		now := time.Now()
+		stocktake.CancelledAt = &now
+		return nil
+	})
+}
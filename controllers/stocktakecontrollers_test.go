@@ -0,0 +1,71 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestStocktakeVariance(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	shirt := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Price: 10, Quantity: 10})
+	socks := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Socks", SKU: "SO-1", Price: 2, Quantity: 5})
+
+	var stocktake models.Stocktake
+	open := models.StocktakeRequest{Name: "Aisle 4", ProductIDs: []uuid.UUID{shirt, socks}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/stocktakes", bearer, open, &stocktake); status != fiber.StatusCreated {
+		t.Fatalf("open stocktake: status %d", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/stocktakes", bearer, models.StocktakeRequest{Name: "Again", ProductIDs: []uuid.UUID{shirt}}, nil); status != fiber.StatusConflict {
+		t.Errorf("second stocktake over the same product: status %d, want 409", status)
+	}
+	quantityPath := "/products/" + shirt.String() + "/quantity"
+	if status := testdb.Call(t, app, fiber.MethodPut, quantityPath, bearer, models.QuantityUpdateRequest{Quantity: 20}, nil); status != fiber.StatusConflict {
+		t.Errorf("adjusting a frozen product: status %d, want 409", status)
+	}
+
+	path := "/stocktakes/" + stocktake.ID.String()
+	count := func(device string, product uuid.UUID, quantity int) {
+		t.Helper()
+		body := models.StocktakeCountRequest{Device: device, Counts: []models.StocktakeCountLine{{ProductID: product, Quantity: quantity}}}
+		if status := testdb.Call(t, app, fiber.MethodPost, path+"/counts", bearer, body, nil); status != fiber.StatusOK {
+			t.Fatalf("count from %s: status %d", device, status)
+		}
+	}
+	// Two devices count the shirts; the first recounts, replacing its count.
+	count("scanner-1", shirt, 4)
+	count("scanner-2", shirt, 5)
+	count("scanner-1", shirt, 3)
+	count("scanner-1", socks, 5)
+
+	// Two shirts ship after counting and must not be counted twice.
+	order := newSalesOrder(t, app, bearer, shirt, 2)
+	for _, step := range []string{"confirm", "pick", "pack", "ship"} {
+		if status := salesOrderStep(t, app, bearer, order, step); status != fiber.StatusOK {
+			t.Fatalf("%s: status %d", step, status)
+		}
+	}
+
+	if status := testdb.Call(t, app, fiber.MethodPost, path+"/approve", bearer, nil, &stocktake); status != fiber.StatusOK {
+		t.Fatalf("approve: status %d", status)
+	}
+	for _, line := range stocktake.Lines {
+		want := map[uuid.UUID]int{shirt: -2, socks: 0}[line.ProductID]
+		if line.Variance != want {
+			t.Errorf("variance for %s = %d, want %d", line.ProductID, line.Variance, want)
+		}
+	}
+	if got := productQuantity(t, shirt); got != 6 {
+		t.Errorf("shirts = %d, want 10 less 2 shipped less the variance of 2", got)
+	}
+	if got := productQuantity(t, socks); got != 5 {
+		t.Errorf("socks = %d, want 5", got)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPut, quantityPath, bearer, models.QuantityUpdateRequest{Quantity: 20}, nil); status != fiber.StatusOK {
+		t.Errorf("adjusting after approval: status %d, want 200", status)
+	}
+}
@@ -0,0 +1,391 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const supplierFile = "SupplierController"
+
+func validateSupplierRequest(input *models.SupplierRequest) string {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Currency = strings.ToUpper(strings.TrimSpace(input.Currency))
+	if input.Currency == "" {
+		input.Currency = "USD"
+	}
+	switch {
+	case input.Name == "":
+		return "name is required"
+	case input.LeadTimeDays < 0:
+		return "lead_time_days cannot be negative"
+	case len(input.Currency) != 3:
+		return "currency must be a 3-letter ISO code"
+	}
+	return ""
+}
+
+// findOrgSupplier loads a supplier by the :id path parameter, scoped to the caller's organization.
+func findOrgSupplier(c *fiber.Ctx, orgID uuid.UUID) (*models.Supplier, error) {
+	supplierID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid supplier ID format")
+	}
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, "id = ? AND org_id = ?", supplierID, orgID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Supplier not found")
+	}
+	return &supplier, nil
+}
+
+// CreateSupplier godoc
+// @Summary      Create a supplier
+// @Description  Adds a supplier with contact details, lead time, currency and payment terms
+// @Tags         Suppliers
+// @Accept       json
+// @Produce      json
+// @Param        supplier  body      models.SupplierRequest  true  "Supplier details"
+// @Success      201       {object}  models.Supplier
+// @Failure      400       {object}  map[string]string  "Invalid input"
+// @Failure      401       {object}  map[string]string  "Unauthorized"
+// @Failure      500       {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /suppliers [post]
+func CreateSupplier(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var input models.SupplierRequest
+	if err := c.BodyParser(&input); err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "CreateSupplier"), zap.String("Message", "Failed to parse request body"), zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if msg := validateSupplierRequest(&input); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	supplier := models.Supplier{
+		UserID:       userID,
+		OrgID:        orgID,
+		Name:         input.Name,
+		Contact:      input.Contact,
+		LeadTimeDays: input.LeadTimeDays,
+		Currency:     input.Currency,
+		PaymentTerms: input.PaymentTerms,
+	}
+	if err := database.DB.Create(&supplier).Error; err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "CreateSupplier"), zap.String("Message", "Database error while creating supplier"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving supplier"})
+	}
+
+	logger.Log.Info("Package controllers File "+supplierFile, zap.String("Function", "CreateSupplier"), zap.String("Message", "Supplier created"), zap.String("supplier_id", supplier.ID.String()))
+	return c.Status(fiber.StatusCreated).JSON(supplier)
+}
+
+// GetSuppliers godoc
+// @Summary      List suppliers
+// @Description  Get paginated list of the authenticated user's suppliers
+// @Tags         Suppliers
+// @Produce      json
+// @Param        pagenum  query     int  false  "Page number (default: 1)"
+// @Param        limit    query     int  false  "Items per page (default: 10)"
+// @Success      200      {array}   models.Supplier
+// @Failure      401      {object}  map[string]string  "Unauthorized"
+// @Failure      500      {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /suppliers [get]
+func GetSuppliers(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	pageNumber := c.QueryInt("pagenum", 1)
+	if pageNumber <= 0 {
+		pageNumber = 1
+	}
+	limit := c.QueryInt("limit", 10)
+	offset := (pageNumber - 1) * limit
+
+	var suppliers []models.Supplier
+	if err := database.DB.Where("org_id = ?", orgID).Order("name").
+		Limit(limit).Offset(offset).
+		Find(&suppliers).Error; err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "GetSuppliers"), zap.String("Message", "Error retrieving suppliers"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving suppliers"})
+	}
+	return c.JSON(suppliers)
+}
+
+// GetSupplier godoc
+// @Summary      Get a supplier
+// @Description  Retrieves a supplier by ID
+// @Tags         Suppliers
+// @Produce      json
+// @Param        id   path      string  true  "Supplier ID (UUID)"
+// @Success      200  {object}  models.Supplier
+// @Failure      400  {object}  map[string]string  "Invalid supplier ID"
+// @Failure      404  {object}  map[string]string  "Supplier not found"
+// @Security     BearerAuth
+// @Router       /suppliers/{id} [get]
+func GetSupplier(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	supplier, err := findOrgSupplier(c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(supplier)
+}
+
+// UpdateSupplier godoc
+// @Summary      Update a supplier
+// @Description  Replaces a supplier's details
+// @Tags         Suppliers
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string                  true  "Supplier ID (UUID)"
+// @Param        supplier  body      models.SupplierRequest  true  "Supplier details"
+// @Success      200       {object}  models.Supplier
+// @Failure      400       {object}  map[string]string  "Invalid input"
+// @Failure      404       {object}  map[string]string  "Supplier not found"
+// @Failure      500       {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /suppliers/{id} [put]
+func UpdateSupplier(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	supplier, err := findOrgSupplier(c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var input models.SupplierRequest
+	if err := c.BodyParser(&input); err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "UpdateSupplier"), zap.String("Message", "Failed to parse request body"), zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if msg := validateSupplierRequest(&input); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	supplier.Name = input.Name
+	supplier.Contact = input.Contact
+	supplier.LeadTimeDays = input.LeadTimeDays
+	supplier.Currency = input.Currency
+	supplier.PaymentTerms = input.PaymentTerms
+	if err := database.DB.Save(supplier).Error; err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "UpdateSupplier"), zap.String("Message", "Failed to update supplier"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update supplier"})
+	}
+	return c.JSON(supplier)
+}
+
+// DeleteSupplier godoc
+// @Summary      Delete a supplier
+// @Description  Deletes a supplier and its product links. A supplier with draft, submitted or partially received purchase orders cannot be deleted.
+// @Tags         Suppliers
+// @Param        id   path  string  true  "Supplier ID (UUID)"
+// @Success      204
+// @Failure      404  {object}  map[string]string  "Supplier not found"
+// @Failure      409  {object}  map[string]string  "Supplier has open purchase orders"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /suppliers/{id} [delete]
+func DeleteSupplier(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	supplier, err := findOrgSupplier(c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Stock is still expected from open purchase orders, so the supplier stays until they are finished.
+		var open int64
+		if err := tx.Model(&models.PurchaseOrder{}).
+			Where("supplier_id = ? AND status IN ?", supplier.ID, []string{models.POStatusDraft, models.POStatusSubmitted, models.POStatusPartiallyReceived}).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Supplier has open purchase orders; cancel or close them first")
+		}
+		if err := tx.Where("supplier_id = ?", supplier.ID).Delete(&models.SupplierProduct{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(supplier).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			return sendError(c, err)
+		}
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "DeleteSupplier"), zap.String("Message", "Failed to delete supplier"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete supplier"})
+	}
+
+	logger.Log.Info("Package controllers File "+supplierFile, zap.String("Function", "DeleteSupplier"), zap.String("Message", "Supplier deleted"), zap.String("supplier_id", supplier.ID.String()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetSupplierProducts godoc
+// @Summary      List a supplier's catalogue
+// @Description  Lists the products linked to a supplier with the supplier's SKU, cost price and minimum order quantity
+// @Tags         Suppliers
+// @Produce      json
+// @Param        id   path      string  true  "Supplier ID (UUID)"
+// @Success      200  {array}   models.SupplierProduct
+// @Failure      404  {object}  map[string]string  "Supplier not found"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /suppliers/{id}/products [get]
+func GetSupplierProducts(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	supplier, err := findOrgSupplier(c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var links []models.SupplierProduct
+	if err := database.DB.Where("supplier_id = ?", supplier.ID).Find(&links).Error; err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "GetSupplierProducts"), zap.String("Message", "Error retrieving supplier products"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving supplier products"})
+	}
+	return c.JSON(links)
+}
+
+// LinkSupplierProduct godoc
+// @Summary      Link a product to a supplier
+// @Description  Creates or updates the supplier's catalogue entry for a product. Marking it preferred clears the flag on the product's other suppliers.
+// @Tags         Suppliers
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string                         true  "Supplier ID (UUID)"
+// @Param        productId   path      string                         true  "Product ID (UUID)"
+// @Param        link        body      models.SupplierProductRequest  true  "Catalogue terms"
+// @Success      200         {object}  models.SupplierProduct
+// @Failure      400         {object}  map[string]string  "Invalid input"
+// @Failure      404         {object}  map[string]string  "Supplier or product not found"
+// @Failure      500         {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /suppliers/{id}/products/{productId} [put]
+func LinkSupplierProduct(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	supplier, err := findOrgSupplier(c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	productID, err := uuid.Parse(c.Params("productId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product ID format"})
+	}
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", productID, orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	var input models.SupplierProductRequest
+	if err := c.BodyParser(&input); err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "LinkSupplierProduct"), zap.String("Message", "Failed to parse request body"), zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.MinOrderQty == 0 {
+		input.MinOrderQty = 1
+	}
+	if input.PackSize == 0 {
+		input.PackSize = 1
+	}
+	if input.CostPrice < 0 || input.MinOrderQty < 0 || input.PackSize < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cost_price, min_order_qty and pack_size cannot be negative"})
+	}
+
+	var link models.SupplierProduct
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if input.Preferred {
+			if err := tx.Model(&models.SupplierProduct{}).
+				Where("product_id = ? AND supplier_id <> ?", product.ID, supplier.ID).
+				Update("preferred", false).Error; err != nil {
+				return err
+			}
+		}
+		err := tx.Where("supplier_id = ? AND product_id = ?", supplier.ID, product.ID).First(&link).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		link.SupplierID = supplier.ID
+		link.ProductID = product.ID
+		link.SupplierSKU = input.SupplierSKU
+		link.CostPrice = input.CostPrice
+		link.MinOrderQty = input.MinOrderQty
+		link.PackSize = input.PackSize
+		link.Preferred = input.Preferred
+		return tx.Save(&link).Error
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+supplierFile, zap.String("Function", "LinkSupplierProduct"), zap.String("Message", "Failed to save supplier product"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to link product"})
+	}
+	return c.JSON(link)
+}
+
+// UnlinkSupplierProduct godoc
+// @Summary      Unlink a product from a supplier
+// @Description  Removes the supplier's catalogue entry for a product
+// @Tags         Suppliers
+// @Param        id          path  string  true  "Supplier ID (UUID)"
+// @Param        productId   path  string  true  "Product ID (UUID)"
+// @Success      204
+// @Failure      404  {object}  map[string]string  "Supplier or link not found"
+// @Security     BearerAuth
+// @Router       /suppliers/{id}/products/{productId} [delete]
+func UnlinkSupplierProduct(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+	supplier, err := findOrgSupplier(c, orgID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	result := database.DB.Where("supplier_id = ? AND product_id = ?", supplier.ID, c.Params("productId")).Delete(&models.SupplierProduct{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier product not found"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// productSuppliers lists the suppliers of a product, preferred first.
+func productSuppliers(productID uuid.UUID) ([]models.ProductSupplier, error) {
+	suppliers := []models.ProductSupplier{}
+	err := database.DB.Table("supplier_products sp").
+		Select("s.id AS supplier_id, s.name, sp.supplier_sku, sp.cost_price, s.currency, sp.min_order_qty, s.lead_time_days, sp.preferred").
+		Joins("JOIN suppliers s ON s.id = sp.supplier_id").
+		Where("sp.product_id = ?", productID).
+		Order("sp.preferred DESC, sp.cost_price ASC").
+		Scan(&suppliers).Error
+	return suppliers, err
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/lokesh2201013/models"
+)
+
+func TestValidateSupplierRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        models.SupplierRequest
+		want         string
+		wantCurrency string
+	}{
+		{"valid", models.SupplierRequest{Name: "Acme", Currency: "eur"}, "", "EUR"},
+		{"currency defaults to USD", models.SupplierRequest{Name: "Acme"}, "", "USD"},
+		{"blank name", models.SupplierRequest{Name: "  "}, "name is required", "USD"},
+		{"negative lead time", models.SupplierRequest{Name: "Acme", LeadTimeDays: -1}, "lead_time_days cannot be negative", "USD"},
+		{"currency not an ISO code", models.SupplierRequest{Name: "Acme", Currency: "euro"}, "currency must be a 3-letter ISO code", "EURO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			if got := validateSupplierRequest(&input); got != tt.want {
+				t.Errorf("validateSupplierRequest = %q, want %q", got, tt.want)
+			}
+			if input.Currency != tt.wantCurrency {
+				t.Errorf("currency = %q, want %q", input.Currency, tt.wantCurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestPreferredSupplier(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Price: 10})
+
+	var acme, globex models.Supplier
+	if status := testdb.Call(t, app, fiber.MethodPost, "/suppliers", bearer, models.SupplierRequest{Name: "Acme"}, &acme); status != fiber.StatusCreated {
+		t.Fatalf("create supplier: status %d", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/suppliers", bearer, models.SupplierRequest{Name: "Globex", Currency: "eur"}, &globex); status != fiber.StatusCreated {
+		t.Fatalf("create supplier: status %d", status)
+	}
+	link := func(supplier models.Supplier, terms models.SupplierProductRequest) {
+		t.Helper()
+		path := "/suppliers/" + supplier.ID.String() + "/products/" + product.String()
+		if status := testdb.Call(t, app, fiber.MethodPut, path, bearer, terms, nil); status != fiber.StatusOK {
+			t.Fatalf("link %s: status %d", supplier.Name, status)
+		}
+	}
+	link(acme, models.SupplierProductRequest{CostPrice: 5, Preferred: true})
+	link(globex, models.SupplierProductRequest{CostPrice: 4, MinOrderQty: 12})
+	// Preferring Globex takes the flag off Acme.
+	link(globex, models.SupplierProductRequest{CostPrice: 4, MinOrderQty: 12, Preferred: true})
+
+	var detail models.ProductDetail
+	if status := testdb.Call(t, app, fiber.MethodGet, "/products/by-id?product_id="+product.String(), bearer, nil, &detail); status != fiber.StatusOK {
+		t.Fatalf("get product: status %d", status)
+	}
+	if len(detail.Suppliers) != 2 {
+		t.Fatalf("suppliers = %+v, want two", detail.Suppliers)
+	}
+	first, second := detail.Suppliers[0], detail.Suppliers[1]
+	if first.SupplierID != globex.ID || !first.Preferred || first.Currency != "EUR" || first.MinOrderQty != 12 {
+		t.Errorf("first supplier = %+v, want preferred Globex in EUR with a minimum of 12", first)
+	}
+	if second.SupplierID != acme.ID || second.Preferred || second.MinOrderQty != 1 {
+		t.Errorf("second supplier = %+v, want Acme, no longer preferred, with the default minimum of 1", second)
+	}
+
+	_, other := testdb.SignUp(t, app)
+	if status := testdb.Call(t, app, fiber.MethodGet, "/suppliers/"+acme.ID.String(), other, nil, nil); status != fiber.StatusNotFound {
+		t.Errorf("another user's supplier: status %d, want 404", status)
+	}
+}
+
+func TestDeleteSupplierWithOpenPurchaseOrders(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	supplier := newSupplier(t, app, bearer, "Acme")
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Shirt", SKU: "SH-1", Price: 10})
+
+	var po models.PurchaseOrder
+	order := models.PurchaseOrderRequest{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLineRequest{{ProductID: product, Quantity: 10, UnitCost: 5}}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/purchase-orders", bearer, order, &po); status != fiber.StatusCreated {
+		t.Fatalf("create purchase order: status %d", status)
+	}
+	path := "/suppliers/" + supplier.ID.String()
+	if status := testdb.Call(t, app, fiber.MethodDelete, path, bearer, nil, nil); status != fiber.StatusConflict {
+		t.Errorf("deleting with a draft purchase order: status %d, want 409", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/purchase-orders/"+po.ID.String()+"/cancel", bearer, nil, nil); status != fiber.StatusOK {
+		t.Fatalf("cancel: status %d", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodDelete, path, bearer, nil, nil); status != fiber.StatusNoContent {
+		t.Errorf("deleting once the order is cancelled: status %d, want 204", status)
+	}
+}
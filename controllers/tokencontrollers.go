@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const tokenFile = "TokenController"
+
+// defaultRefreshTokenTTL is the refresh token lifetime when REFRESH_TOKEN_TTL
+// is unset. Each rotation extends the session by this much.
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+func refreshTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultRefreshTokenTTL
+}
+
+// hashToken is the stored form of an opaque token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes, URL-safe base64 encoded.
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// issueTokens creates the next refresh token of session and a new access
+// token for user within it, and slides the session's expiry forward.
+func issueTokens(tx *gorm.DB, user *models.User, session *models.Session) (*models.TokenResponse, error) {
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(refreshTokenTTL())
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: expiresAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+	if err := tx.Model(session).Updates(map[string]interface{}{
+		"last_used_at": session.LastUsedAt,
+		"expires_at":   session.ExpiresAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	access, err := accessToken(tx, user, session)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// accessToken signs an access token for user in session's organization, with
+// the permissions of the user's role there. If the user is no longer a member
+// the session moves to one of their other organizations. When the
+// organization requires two-factor authentication and the session was opened
+// without it, the token says so and routes refuse it.
+func accessToken(tx *gorm.DB, user *models.User, session *models.Session) (string, error) {
+	membership, err := sessionMembership(tx, user, session.OrgID)
+	if err != nil {
+		return "", err
+	}
+	if membership.OrgID != session.OrgID {
+		session.OrgID = membership.OrgID
+		if err := tx.Model(session).Update("org_id", session.OrgID).Error; err != nil {
+			return "", err
+		}
+	}
+	var org models.Organization
+	if err := tx.Select("id", "require_2fa").First(&org, "id = ?", membership.OrgID).Error; err != nil {
+		return "", err
+	}
+	return utils.GenerateJWT(utils.AccessClaims{
+		UserID:        user.UserID,
+		Name:          user.Username,
+		SessionID:     session.ID,
+		OrgID:         membership.OrgID,
+		Role:          membership.Role,
+		Permissions:   models.RolePermissions(membership.Role),
+		EmailVerified: user.EmailVerifiedAt != nil,
+		MFARequired:   org.Require2FA && !session.MFA,
+	})
+}
+
+// startSession opens a new session for user from the current request and
+// issues its first tokens. mfa records that the user proved a second factor.
+func startSession(c *fiber.Ctx, user *models.User, mfa bool) (*models.TokenResponse, error) {
+	var tokens *models.TokenResponse
+	err := audit.Transaction(database.DB, func(tx *gorm.DB) error {
+		var err error
+		tokens, err = openSession(tx, c, user, mfa)
+		return err
+	})
+	return tokens, err
+}
+
+// openSession is startSession within an existing transaction.
+func openSession(tx *gorm.DB, c *fiber.Ctx, user *models.User, mfa bool) (*models.TokenResponse, error) {
+	return openSessionIn(tx, c, user, uuid.Nil, mfa)
+}
+
+// openSessionIn is openSession starting in orgID rather than the user's
+// personal organization, if they are a member of it.
+func openSessionIn(tx *gorm.DB, c *fiber.Ctx, user *models.User, orgID uuid.UUID, mfa bool) (*models.TokenResponse, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.UserID,
+		OrgID:      orgID,
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		IP:         c.IP(),
+		MFA:        mfa,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL()),
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return issueTokens(tx, user, &session)
+}
+
+// revokeAccessToken adds the caller's access token ID to the revocation list
+// until it would have expired anyway.
+func revokeAccessToken(tx *gorm.DB, c *fiber.Ctx) error {
+	jti, _ := c.Locals("jti").(string)
+	if jti == "" {
+		return nil
+	}
+	expiresAt, ok := c.Locals("exp").(time.Time)
+	if !ok {
+		expiresAt = time.Now().Add(utils.AccessTokenTTL())
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// revokeUserSessions revokes every open session of userID, and with them all
+// of the user's refresh and access tokens, returning how many were open.
+func revokeUserSessions(tx *gorm.DB, userID uuid.UUID) (int64, error) {
+	result := tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// RefreshAccessToken godoc
+// @Summary      Refresh an access token
+// @Description  Exchanges a refresh token for a new access token and a new refresh token. Each refresh token can be used once; presenting a used one again is treated as theft and revokes the whole session, including access tokens issued under it.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        token  body      models.RefreshRequest  true  "Refresh token"
+// @Success      200    {object}  models.TokenResponse
+// @Failure      400    {object}  map[string]string  "Invalid input"
+// @Failure      401    {object}  map[string]string  "Invalid, expired, revoked or reused refresh token"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Router       /token/refresh [post]
+func RefreshAccessToken(c *fiber.Ctx) error {
+	var input models.RefreshRequest
+	if err := c.BodyParser(&input); err != nil || input.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "refresh_token is required"})
+	}
+
+	var tokens *models.TokenResponse
+	var session models.Session
+	reused := false
+	err := audit.Transaction(database.DB, func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&token, "token_hash = ?", hashToken(input.RefreshToken)).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ?", token.SessionID).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+		}
+		now := time.Now()
+		if session.RevokedAt != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Session has been revoked")
+		}
+		if token.UsedAt != nil {
+			// The token was already rotated, so someone else holds a copy:
+			// revoke the whole family. Returning nil commits the revocation.
+			reused = true
+			return tx.Model(&session).Update("revoked_at", now).Error
+		}
+		if now.After(token.ExpiresAt) || now.After(session.ExpiresAt) {
+			return fiber.NewError(fiber.StatusUnauthorized, "Refresh token has expired")
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, "user_id = ?", session.UserID).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+		}
+		var err error
+		tokens, err = issueTokens(tx, &user, &session)
+		return err
+	})
+	if err == nil && reused {
+		logger.Log.Warn("Package controllers File "+tokenFile, zap.String("Function", "RefreshAccessToken"), zap.String("Message", "Refresh token reuse detected, session revoked"), zap.String("session_id", session.ID.String()), zap.String("ip", c.IP()))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token has already been used; session revoked"})
+	}
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+tokenFile, zap.String("Function", "RefreshAccessToken"), zap.String("Message", "Failed to refresh token"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+	return c.JSON(tokens)
+}
+
+// Logout godoc
+// @Summary      Log out
+// @Description  Revokes the current session: the access token used for this request and every refresh and access token issued in the same session stop working
+// @Tags         Auth
+// @Produce      json
+// @Success      204
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /logout [post]
+func Logout(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	sid, _ := c.Locals("sid").(string)
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid session"})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return revokeAccessToken(tx, c)
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+tokenFile, zap.String("Function", "Logout"), zap.String("Message", "Failed to revoke session"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Log.Info("Package controllers File "+tokenFile, zap.String("Function", "Logout"), zap.String("Message", "Session revoked"), zap.String("session_id", sessionID.String()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// LogoutAll godoc
+// @Summary      Log out everywhere
+// @Description  Revokes every session of the authenticated user, so all of their refresh and access tokens stop working
+// @Tags         Auth
+// @Produce      json
+// @Success      204
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /logout-all [post]
+func LogoutAll(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var revoked int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if revoked, err = revokeUserSessions(tx, userID); err != nil {
+			return err
+		}
+		return revokeAccessToken(tx, c)
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+tokenFile, zap.String("Function", "LogoutAll"), zap.String("Message", "Failed to revoke sessions"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Log.Info("Package controllers File "+tokenFile, zap.String("Function", "LogoutAll"), zap.String("Message", "All sessions revoked"), zap.String("user_id", userID.String()), zap.Int64("sessions", revoked))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetJWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  Publishes the public keys access tokens may be signed with, so other services can verify them by the kid in the token header. Empty when tokens are signed with a shared HS256 secret.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  utils.JWKSet
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Router       /.well-known/jwks.json [get]
+func GetJWKS(c *fiber.Ctx) error {
+	set, err := utils.PublicJWKS()
+	if err != nil {
+		logger.Log.Error("Package controllers File "+tokenFile, zap.String("Function", "GetJWKS"), zap.String("Message", "Failed to load signing keys"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(set)
+}
+
+// StartTokenCleanupJob periodically deletes expired sessions, their refresh
+// tokens, revoked access token IDs that have expired anyway, expired account
+// tokens, login challenges and single sign-on logins, and failed login counts
+// untouched for a day.
+func StartTokenCleanupJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			cleanupTokens()
+		}
+	}()
+}
+
+func cleanupTokens() {
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&models.AccountToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&models.LoginChallenge{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&models.SSOLoginState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("last_failed_at < ? AND (blocked_until IS NULL OR blocked_until < ?)", now.Add(-24*time.Hour), now).
+			Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+		expired := tx.Model(&models.Session{}).Select("id").Where("expires_at < ?", now)
+		if err := tx.Where("session_id IN (?)", expired).Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at < ?", now).Delete(&models.Session{}).Error
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+tokenFile, zap.String("Function", "cleanupTokens"), zap.String("Message", "Failed to delete expired tokens"), zap.Error(err))
+	}
+}
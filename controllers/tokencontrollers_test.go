@@ -0,0 +1,51 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	app := testdb.App(t)
+	user, _ := testdb.SignUp(t, app)
+	first := testdb.Login(t, app, user.Username)
+
+	refresh := func(token string, out *models.TokenResponse) int {
+		return testdb.Call(t, app, fiber.MethodPost, "/token/refresh", "", models.RefreshRequest{RefreshToken: token}, out)
+	}
+
+	var second models.TokenResponse
+	if status := refresh(first.RefreshToken, &second); status != fiber.StatusOK {
+		t.Fatalf("first refresh: status %d, want 200", status)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+
+	steps := []struct {
+		name   string
+		token  string
+		bearer string
+		path   string
+		want   int
+	}{
+		{"replaying the rotated refresh token", first.RefreshToken, "", "", fiber.StatusUnauthorized},
+		{"the current refresh token after reuse", second.RefreshToken, "", "", fiber.StatusUnauthorized},
+		{"the access token after reuse", "", second.AccessToken, "/products", fiber.StatusUnauthorized},
+		{"an unknown refresh token", "not-a-refresh-token", "", "", fiber.StatusUnauthorized},
+	}
+	for _, step := range steps {
+		var status int
+		if step.path != "" {
+			status = testdb.Call(t, app, fiber.MethodGet, step.path, step.bearer, nil, nil)
+		} else {
+			status = refresh(step.token, nil)
+		}
+		if status != step.want {
+			t.Errorf("%s: status %d, want %d", step.name, status, step.want)
+		}
+	}
+}
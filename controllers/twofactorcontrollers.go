@@ -0,0 +1,607 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/utils"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const twoFactorFile = "TwoFactorController"
+
+const (
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+// totpOpts are the parameters authenticator apps assume by default.
+var totpOpts = totp.ValidateOpts{Period: 30, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+var errInvalidCode = fiber.NewError(fiber.StatusBadRequest, "Invalid code")
+
+// totpIssuer names the service in authenticator apps, from TOTP_ISSUER.
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Inventory"
+}
+
+// countWrongCode counts a wrong code for a signed-in user's own account
+// towards its login throttle, after the transaction that checked it has
+// rolled back.
+func countWrongCode(c *fiber.Ctx, function, username string) {
+	if err := recordLoginFailure(database.DB, username, c.IP()); err != nil {
+		logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", function), zap.String("Message", "Failed to record wrong code"), zap.Error(err))
+	}
+	logger.Log.Warn("Package controllers File "+twoFactorFile, zap.String("Function", function), zap.String("Message", "Wrong second factor"), zap.String("username", username), zap.String("ip", c.IP()))
+}
+
+// findTwoFactor locks userID's authenticator. With confirmed set, one that
+// has not been confirmed yet is treated as missing.
+func findTwoFactor(tx *gorm.DB, userID uuid.UUID, confirmed bool) (*models.TwoFactor, error) {
+	var tf models.TwoFactor
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID)
+	if confirmed {
+		query = query.Where("confirmed_at IS NOT NULL")
+	}
+	if err := query.First(&tf).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Two-factor authentication is not set up")
+		}
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// matchTOTP returns the time step code is valid for at now. It accepts the
+// current step or one either side of it, to allow for clock drift, but only
+// steps after lastStep, so each code works once.
+func matchTOTP(secret string, lastStep int64, code string, now time.Time) (int64, bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpOpts.Digits.Length() {
+		return 0, false, nil
+	}
+	current := now.Unix() / int64(totpOpts.Period)
+	for step := current - 1; step <= current+1; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*int64(totpOpts.Period), 0), totpOpts)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// checkTOTP accepts code if matchTOTP does and records its step as the last
+// one used. tf must be locked.
+func checkTOTP(tx *gorm.DB, tf *models.TwoFactor, code string) (bool, error) {
+	secret, err := utils.OpenTOTPSecret(tf.UserID, tf.Secret)
+	if err != nil {
+		return false, err
+	}
+	step, ok, err := matchTOTP(secret, tf.LastStep, code, time.Now())
+	if err != nil || !ok {
+		return false, err
+	}
+	tf.LastStep = step
+	return true, tx.Model(tf).Update("last_step", step).Error
+}
+
+// normalizeRecoveryCode ignores case, spaces and the separating dash.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// newRecoveryCodes replaces userID's recovery codes with fresh ones and
+// returns them; only their hashes are kept.
+func newRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	buf := make([]byte, 7)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(buf))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(raw)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode spends one of userID's unused recovery codes.
+func useRecoveryCode(tx *gorm.DB, userID uuid.UUID, code string) (bool, error) {
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// verifySecondFactor checks a TOTP code, or failing that a recovery code,
+// for the locked authenticator tf.
+func verifySecondFactor(tx *gorm.DB, tf *models.TwoFactor, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		return checkTOTP(tx, tf, code)
+	}
+	if recoveryCode != "" {
+		return useRecoveryCode(tx, tf.UserID, recoveryCode)
+	}
+	return false, nil
+}
+
+// startLoginChallenge returns a challenge to complete with a second factor if
+// user has confirmed two-factor authentication, or nil if the password alone
+// is enough.
+func startLoginChallenge(user *models.User) (*models.MFAChallenge, error) {
+	var enabled int64
+	if err := database.DB.Model(&models.TwoFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", user.UserID).Count(&enabled).Error; err != nil {
+		return nil, err
+	}
+	if enabled == 0 {
+		return nil, nil
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&models.LoginChallenge{
+		UserID:    user.UserID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(loginChallengeTTL),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &models.MFAChallenge{
+		MFARequired:    true,
+		ChallengeToken: token,
+		ExpiresIn:      int(loginChallengeTTL.Seconds()),
+	}, nil
+}
+
+// LoginMFA godoc
+// @Summary      Complete a two-factor login
+// @Description  Exchanges the challenge token from Login, together with a code from the authenticator app or an unused recovery code, for an access token and a refresh token. A challenge expires after five minutes or five wrong codes. Wrong codes count towards the account's login throttle, and while it is throttled no code is checked.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        login  body      models.MFALoginRequest  true  "Challenge token and code"
+// @Success      200    {object}  models.TokenResponse
+// @Failure      400    {object}  map[string]string  "Invalid input"
+// @Failure      401    {object}  map[string]string  "Invalid or expired challenge, or wrong code"
+// @Failure      429    {object}  map[string]string  "Too many failed logins; see Retry-After"
+// @Failure      500    {object}  map[string]string  "Internal server error"
+// @Router       /login/2fa [post]
+func LoginMFA(c *fiber.Ctx) error {
+	var input models.MFALoginRequest
+	if err := c.BodyParser(&input); err != nil || input.ChallengeToken == "" || (input.Code == "" && input.RecoveryCode == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "challenge_token and code or recovery_code are required"})
+	}
+
+	var tokens *models.TokenResponse
+	var challenge models.LoginChallenge
+	var user models.User
+	wrongCode := false
+	err := audit.Transaction(database.DB, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&challenge, "token_hash = ?", hashToken(input.ChallengeToken)).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired challenge")
+		}
+		now := time.Now()
+		if challenge.UsedAt != nil || now.After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired challenge")
+		}
+		if err := tx.First(&user, "user_id = ?", challenge.UserID).Error; err != nil {
+			return err
+		}
+		if err := checkLoginThrottle(c, tx, user.Username); err != nil {
+			return err
+		}
+		tf, err := findTwoFactor(tx, challenge.UserID, true)
+		if err != nil {
+			return err
+		}
+		ok, err := verifySecondFactor(tx, tf, input.Code, input.RecoveryCode)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Returning nil commits the attempt count.
+			wrongCode = true
+			return tx.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1")).Error
+		}
+		if err := tx.Model(&challenge).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		tokens, err = openSession(tx, c, &user, true)
+		return err
+	})
+	if err == nil && wrongCode {
+		// Wrong codes count towards the account's login throttle too, so
+		// guessing across fresh challenges is slowed like guessing passwords.
+		if err := recordLoginFailure(database.DB, user.Username, c.IP()); err != nil {
+			logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "LoginMFA"), zap.String("Message", "Failed to record failed login"), zap.Error(err))
+		}
+		logger.Log.Warn("Package controllers File "+twoFactorFile, zap.String("Function", "LoginMFA"), zap.String("Message", "Wrong second factor"), zap.String("user_id", challenge.UserID.String()), zap.String("ip", c.IP()))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid code"})
+	}
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "LoginMFA"), zap.String("Message", "Failed to complete login"), zap.Error(err))
+		} else if fe.Code == fiber.StatusNotFound {
+			// Two-factor authentication was turned off after the challenge was issued.
+			err = fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired challenge")
+		}
+		return sendError(c, err)
+	}
+	if err := clearAccountThrottle(database.DB, user.Username); err != nil {
+		logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "LoginMFA"), zap.String("Message", "Failed to clear login throttle"), zap.Error(err))
+	}
+
+	logger.Log.Info("Package controllers File "+twoFactorFile, zap.String("Function", "LoginMFA"), zap.String("Message", "token created"), zap.String("user_id", challenge.UserID.String()))
+	return c.JSON(tokens)
+}
+
+// EnrollTOTP godoc
+// @Summary      Start setting up two-factor authentication
+// @Description  Creates a new TOTP secret for the caller and returns it as an otpauth:// URI and a QR code to scan with an authenticator app. It takes effect once confirmed with a code from the app; enrolling again before then replaces the secret.
+// @Tags         Two-factor authentication
+// @Produce      json
+// @Success      200  {object}  models.TOTPEnrollment
+// @Failure      401  {object}  map[string]string  "Unauthorized"
+// @Failure      409  {object}  map[string]string  "Already enabled"
+// @Failure      500  {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /2fa/enroll [post]
+func EnrollTOTP(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var enrollment models.TOTPEnrollment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, "user_id = ?", userID).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid user ID")
+		}
+		var fe *fiber.Error
+		if _, err := findTwoFactor(tx, userID, true); err == nil {
+			return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+		} else if !errors.As(err, &fe) {
+			return err
+		}
+
+		key, err := totp.Generate(totp.GenerateOpts{
+			Issuer:      totpIssuer(),
+			AccountName: user.Email,
+			Period:      totpOpts.Period,
+			Digits:      totpOpts.Digits,
+			Algorithm:   totpOpts.Algorithm,
+		})
+		if err != nil {
+			return err
+		}
+		img, err := key.Image(256, 256)
+		if err != nil {
+			return err
+		}
+		var qr bytes.Buffer
+		if err := png.Encode(&qr, img); err != nil {
+			return err
+		}
+		enrollment = models.TOTPEnrollment{
+			Secret: key.Secret(),
+			URI:    key.URL(),
+			QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+		}
+		sealed, err := utils.SealTOTPSecret(userID, key.Secret())
+		if err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&models.TwoFactor{UserID: userID, Secret: sealed}).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "EnrollTOTP"), zap.String("Message", "Failed to create TOTP secret"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+	return c.JSON(enrollment)
+}
+
+// ConfirmTOTP godoc
+// @Summary      Confirm two-factor authentication
+// @Description  Turns on two-factor authentication once the authenticator app produces a valid code, and returns ten single-use recovery codes. They are shown only now. The current session counts as two-factor authenticated; refresh the access token to use organizations that require it.
+// @Tags         Two-factor authentication
+// @Accept       json
+// @Produce      json
+// @Param        code  body      models.TOTPCodeRequest  true  "Code from the authenticator app"
+// @Success      200   {object}  models.RecoveryCodes
+// @Failure      400   {object}  map[string]string  "Invalid code"
+// @Failure      404   {object}  map[string]string  "No enrolment to confirm"
+// @Failure      409   {object}  map[string]string  "Already enabled"
+// @Security     BearerAuth
+// @Router       /2fa/confirm [post]
+func ConfirmTOTP(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var input models.TOTPCodeRequest
+	if err := c.BodyParser(&input); err != nil || input.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code is required"})
+	}
+	sid, _ := c.Locals("sid").(string)
+
+	var codes []string
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		tf, err := findTwoFactor(tx, userID, false)
+		if err != nil {
+			return err
+		}
+		if tf.ConfirmedAt != nil {
+			return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+		}
+		ok, err := checkTOTP(tx, tf, input.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInvalidCode
+		}
+		if err := tx.Model(tf).Update("confirmed_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if codes, err = newRecoveryCodes(tx, userID); err != nil {
+			return err
+		}
+		if err := recordUserChanges(tx, "user.update", userID, map[string]models.AuditChange{"two_factor": {Before: false, After: true}}, nil); err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).Where("id = ? AND user_id = ?", sid, userID).Update("mfa", true).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "ConfirmTOTP"), zap.String("Message", "Failed to enable two-factor authentication"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+twoFactorFile, zap.String("Function", "ConfirmTOTP"), zap.String("Message", "Two-factor authentication enabled"), zap.String("user_id", userID.String()))
+	return c.JSON(models.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Replace recovery codes
+// @Description  Invalidates the caller's recovery codes and returns ten new ones. Needs a current code from the authenticator app; wrong codes count towards the account's login throttle.
+// @Tags         Two-factor authentication
+// @Accept       json
+// @Produce      json
+// @Param        code  body      models.TOTPCodeRequest  true  "Code from the authenticator app"
+// @Success      200   {object}  models.RecoveryCodes
+// @Failure      400   {object}  map[string]string  "Invalid code"
+// @Failure      404   {object}  map[string]string  "Two-factor authentication is not set up"
+// @Failure      429   {object}  map[string]string  "Too many failed logins; see Retry-After"
+// @Security     BearerAuth
+// @Router       /2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var input models.TOTPCodeRequest
+	if err := c.BodyParser(&input); err != nil || input.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code is required"})
+	}
+
+	var codes []string
+	var user models.User
+	wrongCode := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("username").First(&user, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if err := checkLoginThrottle(c, tx, user.Username); err != nil {
+			return err
+		}
+		tf, err := findTwoFactor(tx, userID, true)
+		if err != nil {
+			return err
+		}
+		ok, err := checkTOTP(tx, tf, input.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			wrongCode = true
+			return errInvalidCode
+		}
+		codes, err = newRecoveryCodes(tx, userID)
+		return err
+	})
+	if wrongCode {
+		countWrongCode(c, "RegenerateRecoveryCodes", user.Username)
+	}
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "RegenerateRecoveryCodes"), zap.String("Message", "Failed to replace recovery codes"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+	return c.JSON(models.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// DisableTOTP godoc
+// @Summary      Turn off two-factor authentication
+// @Description  Removes the caller's authenticator and recovery codes. Needs a current code or a recovery code; wrong codes count towards the account's login throttle. Refused while the caller belongs to an organization that requires two-factor authentication.
+// @Tags         Two-factor authentication
+// @Accept       json
+// @Produce      json
+// @Param        code  body      models.MFALoginRequest  true  "code or recovery_code; challenge_token is ignored"
+// @Success      204
+// @Failure      400   {object}  map[string]string  "Invalid code"
+// @Failure      404   {object}  map[string]string  "Two-factor authentication is not set up"
+// @Failure      409   {object}  map[string]string  "Required by an organization"
+// @Failure      429   {object}  map[string]string  "Too many failed logins; see Retry-After"
+// @Security     BearerAuth
+// @Router       /2fa [delete]
+func DisableTOTP(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var input models.MFALoginRequest
+	if err := c.BodyParser(&input); err != nil || (input.Code == "" && input.RecoveryCode == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code or recovery_code is required"})
+	}
+
+	var user models.User
+	wrongCode := false
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		if err := tx.Select("username").First(&user, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if err := checkLoginThrottle(c, tx, user.Username); err != nil {
+			return err
+		}
+		tf, err := findTwoFactor(tx, userID, true)
+		if err != nil {
+			return err
+		}
+		var requiring int64
+		if err := tx.Table("memberships m").Joins("JOIN organizations o ON o.id = m.org_id").
+			Where("m.user_id = ? AND o.require_2fa", userID).Count(&requiring).Error; err != nil {
+			return err
+		}
+		if requiring > 0 {
+			return fiber.NewError(fiber.StatusConflict, "An organization you belong to requires two-factor authentication")
+		}
+		ok, err := verifySecondFactor(tx, tf, input.Code, input.RecoveryCode)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			wrongCode = true
+			return errInvalidCode
+		}
+		if err := tx.Delete(tf).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := recordUserChanges(tx, "user.update", userID, map[string]models.AuditChange{"two_factor": {Before: true, After: false}}, nil); err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).Where("user_id = ?", userID).Update("mfa", false).Error
+	})
+	if wrongCode {
+		countWrongCode(c, "DisableTOTP", user.Username)
+	}
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "DisableTOTP"), zap.String("Message", "Failed to disable two-factor authentication"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+twoFactorFile, zap.String("Function", "DisableTOTP"), zap.String("Message", "Two-factor authentication disabled"), zap.String("user_id", userID.String()))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SetTwoFactorPolicy godoc
+// @Summary      Require two-factor authentication
+// @Description  Turns the active organization's two-factor requirement on or off. While it is on, members whose session was not opened with a second factor are refused by every inventory route until they set it up and sign in again. Turning it on needs the caller's own session to be two-factor authenticated, and revokes members' sessions in the organization that are not.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        policy  body      models.TwoFactorPolicyRequest  true  "Whether to require two-factor authentication"
+// @Success      200     {object}  models.Organization
+// @Failure      403     {object}  map[string]string  "Missing permission users:manage, or caller has no second factor"
+// @Failure      500     {object}  map[string]string  "Internal server error"
+// @Security     BearerAuth
+// @Router       /admin/2fa-policy [put]
+func SetTwoFactorPolicy(c *fiber.Ctx) error {
+	userID, orgID, err := currentScope(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var input models.TwoFactorPolicyRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	sid, _ := c.Locals("sid").(string)
+
+	var org models.Organization
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&org, "id = ?", orgID).Error; err != nil {
+			return err
+		}
+		if input.Require {
+			// Stop admins locking themselves out.
+			var session models.Session
+			if err := tx.First(&session, "id = ? AND user_id = ?", sid, userID).Error; err != nil || !session.MFA {
+				return fiber.NewError(fiber.StatusForbidden, "Sign in with two-factor authentication before requiring it")
+			}
+		}
+		org.Require2FA = input.Require
+		if err := tx.Model(&org).Update("require_2fa", org.Require2FA).Error; err != nil {
+			return err
+		}
+		if !input.Require {
+			return nil
+		}
+		return tx.Model(&models.Session{}).
+			Where("org_id = ? AND NOT mfa AND revoked_at IS NULL", orgID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+twoFactorFile, zap.String("Function", "SetTwoFactorPolicy"), zap.String("Message", "Failed to change two-factor policy"), zap.Error(err))
+		}
+		return sendError(c, err)
+	}
+
+	logger.Log.Info("Package controllers File "+twoFactorFile, zap.String("Function", "SetTwoFactorPolicy"), zap.String("Message", "Two-factor policy changed"), zap.String("org_id", orgID.String()), zap.Bool("require", org.Require2FA), zap.String("by", userID.String()))
+	return c.JSON(org)
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestMatchTOTP(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1_750_000_000, 0)
+	current := now.Unix() / int64(totpOpts.Period)
+	codeAt := func(step int64) string {
+		code, err := totp.GenerateCodeCustom(secret, time.Unix(step*int64(totpOpts.Period), 0), totpOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", codeAt(current), 0, current, true},
+		{"previous step for clock drift", codeAt(current - 1), 0, current - 1, true},
+		{"next step for clock drift", codeAt(current + 1), 0, current + 1, true},
+		{"two steps old", codeAt(current - 2), 0, 0, false},
+		{"two steps ahead", codeAt(current + 2), 0, 0, false},
+		{"surrounding spaces", " " + codeAt(current) + " ", 0, current, true},
+		{"replayed code", codeAt(current), current, 0, false},
+		{"code older than the last one used", codeAt(current - 1), current, 0, false},
+		{"newer code after the last one used", codeAt(current + 1), current, current + 1, true},
+		{"wrong length", "12345", 0, 0, false},
+		{"empty", "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok, err := matchTOTP(secret, tt.lastStep, tt.code, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("matchTOTP = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestWrongCodesThrottleTwoFactor(t *testing.T) {
+	app := testdb.App(t)
+	resetIPThrottles(t)
+
+	user, bearer := testdb.SignUp(t, app)
+	var enrollment models.TOTPEnrollment
+	if status := testdb.Call(t, app, fiber.MethodPost, "/2fa/enroll", bearer, nil, &enrollment); status != fiber.StatusOK {
+		t.Fatalf("enroll: status %d", status)
+	}
+	code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/2fa/confirm", bearer, models.TOTPCodeRequest{Code: code}, nil); status != fiber.StatusOK {
+		t.Fatalf("confirm: status %d", status)
+	}
+
+	// A challenge issued before the account is throttled must not be
+	// usable to keep guessing once it is.
+	var challenge models.MFAChallenge
+	login := map[string]string{"username": user.Username, "password": testdb.Password}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/login", "", login, &challenge); status != fiber.StatusAccepted {
+		t.Fatalf("login: status %d, want 202", status)
+	}
+
+	wrong := models.MFALoginRequest{Code: "000000"}
+	for i := 0; i < 2; i++ {
+		if status := testdb.Call(t, app, fiber.MethodDelete, "/2fa", bearer, wrong, nil); status != fiber.StatusBadRequest {
+			t.Fatalf("wrong code %d turning 2FA off: status %d, want 400", i+1, status)
+		}
+		if status := testdb.Call(t, app, fiber.MethodPost, "/2fa/recovery-codes", bearer, models.TOTPCodeRequest{Code: "000000"}, nil); status != fiber.StatusBadRequest {
+			t.Fatalf("wrong code %d replacing recovery codes: status %d, want 400", i+1, status)
+		}
+	}
+
+	// Four wrong codes throttle the account like four wrong passwords.
+	body, err := json.Marshal(models.MFALoginRequest{ChallengeToken: challenge.ChallengeToken, Code: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(fiber.MethodPost, "/login/2fa", bytes.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+		t.Errorf("completing the login while throttled: status %d, Retry-After %q, want 429 with Retry-After", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+	if status := testdb.Call(t, app, fiber.MethodDelete, "/2fa", bearer, wrong, nil); status != fiber.StatusTooManyRequests {
+		t.Errorf("turning 2FA off while throttled: status %d, want 429", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/2fa/recovery-codes", bearer, models.TOTPCodeRequest{Code: "000000"}, nil); status != fiber.StatusTooManyRequests {
+		t.Errorf("replacing recovery codes while throttled: status %d, want 429", status)
+	}
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const unitFile = "UnitController"
+
+// toBaseUnits converts qty given in unit to the product's base unit. An empty
+// unit or the base unit itself needs no conversion; any other unit must have a
+// conversion defined for the product.
+func toBaseUnits(db *gorm.DB, product *models.Product, unit string, qty int) (int, error) {
+	unit = strings.TrimSpace(unit)
+	if unit == "" || unit == product.BaseUnit {
+		return qty, nil
+	}
+	var conversion models.UnitConversion
+	if err := db.Where("product_id = ? AND unit = ?", product.ID, unit).First(&conversion).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, fiber.NewError(fiber.StatusBadRequest, "Unknown unit "+unit+" for product "+product.SKU)
+		}
+		return 0, err
+	}
+	if qty > math.MaxInt32/conversion.Factor || qty < math.MinInt32/conversion.Factor {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Quantity too large")
+	}
+	return qty * conversion.Factor, nil
+}
+
+// GetProductUnits godoc
+// @Summary      List a product's units
+// @Description  Lists the pack units defined for a product and how many base units each holds
+// @Tags         Units
+// @Produce      json
+// @Param        id   path      string  true  "Product ID (UUID)"
+// @Success      200  {array}   models.UnitConversion
+// @Failure      404  {object}  map[string]string  "Product not found"
+// @Security     BearerAuth
+// @Router       /products/{id}/units [get]
+func GetProductUnits(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	units := []models.UnitConversion{}
+	if err := database.DB.Where("product_id = ?", product.ID).Order("factor").Find(&units).Error; err != nil {
+		logger.Log.Error("Package controllers File "+unitFile, zap.String("Function", "GetProductUnits"), zap.String("Message", "Error retrieving units"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error retrieving units"})
+	}
+	return c.JSON(units)
+}
+
+// SetProductUnit godoc
+// @Summary      Define a pack unit
+// @Description  Creates or updates a pack unit for a product as a whole number of base units (e.g. box = 12, pallet = 480). Quantities given in this unit are multiplied by the factor.
+// @Tags         Units
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string                        true  "Product ID (UUID)"
+// @Param        unit  path      string                        true  "Unit name"
+// @Param        body  body      models.UnitConversionRequest  true  "Conversion factor"
+// @Success      200   {object}  models.UnitConversion
+// @Failure      400   {object}  map[string]string  "Invalid input"
+// @Failure      404   {object}  map[string]string  "Product not found"
+// @Security     BearerAuth
+// @Router       /products/{id}/units/{unit} [put]
+func SetProductUnit(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var input models.UnitConversionRequest
+	if err := c.BodyParser(&input); err != nil || input.Factor < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "factor must be a positive whole number"})
+	}
+	unit := strings.TrimSpace(c.Params("unit"))
+	if unit == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unit is required"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	if unit == product.BaseUnit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The base unit cannot be redefined"})
+	}
+
+	var conversion models.UnitConversion
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var previous models.UnitConversion
+		if err := tx.Where("product_id = ? AND unit = ?", product.ID, unit).Limit(1).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ? AND unit = ?", product.ID, unit).
+			Assign(models.UnitConversion{Factor: input.Factor}).
+			FirstOrCreate(&conversion, models.UnitConversion{ProductID: product.ID, Unit: unit}).Error; err != nil {
+			return err
+		}
+		change := models.AuditChange{After: conversion.Factor}
+		if previous.Factor != 0 {
+			change.Before = previous.Factor
+		}
+		if change.Before == change.After {
+			return nil
+		}
+		return audit.Record(tx, audit.Entry{
+			Action:     "product.update",
+			EntityType: "product",
+			EntityID:   product.ID.String(),
+			Changes:    map[string]models.AuditChange{"units." + unit: change},
+		})
+	})
+	if err != nil {
+		logger.Log.Error("Package controllers File "+unitFile, zap.String("Function", "SetProductUnit"), zap.String("Message", "Failed to save unit"), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save unit"})
+	}
+
+	logger.Log.Info("Package controllers File "+unitFile, zap.String("Function", "SetProductUnit"), zap.String("Message", "Unit saved"), zap.String("product_id", product.ID.String()), zap.String("unit", unit), zap.Int("factor", input.Factor))
+	return c.JSON(conversion)
+}
+
+// DeleteProductUnit godoc
+// @Summary      Remove a pack unit
+// @Description  Removes a pack unit from a product. Stock already converted is unaffected.
+// @Tags         Units
+// @Produce      json
+// @Param        id    path      string  true  "Product ID (UUID)"
+// @Param        unit  path      string  true  "Unit name"
+// @Success      200   {object}  map[string]string
+// @Failure      404   {object}  map[string]string  "Product or unit not found"
+// @Security     BearerAuth
+// @Router       /products/{id}/units/{unit} [delete]
+func DeleteProductUnit(c *fiber.Ctx) error {
+	orgID, err := currentOrgID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No active organization"})
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, "id = ? AND org_id = ?", c.Params("id"), orgID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	err = auditTransaction(c, func(tx *gorm.DB) error {
+		var removed []models.UnitConversion
+		result := tx.Clauses(clause.Returning{}).Where("product_id = ? AND unit = ?", product.ID, c.Params("unit")).Delete(&removed)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusNotFound, "Unit not found")
+		}
+		return audit.Record(tx, audit.Entry{
+			Action:     "product.update",
+			EntityType: "product",
+			EntityID:   product.ID.String(),
+			Changes:    map[string]models.AuditChange{"units." + removed[0].Unit: {Before: removed[0].Factor}},
+		})
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if !errors.As(err, &fe) {
+			logger.Log.Error("Package controllers File "+unitFile, zap.String("Function", "DeleteProductUnit"), zap.String("Message", "Failed to delete unit"), zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete unit"})
+		}
+		return sendError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Unit removed"})
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/lokesh2201013/models"
+)
+
+func TestToBaseUnitsWithoutConversion(t *testing.T) {
+	product := &models.Product{SKU: "SH-1", BaseUnit: "each"}
+	// No lookup is needed, so no database either.
+	for _, unit := range []string{"", "each", " each "} {
+		got, err := toBaseUnits(nil, product, unit, 5)
+		if err != nil || got != 5 {
+			t.Errorf("toBaseUnits(%q, 5) = %d, %v; want 5", unit, got, err)
+		}
+	}
+}
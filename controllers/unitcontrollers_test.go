@@ -0,0 +1,46 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestPackUnits(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+	product := testdb.CreateProduct(t, app, bearer, models.Product{Name: "Pens", SKU: "PN-1", Price: 1})
+	unitsPath := "/products/" + product.String() + "/units/"
+
+	if status := testdb.Call(t, app, fiber.MethodPut, unitsPath+"box", bearer, models.UnitConversionRequest{Factor: 0}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("zero factor: status %d, want 400", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPut, unitsPath+models.DefaultBaseUnit, bearer, models.UnitConversionRequest{Factor: 2}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("redefining the base unit: status %d, want 400", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPut, unitsPath+"box", bearer, models.UnitConversionRequest{Factor: 12}, nil); status != fiber.StatusOK {
+		t.Fatalf("define box: status %d", status)
+	}
+
+	quantityPath := "/products/" + product.String() + "/quantity"
+	if status := testdb.Call(t, app, fiber.MethodPut, quantityPath, bearer, models.QuantityUpdateRequest{Quantity: 1, Unit: "crate"}, nil); status != fiber.StatusBadRequest {
+		t.Errorf("unknown unit: status %d, want 400", status)
+	}
+	if status := testdb.Call(t, app, fiber.MethodPut, quantityPath, bearer, models.QuantityUpdateRequest{Quantity: 2, Unit: "box"}, nil); status != fiber.StatusOK {
+		t.Fatalf("set quantity in boxes: status %d", status)
+	}
+	if got := productQuantity(t, product); got != 24 {
+		t.Errorf("quantity = %d, want 2 boxes of 12", got)
+	}
+
+	var order models.SalesOrder
+	body := models.SalesOrderRequest{Customer: "Jane Doe", Lines: []models.SalesOrderLineRequest{{ProductID: product, Quantity: 1, Unit: "box"}}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/sales-orders", bearer, body, &order); status != fiber.StatusCreated {
+		t.Fatalf("create sales order: status %d", status)
+	}
+	if order.Lines[0].Quantity != 12 {
+		t.Errorf("order line quantity = %d, want 12 base units", order.Lines[0].Quantity)
+	}
+}
@@ -25,8 +25,12 @@ func ConnectDB() {
 		" port=" + os.Getenv("DB_PORT") +
 		" sslmode=disable"
 		log.Println("Connecting with DSN:", dsn)
+	Connect(dsn)
+}
 
-
+// Connect opens the database at dsn, migrates it and makes it DB. Tests
+// use it directly with their own database.
+func Connect(dsn string) {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("Failed to connect to db: %v\n", err)
@@ -43,10 +47,121 @@ func ConnectDB() {
 	sqlDB.SetConnMaxLifetime(5 * time.Minute)  
 
 	db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
-	if err := db.AutoMigrate(&models.User{}, &models.Product{}); err != nil {
+	// Users who registered before email verification existed keep access.
+	verifyExisting := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Product{},
+		&models.StockMovement{},
+		&models.Supplier{},
+		&models.SupplierProduct{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.SalesOrder{},
+		&models.SalesOrderLine{},
+		&models.ReturnAuthorization{},
+		&models.Lot{},
+		&models.SerialNumber{},
+		&models.SerialEvent{},
+		&models.Stocktake{},
+		&models.StocktakeLine{},
+		&models.StocktakeCount{},
+		&models.UnitConversion{},
+		&models.BundleComponent{},
+		&models.AssemblyOrder{},
+		&models.Bin{},
+		&models.BinStock{},
+		&models.PickList{},
+		&models.PickListLine{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.Organization{},
+		&models.Membership{},
+		&models.Invitation{},
+		&models.AccountToken{},
+		&models.TwoFactor{},
+		&models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.LoginThrottle{},
+		&models.APIKey{},
+		&models.ExternalIdentity{},
+		&models.SSOLoginState{},
+		&models.AuditLog{},
+	); err != nil {
 		log.Fatalf("AutoMigrate failed: %v\n", err)
 	}
+	if verifyExisting {
+		if err := db.Exec(`UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL`).Error; err != nil {
+			log.Fatalf("Marking existing users verified failed: %v\n", err)
+		}
+	}
+	migratePersonalOrgs(db)
+	protectAuditLog(db)
 
 	DB = db
 	log.Println("Connected to PostgreSQL with connection pooling enabled")
 }
+
+// orgOwnedTables hold rows that belonged to a user before organizations
+// existed and now belong to an organization.
+var orgOwnedTables = []string{
+	"products", "suppliers", "purchase_orders", "sales_orders", "return_authorizations",
+	"stocktakes", "assembly_orders", "bins", "pick_lists",
+}
+
+// migratePersonalOrgs gives every user without a personal organization one,
+// as its admin, and moves the rows they owned before organizations existed
+// into it.
+func migratePersonalOrgs(db *gorm.DB) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			WITH created AS (
+				INSERT INTO organizations (id, name, personal, created_by, created_at)
+				SELECT uuid_generate_v4(), u.username, true, u.user_id, NOW()
+				FROM users u
+				WHERE NOT EXISTS (SELECT 1 FROM organizations o WHERE o.personal AND o.created_by = u.user_id)
+				RETURNING id, created_by
+			)
+			INSERT INTO memberships (org_id, user_id, role, created_at)
+			SELECT id, created_by, 'admin', NOW() FROM created`).Error; err != nil {
+			return err
+		}
+		for _, table := range orgOwnedTables {
+			if err := tx.Exec(`
+				UPDATE ` + table + ` t SET org_id = o.id
+				FROM organizations o
+				WHERE t.org_id IS NULL AND o.personal AND o.created_by = t.user_id`).Error; err != nil {
+				return err
+			}
+		}
+		// Bin codes are unique per organization now, not per user.
+		return tx.Exec(`DROP INDEX IF EXISTS idx_user_bin_code`).Error
+	})
+	if err != nil {
+		log.Fatalf("Organization migration failed: %v\n", err)
+	}
+}
+
+// protectAuditLog makes audit_logs append-only: the database refuses to
+// update, delete or truncate entries, so getting rid of one takes dropping the
+// trigger first, and the hash chain shows the gap.
+func protectAuditLog(db *gorm.DB) {
+	err := db.Exec(`
+		CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_logs is append-only';
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS audit_logs_no_change ON audit_logs;
+		CREATE TRIGGER audit_logs_no_change BEFORE UPDATE OR DELETE ON audit_logs
+			FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+
+		DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs;
+		CREATE TRIGGER audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only();`).Error
+	if err != nil {
+		log.Fatalf("Protecting the audit log failed: %v\n", err)
+	}
+}
@@ -0,0 +1,142 @@
+// Package testdb runs tests against the PostgreSQL database named by
+// TEST_DATABASE_URL, such as
+// "host=localhost user=postgres password=postgres dbname=inventory_test sslmode=disable".
+// Tests that need it are skipped when the variable is unset. Every test
+// signs up its own users, so tests share the database without cleaning up.
+package testdb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	logger "github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+	"github.com/lokesh2201013/routes"
+	"gorm.io/gorm"
+)
+
+// Password is the password SignUp gives every user.
+const Password = "correct horse battery staple"
+
+var connectOnce sync.Once
+
+// Open connects to and migrates the test database, or skips t without one.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	connectOnce.Do(func() {
+		logger.InitLogger()
+		database.Connect(dsn)
+	})
+	return database.DB
+}
+
+// App opens the test database and returns the API as main serves it.
+func App(t testing.TB) *fiber.App {
+	Open(t)
+	app := fiber.New()
+	app.Use(audit.RequestID())
+	routes.AuthRoutes(app)
+	return app
+}
+
+// Call sends a request with body as JSON, authenticated with bearer when it
+// is set, and decodes the response into out when it is not nil. It returns
+// the status code.
+func Call(t testing.TB, app *fiber.App, method, path, bearer string, body, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if bearer != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+bearer)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < http.StatusBadRequest {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// Register registers a user with a fresh name and Password, leaving their
+// email address unverified.
+func Register(t testing.TB, app *fiber.App) *models.User {
+	t.Helper()
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	name := "t" + hex.EncodeToString(buf)
+	register := models.RegisterRequest{Username: name, Email: name + "@example.com", Password: Password}
+	if status := Call(t, app, fiber.MethodPost, "/register", "", register, nil); status != fiber.StatusCreated {
+		t.Fatalf("register: status %d", status)
+	}
+	var user models.User
+	if err := database.DB.First(&user, "username = ?", name).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// SignUp registers a user, verifies their email and signs them in, returning
+// the user and their access token.
+func SignUp(t testing.TB, app *fiber.App) (*models.User, string) {
+	t.Helper()
+	user := Register(t, app)
+	// Skip the emailed link; TestVerifyEmail follows it.
+	if err := database.DB.Model(user).Update("email_verified_at", gorm.Expr("NOW()")).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, Login(t, app, user.Username).AccessToken
+}
+
+// Login signs username in with Password and returns a new session's tokens.
+func Login(t testing.TB, app *fiber.App, username string) *models.TokenResponse {
+	t.Helper()
+	var tokens models.TokenResponse
+	login := map[string]string{"username": username, "password": Password}
+	if status := Call(t, app, fiber.MethodPost, "/login", "", login, &tokens); status != fiber.StatusOK {
+		t.Fatalf("login: status %d", status)
+	}
+	return &tokens
+}
+
+// CreateProduct adds product through POST /products and returns its ID.
+func CreateProduct(t testing.TB, app *fiber.App, bearer string, product interface{}) uuid.UUID {
+	t.Helper()
+	var created struct {
+		ProductID uuid.UUID `json:"product_id"`
+	}
+	if status := Call(t, app, fiber.MethodPost, "/products", bearer, product, &created); status != fiber.StatusCreated {
+		t.Fatalf("create product: status %d", status)
+	}
+	return created.ProductID
+}
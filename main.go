@@ -9,8 +9,13 @@ import (
 	//"github.com/gofiber/fiber/v2/middleware/limiter"
     //"github.com/joho/godotenv"
 	logger "github.com/lokesh2201013/Logger"
+	"github.com/lokesh2201013/audit"
+	"github.com/lokesh2201013/controllers"
 	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/mailer"
+	"github.com/lokesh2201013/oidc"
 	"github.com/lokesh2201013/routes"
+	"github.com/lokesh2201013/utils"
 
 	"github.com/lokesh2201013/docs"             
 	fiberSwagger "github.com/swaggo/fiber-swagger" 
@@ -38,9 +43,25 @@ func main() {
 	app := fiber.New()
 
 	logger.InitLogger()
+	app.Use(audit.RequestID())
 	app.Use(logger.ZapLogger())
 
 	database.ConnectDB()
+	if err := utils.LoadSigningKeys(); err != nil {
+		log.Fatalf("Loading JWT signing keys failed: %v\n", err)
+	}
+	if err := utils.LoadTOTPKey(); err != nil {
+		log.Fatalf("Loading TOTP encryption key failed: %v\n", err)
+	}
+	if err := utils.LoadBreachedPasswords(); err != nil {
+		log.Fatalf("Loading breached passwords failed: %v\n", err)
+	}
+	if err := mailer.Init(); err != nil {
+		log.Fatalf("Configuring mailer failed: %v\n", err)
+	}
+	if err := oidc.Init(); err != nil {
+		log.Fatalf("Configuring single sign-on failed: %v\n", err)
+	}
     docs.SwaggerInfo.Title = "Product API"
     docs.SwaggerInfo.Description = "API for managing products with JWT authentication"
     docs.SwaggerInfo.Version = "1.0"
@@ -57,6 +78,13 @@ func main() {
 	app.Use(tokenBucketMiddleware)
 	routes.AuthRoutes(app)
 
+	// PO_SUGGEST_INTERVAL (e.g. "24h") enables periodic draft purchase order suggestions.
+	if interval, err := time.ParseDuration(os.Getenv("PO_SUGGEST_INTERVAL")); err == nil && interval > 0 {
+		controllers.StartPurchaseSuggestionJob(interval)
+	}
+	controllers.StartReservationExpiryJob(time.Minute)
+	controllers.StartTokenCleanupJob(time.Hour)
+
 port := ":" + os.Getenv("PORT")
 if port == ":" {
     port = ":8080" 
@@ -78,14 +106,16 @@ var mu sync.Mutex
 const maxTokens = 100
 const refillRate = 1 
 
+// tokenBucketMiddleware limits each client IP, and each authenticated API
+// key separately, to a bucket of requests.
 func tokenBucketMiddleware(c *fiber.Ctx) error {
-	ip := c.IP()
+	key := utils.RateLimitKey(c)
 
 	mu.Lock()
-	bucket, exists := buckets[ip]
+	bucket, exists := buckets[key]
 	if !exists {
 		bucket = &Bucket{Tokens: maxTokens, LastRefillTime: time.Now()}
-		buckets[ip] = bucket
+		buckets[key] = bucket
 	}
 
 	now := time.Now()
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Account token purposes.
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// AccountToken is a single-use token mailed to a user to prove they control
+// their email address, either to verify it or to reset their password. Only
+// the token's hash is stored; issuing a new one replaces any unused token of
+// the same purpose.
+type AccountToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"5e6f7a8b-9c0d-4e1f-a2b3-c4d5e6f7a8b9"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Purpose   string     `gorm:"not null" json:"purpose" example:"password_reset"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at" example:"2025-07-25T15:00:00Z"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" example:"john@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" example:"q1mL0v1k0y2cQ9sKq8iY3b6JYw0Jk1e8cF1mV7n2xQ4"`
+	Password string `json:"password" example:"newStrongPassword456"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" example:"Zr4tX9pQ2wL7mN1vB8cK3jH6gF5dS0aY2uI9oE4rT7y"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "github.com/google/uuid"
+
+// InventorySummary is the dashboard overview returned by GET /analytics/summary.
+type InventorySummary struct {
+	TotalSKUs       int64               `json:"total_skus" example:"120"`
+	TotalUnits      int64               `json:"total_units" example:"5840"`
+	TotalStockValue float64             `json:"total_stock_value" example:"73412.55"`
+	OutOfStock      int64               `json:"out_of_stock" example:"4"`
+	LowStock        int64               `json:"low_stock" example:"11"`
+	TopMovers       []TopMover          `json:"top_movers"`
+	Categories      []CategoryBreakdown `json:"categories"`
+}
+
+// CategoryBreakdown aggregates stock per product type.
+type CategoryBreakdown struct {
+	Category   string  `json:"category" example:"Clothing"`
+	SKUs       int64   `json:"skus" example:"32"`
+	Units      int64   `json:"units" example:"1290"`
+	StockValue float64 `json:"stock_value" example:"18250.40"`
+}
+
+// TopMover is a product ranked by the number of units moved this week.
+type TopMover struct {
+	ProductID  uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Name       string    `json:"name" example:"Red T-Shirt"`
+	SKU        string    `json:"sku" example:"RTS-XL-001"`
+	UnitsMoved int64     `json:"units_moved" example:"87"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey lets a script act as its owner within one organization without a
+// password. The key itself is shown once; only its SHA-256 hash is stored,
+// and Prefix, its first characters, identifies it in lists and logs. A key
+// grants its Scopes, limited to whatever its owner's role still allows.
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	OrgID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	Name       string     `gorm:"not null" json:"name" example:"Nightly stock sync"`
+	Prefix     string     `gorm:"not null;uniqueIndex" json:"prefix" example:"inv_k3x9q2m7"`
+	KeyHash    string     `gorm:"not null" json:"-"`
+	Scopes     []string   `gorm:"serializer:json;not null" json:"scopes" example:"products:read,stock:adjust"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2026-01-01T00:00:00Z"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" example:"2025-07-25T14:30:00Z"`
+	LastUsedIP string     `json:"last_used_ip,omitempty" example:"203.0.113.7"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name" example:"Nightly stock sync"`
+	Scopes    []string   `json:"scopes" example:"products:read,stock:adjust"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2026-01-01T00:00:00Z"`
+}
+
+// CreatedAPIKey is returned once, when the key is made; the key cannot be
+// retrieved later.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key" example:"inv_k3x9q2m7_Q2hhbmdlIG1lIHRvIGEgcmVhbCBzZWNyZXQgcGxlYXNl"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditLog is one recorded change: who (ActorID, or the API key they used)
+// did what (Action) to which entity, in which organization, and from where.
+// Each organization's entries form a chain: Seq counts up from 1 and Hash
+// covers the entry and PrevHash, the previous entry's hash, so editing,
+// removing or reordering entries breaks the chain. The table only accepts
+// inserts.
+type AuditLog struct {
+	ID         uuid.UUID    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"9c0d1e2f-3a4b-4c5d-8e6f-7a8b9c0d1e2f"`
+	OrgID      uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_audit_chain,priority:1" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	Seq        int64        `gorm:"not null;uniqueIndex:idx_audit_chain,priority:2" json:"seq" example:"42"`
+	ActorID    *uuid.UUID   `gorm:"type:uuid;index" json:"actor_id,omitempty" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	APIKeyID   *uuid.UUID   `gorm:"type:uuid" json:"api_key_id,omitempty"`
+	Action     string       `gorm:"not null;index" json:"action" example:"product.update"`
+	EntityType string       `gorm:"not null;index:idx_audit_entity,priority:1" json:"entity_type" example:"product"`
+	EntityID   string       `gorm:"not null;index:idx_audit_entity,priority:2" json:"entity_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Changes    AuditChanges `gorm:"type:text;not null" json:"changes" swaggertype:"object"`
+	IP         string       `json:"ip" example:"203.0.113.7"`
+	RequestID  string       `gorm:"index" json:"request_id" example:"5f0c7a5e-8a53-4b7e-9a55-1f0b2f6f7c1d"`
+	CreatedAt  time.Time    `gorm:"not null;index" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	PrevHash   string       `gorm:"not null" json:"prev_hash" example:"3b1f...e9"`
+	Hash       string       `gorm:"not null" json:"hash" example:"a94c...07"`
+}
+
+// AuditChange is a field's value before and after a change. Before is absent
+// for creations and After for deletions.
+type AuditChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditChanges is the JSON of an entry's changed fields, by field name. It is
+// stored and hashed exactly as written, and served as a JSON object.
+type AuditChanges string
+
+func (a AuditChanges) MarshalJSON() ([]byte, error) {
+	if a == "" {
+		return []byte("{}"), nil
+	}
+	return []byte(a), nil
+}
+
+func (a AuditChanges) Value() (driver.Value, error) {
+	return string(a), nil
+}
+
+func (a *AuditChanges) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		*a = AuditChanges(v)
+	case []byte:
+		*a = AuditChanges(v)
+	case nil:
+		*a = ""
+	default:
+		return errors.New("unsupported type for AuditChanges")
+	}
+	return nil
+}
+
+// Fields decodes the changes.
+func (a AuditChanges) Fields() (map[string]AuditChange, error) {
+	fields := map[string]AuditChange{}
+	if a == "" {
+		return fields, nil
+	}
+	err := json.Unmarshal([]byte(a), &fields)
+	return fields, err
+}
+
+// AuditVerification is the result of checking an organization's chain.
+type AuditVerification struct {
+	Valid   bool   `json:"valid" example:"true"`
+	Entries int64  `json:"entries" example:"1204"`
+	BadSeq  int64  `json:"bad_seq,omitempty" example:"0"`
+	Problem string `json:"problem,omitempty" example:""`
+	Head    string `json:"head,omitempty" example:"a94c...07"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bin is a shelf location within the warehouse. Sequence orders bins along the
+// picking walk; bins with the same sequence fall back to aisle, rack and shelf.
+type Bin struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"6a7b8c9d-0e1f-4a2b-3c4d-5e6f7a8b9c0d"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Code     string    `gorm:"not null;uniqueIndex:idx_org_bin_code" json:"code" example:"A-03-2"`
+	Aisle    string    `json:"aisle" example:"A"`
+	Rack     string    `json:"rack" example:"03"`
+	Shelf    string    `json:"shelf" example:"2"`
+	Sequence int       `gorm:"not null;default:0;index" json:"sequence" example:"120"`
+	// Capacity is the most units the bin holds; zero means unlimited.
+	Capacity  int        `gorm:"not null;default:0" json:"capacity" example:"200"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	Stock     []BinStock `gorm:"foreignKey:BinID;constraint:OnDelete:CASCADE" json:"stock,omitempty"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_org_bin_code" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// BinStock is how many units of a product sit in a bin.
+type BinStock struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"7a8b9c0d-1e2f-4a3b-4c5d-6e7f8a9b0c1d"`
+	BinID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bin_product" json:"bin_id" example:"6a7b8c9d-0e1f-4a2b-3c4d-5e6f7a8b9c0d"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bin_product;index" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity  int       `gorm:"not null;default:0" json:"quantity" example:"36"`
+}
+
+type BinRequest struct {
+	Code     string `json:"code" example:"A-03-2"`
+	Aisle    string `json:"aisle" example:"A"`
+	Rack     string `json:"rack" example:"03"`
+	Shelf    string `json:"shelf" example:"2"`
+	Sequence int    `json:"sequence" example:"120"`
+	Capacity int    `json:"capacity" example:"200"`
+}
+
+// ProductBin is one bin holding a product, from GET /products/:id/bins.
+type ProductBin struct {
+	BinID    uuid.UUID `json:"bin_id" example:"6a7b8c9d-0e1f-4a2b-3c4d-5e6f7a8b9c0d"`
+	Code     string    `json:"code" example:"A-03-2"`
+	Sequence int       `json:"sequence" example:"120"`
+	Quantity int       `json:"quantity" example:"36"`
+}
+
+// PutawaySuggestion recommends where to shelve received units.
+type PutawaySuggestion struct {
+	ProductID uuid.UUID  `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity  int        `json:"quantity" example:"24"`
+	BinID     *uuid.UUID `json:"bin_id" example:"6a7b8c9d-0e1f-4a2b-3c4d-5e6f7a8b9c0d"`
+	Code      string     `json:"code,omitempty" example:"A-03-2"`
+	Reason    string     `json:"reason" example:"Bin already holds this product"`
+}
+
+type PutawayRequest struct {
+	ProductID uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	BinID     uuid.UUID `json:"bin_id" example:"6a7b8c9d-0e1f-4a2b-3c4d-5e6f7a8b9c0d"`
+	Quantity  int       `json:"quantity" example:"24"`
+}
+
+type BinStockRequest struct {
+	Quantity int `json:"quantity" example:"36"`
+}
+
+// Pick list statuses.
+const (
+	PickListStatusOpen      = "open"
+	PickListStatusCompleted = "completed"
+)
+
+// PickList batches confirmed sales orders into one walk through the
+// warehouse, with Lines in walking order.
+type PickList struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"8a9b0c1d-2e3f-4a4b-5c6d-7e8f9a0b1c2d"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Status      string         `gorm:"not null;default:'open';index" json:"status" example:"open"`
+	CompletedAt *time.Time     `json:"completed_at"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	Lines       []PickListLine `gorm:"foreignKey:PickListID;constraint:OnDelete:CASCADE" json:"lines"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// PickListLine is one stop on the walk. Lines without a bin are for stock
+// not recorded in any bin and come last.
+type PickListLine struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"9a0b1c2d-3e4f-4a5b-6c7d-8e9f0a1b2c3d"`
+	PickListID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"pick_list_id" example:"8a9b0c1d-2e3f-4a4b-5c6d-7e8f9a0b1c2d"`
+	Position         int        `gorm:"not null" json:"position" example:"1"`
+	BinID            *uuid.UUID `gorm:"type:uuid" json:"bin_id" example:"6a7b8c9d-0e1f-4a2b-3c4d-5e6f7a8b9c0d"`
+	BinCode          string     `json:"bin_code" example:"A-03-2"`
+	ProductID        uuid.UUID  `gorm:"type:uuid;not null" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	SalesOrderID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"sales_order_id" example:"d4e5f6a7-b8c9-4d0e-1f2a-3b4c5d6e7f80"`
+	SalesOrderLineID uuid.UUID  `gorm:"type:uuid;not null" json:"sales_order_line_id" example:"e5f6a7b8-c9d0-4e1f-2a3b-4c5d6e7f8091"`
+	Quantity         int        `gorm:"not null" json:"quantity" example:"2"`
+}
+
+type PickListRequest struct {
+	SalesOrderIDs []uuid.UUID `json:"sales_order_ids"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BundleComponent is one product that goes into a bundle, Quantity units per
+// kit. A bundle sells from its own assembled stock first and then straight
+// from its components.
+type BundleComponent struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"4a5b6c7d-8e9f-4a0b-1c2d-3e4f5a6b7c8d"`
+	BundleID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bundle_component" json:"bundle_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	ComponentID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bundle_component;index" json:"component_id" example:"7d1e2f3a-4b5c-4d6e-8f9a-0b1c2d3e4f5a"`
+	Quantity    int       `gorm:"not null" json:"quantity" example:"2"`
+}
+
+type BundleComponentRequest struct {
+	ProductID uuid.UUID `json:"product_id" example:"7d1e2f3a-4b5c-4d6e-8f9a-0b1c2d3e4f5a"`
+	Quantity  int       `json:"quantity" example:"2"`
+}
+
+// BundleRequest replaces a product's components. An empty list turns the
+// bundle back into an ordinary product.
+type BundleRequest struct {
+	Components []BundleComponentRequest `json:"components"`
+}
+
+// Assembly order statuses. Completing an order consumes the components and
+// adds the built kits to the bundle's stock.
+const (
+	AssemblyStatusDraft     = "draft"
+	AssemblyStatusCompleted = "completed"
+	AssemblyStatusCancelled = "cancelled"
+)
+
+var assemblyTransitions = map[string][]string{
+	AssemblyStatusDraft: {AssemblyStatusCompleted, AssemblyStatusCancelled},
+}
+
+type AssemblyOrder struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"5a6b7c8d-9e0f-4a1b-2c3d-4e5f6a7b8c9d"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	BundleID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"bundle_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity    int        `gorm:"not null" json:"quantity" example:"20"`
+	Status      string     `gorm:"not null;default:'draft';index" json:"status" example:"draft"`
+	Notes       string     `json:"notes" example:"Holiday gift boxes"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// CanTransitionTo reports whether the assembly order may move from its current status to status.
+func (a *AssemblyOrder) CanTransitionTo(status string) bool {
+	for _, next := range assemblyTransitions[a.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+type AssemblyOrderRequest struct {
+	BundleID uuid.UUID `json:"bundle_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity int       `json:"quantity" example:"20"`
+	Notes    string    `json:"notes" example:"Holiday gift boxes"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one username or one client IP.
+// Key is "account:<username>" or "ip:<address>"; usernames are tracked
+// whether or not they exist, so lockouts do not reveal which accounts do.
+// No login for the key is checked before BlockedUntil.
+type LoginThrottle struct {
+	Key          string     `gorm:"primaryKey" json:"key" example:"account:john_doe"`
+	Failures     int        `gorm:"not null;default:0" json:"failures" example:"4"`
+	LastFailedAt time.Time  `gorm:"not null;index" json:"last_failed_at" example:"2025-07-25T14:00:00Z"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty" example:"2025-07-25T14:00:02Z"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lot is a batch of a lot-tracked product. For such products Product.Quantity
+// is the sum of their lots' quantities and Product.Reserved of their Reserved
+// units, the ones pinned to confirmed sales orders. Stock received without a
+// lot number lands in the product's unnamed lot (empty LotNumber, no expiry).
+type Lot struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"0a1b2c3d-4e5f-4a6b-7c8d-9e0f1a2b3c4d"`
+	ProductID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_product_lot" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	LotNumber  string     `gorm:"not null;default:'';uniqueIndex:idx_product_lot" json:"lot_number" example:"L2025-07-A"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at" example:"2025-12-31T00:00:00Z"`
+	Quantity   int        `gorm:"not null;default:0" json:"quantity" example:"120"`
+	Reserved   int        `gorm:"not null;default:0" json:"reserved" example:"24"`
+	ReceivedAt time.Time  `gorm:"autoCreateTime" json:"received_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+}
+
+// Expired reports whether the lot is past its expiry date at t.
+func (l *Lot) Expired(t time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(t)
+}
+
+// ExpiringLot is a row of the expiring-soon report.
+type ExpiringLot struct {
+	Lot
+	Name    string `json:"name" example:"Greek Yoghurt 500g"`
+	SKU     string `json:"sku" example:"GY-500"`
+	Expired bool   `json:"expired" example:"false"`
+}
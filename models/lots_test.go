@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLotExpired(t *testing.T) {
+	now := time.Now()
+	yesterday, tomorrow := now.AddDate(0, 0, -1), now.AddDate(0, 0, 1)
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		want      bool
+	}{
+		{"no expiry", nil, false},
+		{"expired", &yesterday, true},
+		{"expires at now", &now, true},
+		{"still good", &tomorrow, false},
+	}
+	for _, tt := range tests {
+		lot := Lot{ExpiresAt: tt.expiresAt}
+		if got := lot.Expired(now); got != tt.want {
+			t.Errorf("%s: Expired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "github.com/google/uuid"
+
+// MeResponse is the caller's own account and where they are working.
+type MeResponse struct {
+	UserResponse
+	PasswordSet      bool      `json:"password_set" example:"true"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled" example:"false"`
+	SSOLinked        bool      `json:"sso_linked" example:"false"`
+	OrgID            uuid.UUID `json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	Role             string    `json:"role" example:"clerk"`
+	Permissions      []string  `json:"permissions" example:"products:read,stock:adjust"`
+}
+
+// UpdateMeRequest changes the caller's own account. Omitted fields are left
+// alone. Changing the email address or password needs the current password.
+type UpdateMeRequest struct {
+	Username        *string `json:"username,omitempty" example:"john_doe"`
+	Email           *string `json:"email,omitempty" example:"john@example.com"`
+	NewPassword     *string `json:"new_password,omitempty" example:"a-longer-Passphrase-42"`
+	CurrentPassword string  `json:"current_password,omitempty" example:"strongPassword123"`
+}
+
+// SessionInfo is one of the caller's signed-in sessions, with the device
+// described from its user agent.
+type SessionInfo struct {
+	Session
+	Device  string `json:"device" example:"Firefox on Windows"`
+	Current bool   `json:"current" example:"true"`
+}
@@ -8,12 +8,44 @@ import (
 type User struct {
 	UserID    uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
 	Username  string    `gorm:"unique;not null" json:"username" example:"john_doe"`
-	Password  string    `gorm:"not null" json:"password" example:"strongPassword123"`
-	Email     string    `gorm:"unique;not null" json:"email" validate:"required,email" example:"john@example.com"`
+	Password  string    `gorm:"not null" json:"-"`
+	Email     string    `gorm:"unique;not null" json:"email" example:"john@example.com"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+
+	// EmailVerifiedAt is when the user proved they own Email. Unverified
+	// users can sign in but not use the inventory.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" example:"2025-07-25T14:05:00Z"`
 }
 
 
+// RegisterRequest is everything a client may set when registering.
+type RegisterRequest struct {
+	Username string `json:"username" example:"john_doe"`
+	Email    string `json:"email" example:"john@example.com"`
+	Password string `json:"password" example:"correct-horse-battery"`
+}
+
+// UserResponse is the public view of a user; it never includes the password
+// hash.
+type UserResponse struct {
+	UserID          uuid.UUID  `json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Username        string     `json:"username" example:"john_doe"`
+	Email           string     `json:"email" example:"john@example.com"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" example:"2025-07-25T14:05:00Z"`
+	CreatedAt       time.Time  `json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+// Response is the public view of u.
+func (u *User) Response() UserResponse {
+	return UserResponse{
+		UserID:          u.UserID,
+		Username:        u.Username,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		CreatedAt:       u.CreatedAt,
+	}
+}
+
 type LoginRequest struct {
     Username string `json:"username" example:"john_doe"`
     Password string `json:"password" example:"strongPassword123"`
@@ -31,9 +63,72 @@ type Product struct {
 	Price       float64   `gorm:"not null" json:"price" example:"19.99"`
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+
+	// ReorderPoint is the quantity at or below which the product counts as low stock.
+	ReorderPoint int `gorm:"not null;default:0" json:"reorder_point" example:"10"`
+	// ReorderQuantity is how much to order once stock (including open orders)
+	// falls to the reorder point. Zero means just enough to clear the reorder point.
+	ReorderQuantity int `gorm:"not null;default:0" json:"reorder_quantity" example:"50"`
+	// Reserved is the part of Quantity held for confirmed sales orders.
+	Reserved int `gorm:"not null;default:0" json:"reserved" example:"6"`
+	// Quarantined counts returned units held for inspection outside Quantity.
+	Quarantined int `gorm:"not null;default:0" json:"quarantined" example:"2"`
+	// LotTracked products hold their stock in lots with expiry dates and are
+	// picked first-expiry-first-out.
+	LotTracked bool `gorm:"not null;default:false" json:"lot_tracked" example:"false"`
+	// SerialTracked products record every unit by serial number; Quantity
+	// always equals the number of in-stock serials.
+	SerialTracked bool `gorm:"not null;default:false" json:"serial_tracked" example:"false"`
+	// BaseUnit is the unit Quantity is counted in; pack units convert to it.
+	BaseUnit string `gorm:"not null;default:'each'" json:"base_unit" example:"each"`
+	// IsBundle products are kits made of BundleComponents. Quantity holds
+	// assembled kits; more can be sold while the components are available.
+	IsBundle bool `gorm:"not null;default:false" json:"is_bundle" example:"false"`
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
 }
 
+// Available is the on-hand quantity not yet reserved for sales orders.
+func (p *Product) Available() int {
+	return p.Quantity - p.Reserved
+}
+
+
+type ReorderRuleRequest struct {
+	ReorderPoint    int `json:"reorder_point" example:"10"`
+	ReorderQuantity int `json:"reorder_quantity" example:"50"`
+}
 
 type QuantityUpdateRequest struct {
 	Quantity int `json:"quantity" example:"5"`
-}
\ No newline at end of file
+	// Unit is the unit Quantity is given in; empty means the product's base unit.
+	Unit string `json:"unit,omitempty" example:"box"`
+	// LotNumber and ExpiresAt place any increase of a lot-tracked product into that lot.
+	LotNumber string     `json:"lot_number,omitempty" example:"L2025-07-A"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2025-12-31T00:00:00Z"`
+	// Serials lists the units added or removed for a serial-tracked product.
+	Serials []string `json:"serials,omitempty" example:"SN-00012345"`
+}
+
+// Movement types recorded against StockMovement.Type.
+const (
+	MovementInitial    = "initial"
+	MovementAdjustment = "adjustment"
+	MovementInbound    = "inbound"
+	MovementOutbound   = "outbound"
+	MovementReturn     = "return"
+	MovementAssembly   = "assembly"
+)
+
+// StockMovement records every change to a product's quantity. Quantity is the
+// signed delta applied to Product.Quantity.
+type StockMovement struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"8f1d7c55-2f0e-4f6a-9a43-1f8b5d3f2b10"`
+	ProductID uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Type      string     `gorm:"not null" json:"type" example:"adjustment"`
+	Quantity  int        `gorm:"not null" json:"quantity" example:"-3"`
+	UnitCost  float64    `json:"unit_cost" example:"7.50"`
+	Reference string     `json:"reference" example:"PO-2025-0001"`
+	LotID     *uuid.UUID `gorm:"type:uuid;index" json:"lot_id,omitempty" example:"0a1b2c3d-4e5f-4a6b-7c8d-9e0f1a2b3c4d"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization owns an inventory shared by its members. Every user has a
+// personal organization created at registration; teams create more and
+// invite colleagues into them. Require2FA makes members sign in with a second
+// factor before they can use the organization.
+//
+// Records that belong to an inventory carry an OrgID naming the organization
+// that owns them, alongside the UserID of the member who created them.
+type Organization struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	Name       string    `gorm:"not null" json:"name" example:"Acme Retail"`
+	Personal   bool      `gorm:"not null;default:false" json:"personal" example:"false"`
+	Require2FA bool      `gorm:"column:require_2fa;not null;default:false" json:"require_2fa" example:"false"`
+	CreatedBy  uuid.UUID `gorm:"type:uuid;not null" json:"created_by" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+// Membership gives a user a role within an organization.
+type Membership struct {
+	OrgID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Role      string    `gorm:"not null" json:"role" example:"clerk"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+// Invitation lets whoever holds its token, signed in with the invited email,
+// join the organization. Only the token's hash is stored.
+type Invitation struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"1b2c3d4e-5f6a-4b7c-8d9e-0f1a2b3c4d5e"`
+	OrgID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	Email      string     `gorm:"not null" json:"email" example:"jane@example.com"`
+	Role       string     `gorm:"not null" json:"role" example:"clerk"`
+	TokenHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	InvitedBy  uuid.UUID  `gorm:"type:uuid;not null" json:"invited_by" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at" example:"2025-08-01T14:00:00Z"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+// CreatedInvitation is returned once, when the invitation is made; the token
+// cannot be retrieved later.
+type CreatedInvitation struct {
+	Invitation
+	Token string `json:"token" example:"Zm9vYmFyYmF6cXV4X2ludml0ZV90b2tlbg"`
+}
+
+// OrganizationMembership is one of the caller's organizations.
+type OrganizationMembership struct {
+	OrgID      uuid.UUID `json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	Name       string    `json:"name" example:"Acme Retail"`
+	Personal   bool      `json:"personal" example:"false"`
+	Require2FA bool      `gorm:"column:require_2fa" json:"require_2fa" example:"false"`
+	Role       string    `json:"role" example:"admin"`
+	Active     bool      `json:"active" example:"true"`
+}
+
+type OrganizationRequest struct {
+	Name string `json:"name" example:"Acme Retail"`
+}
+
+type InvitationRequest struct {
+	Email string `json:"email" example:"jane@example.com"`
+	Role  string `json:"role" example:"clerk"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" example:"Zm9vYmFyYmF6cXV4X2ludml0ZV90b2tlbg"`
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purchase order statuses. A PO moves draft → submitted → partially_received →
+// received → closed, and may be cancelled before anything is received.
+const (
+	POStatusDraft             = "draft"
+	POStatusSubmitted         = "submitted"
+	POStatusPartiallyReceived = "partially_received"
+	POStatusReceived          = "received"
+	POStatusClosed            = "closed"
+	POStatusCancelled         = "cancelled"
+)
+
+var poTransitions = map[string][]string{
+	POStatusDraft:             {POStatusSubmitted, POStatusCancelled},
+	POStatusSubmitted:         {POStatusPartiallyReceived, POStatusReceived, POStatusCancelled},
+	POStatusPartiallyReceived: {POStatusPartiallyReceived, POStatusReceived, POStatusClosed},
+	POStatusReceived:          {POStatusReceived, POStatusClosed},
+}
+
+type PurchaseOrder struct {
+	ID           uuid.UUID           `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d"`
+	UserID       uuid.UUID           `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	SupplierID   uuid.UUID           `gorm:"type:uuid;not null;index" json:"supplier_id" example:"5b0f2a1c-7d3e-4e8b-a9c6-2f1e3d4c5b6a"`
+	Status       string              `gorm:"not null;default:'draft';index" json:"status" example:"draft"`
+	ExpectedDate *time.Time          `json:"expected_date" example:"2025-08-01T00:00:00Z"`
+	Notes        string              `json:"notes" example:"Deliver to dock 2"`
+	SubmittedAt  *time.Time          `json:"submitted_at"`
+	ClosedAt     *time.Time          `json:"closed_at"`
+	CreatedAt    time.Time           `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt    time.Time           `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+	Lines        []PurchaseOrderLine `gorm:"foreignKey:PurchaseOrderID;constraint:OnDelete:CASCADE" json:"lines"`
+
+	// Putaway is filled in on receipt with a suggested bin per received line.
+	Putaway []PutawaySuggestion `gorm:"-" json:"putaway,omitempty"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// CanTransitionTo reports whether the PO may move from its current status to status.
+func (po *PurchaseOrder) CanTransitionTo(status string) bool {
+	for _, next := range poTransitions[po.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// PurchaseOrderLine is one product on a PO. ReceivedQuantity may end up above
+// or below Quantity when the supplier over- or under-delivers.
+type PurchaseOrderLine struct {
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"c3d4e5f6-a7b8-4c9d-0e1f-2a3b4c5d6e7f"`
+	PurchaseOrderID  uuid.UUID `gorm:"type:uuid;not null;index" json:"purchase_order_id" example:"a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d"`
+	ProductID        uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity         int       `gorm:"not null" json:"quantity" example:"48"`
+	UnitCost         float64   `gorm:"not null;default:0" json:"unit_cost" example:"7.50"`
+	ReceivedQuantity int       `gorm:"not null;default:0" json:"received_quantity" example:"24"`
+}
+
+type PurchaseOrderLineRequest struct {
+	ProductID uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity  int       `json:"quantity" example:"48"`
+	UnitCost  float64   `json:"unit_cost" example:"7.50"`
+	// Unit is the unit Quantity is ordered in; the line stores base units.
+	// UnitCost is always per base unit.
+	Unit string `json:"unit,omitempty" example:"box"`
+}
+
+type PurchaseOrderRequest struct {
+	SupplierID   uuid.UUID                  `json:"supplier_id" example:"5b0f2a1c-7d3e-4e8b-a9c6-2f1e3d4c5b6a"`
+	ExpectedDate *time.Time                 `json:"expected_date" example:"2025-08-01T00:00:00Z"`
+	Notes        string                     `json:"notes" example:"Deliver to dock 2"`
+	Lines        []PurchaseOrderLineRequest `json:"lines"`
+}
+
+type ReceiveLineRequest struct {
+	LineID   uuid.UUID `json:"line_id" example:"c3d4e5f6-a7b8-4c9d-0e1f-2a3b4c5d6e7f"`
+	Quantity int       `json:"quantity" example:"24"`
+	// Unit is the unit Quantity is received in; empty means the base unit.
+	Unit string `json:"unit,omitempty" example:"box"`
+	// UnitCost overrides the line's cost for this delivery when non-zero.
+	UnitCost float64 `json:"unit_cost" example:"7.25"`
+	// LotNumber is required when the product is lot-tracked.
+	LotNumber string     `json:"lot_number" example:"L2025-07-A"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2025-12-31T00:00:00Z"`
+	// Serials registers each received unit of a serial-tracked product.
+	Serials []string `json:"serials" example:"SN-00012345"`
+}
+
+type ReceivePurchaseOrderRequest struct {
+	Lines []ReceiveLineRequest `json:"lines"`
+}
+
+// PurchaseSuggestion is the result of POST /purchasing/suggest. Unsourced lists
+// products that need reordering but have no linked supplier.
+type PurchaseSuggestion struct {
+	DryRun    bool            `json:"dry_run" example:"true"`
+	Orders    []PurchaseOrder `json:"orders"`
+	Unsourced []Product       `json:"unsourced"`
+}
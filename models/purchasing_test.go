@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestPurchaseOrderTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{POStatusDraft, POStatusSubmitted, true},
+		{POStatusDraft, POStatusReceived, false},
+		{POStatusDraft, POStatusCancelled, true},
+		{POStatusSubmitted, POStatusPartiallyReceived, true},
+		{POStatusSubmitted, POStatusCancelled, true},
+		{POStatusSubmitted, POStatusClosed, false},
+		{POStatusPartiallyReceived, POStatusPartiallyReceived, true},
+		{POStatusPartiallyReceived, POStatusCancelled, false},
+		{POStatusPartiallyReceived, POStatusClosed, true},
+		{POStatusReceived, POStatusReceived, true},
+		{POStatusReceived, POStatusCancelled, false},
+		{POStatusClosed, POStatusReceived, false},
+		{POStatusCancelled, POStatusSubmitted, false},
+	}
+	for _, tt := range tests {
+		po := PurchaseOrder{Status: tt.from}
+		if got := po.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
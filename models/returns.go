@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Return (RMA) statuses. An RMA is authorised against a shipped order line,
+// the goods are received and inspected, and a disposition completes it.
+// Quarantined goods wait in quarantined until they are released, by
+// restocking or writing them off, which completes the RMA.
+const (
+	RMAStatusAuthorized  = "authorized"
+	RMAStatusReceived    = "received"
+	RMAStatusInspected   = "inspected"
+	RMAStatusQuarantined = "quarantined"
+	RMAStatusCompleted   = "completed"
+	RMAStatusCancelled   = "cancelled"
+)
+
+// Return dispositions. Restocked units go back into Product.Quantity,
+// quarantined units are held in Product.Quarantined at a quarantine location
+// until released, and written-off units leave inventory for good.
+const (
+	DispositionRestock    = "restock"
+	DispositionQuarantine = "quarantine"
+	DispositionWriteOff   = "write_off"
+)
+
+var rmaTransitions = map[string][]string{
+	RMAStatusAuthorized:  {RMAStatusReceived, RMAStatusCancelled},
+	RMAStatusReceived:    {RMAStatusInspected},
+	RMAStatusInspected:   {RMAStatusQuarantined, RMAStatusCompleted},
+	RMAStatusQuarantined: {RMAStatusCompleted},
+}
+
+type ReturnAuthorization struct {
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"f6a7b8c9-d0e1-4f2a-3b4c-5d6e7f809102"`
+	UserID           uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	SalesOrderID     uuid.UUID `gorm:"type:uuid;not null;index" json:"sales_order_id" example:"d4e5f6a7-b8c9-4d0e-1f2a-3b4c5d6e7f80"`
+	SalesOrderLineID uuid.UUID `gorm:"type:uuid;not null;index" json:"sales_order_line_id" example:"e5f6a7b8-c9d0-4e1f-2a3b-4c5d6e7f8091"`
+	ProductID        uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity         int       `gorm:"not null" json:"quantity" example:"1"`
+	ReceivedQuantity int       `gorm:"not null;default:0" json:"received_quantity" example:"1"`
+	Reason           string    `json:"reason" example:"Wrong size"`
+	Status           string    `gorm:"not null;default:'authorized';index" json:"status" example:"authorized"`
+	Condition        string    `json:"condition" example:"unopened"`
+	InspectionNotes  string    `json:"inspection_notes" example:"Tags attached, no damage"`
+	Disposition      string    `gorm:"index" json:"disposition" example:"restock"`
+	// ReleaseDisposition is what became of quarantined goods on release:
+	// restock or write_off. Disposition stays quarantine.
+	ReleaseDisposition string     `gorm:"index" json:"release_disposition" example:"restock"`
+	Location           string     `json:"location" example:"QUARANTINE-A"`
+	ReceivedAt         *time.Time `json:"received_at"`
+	InspectedAt        *time.Time `json:"inspected_at"`
+	ReleasedAt         *time.Time `json:"released_at"`
+	CompletedAt        *time.Time `json:"completed_at"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// CanTransitionTo reports whether the RMA may move from its current status to status.
+func (r *ReturnAuthorization) CanTransitionTo(status string) bool {
+	for _, next := range rmaTransitions[r.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+type ReturnRequest struct {
+	SalesOrderLineID uuid.UUID `json:"sales_order_line_id" example:"e5f6a7b8-c9d0-4e1f-2a3b-4c5d6e7f8091"`
+	Quantity         int       `json:"quantity" example:"1"`
+	Reason           string    `json:"reason" example:"Wrong size"`
+}
+
+type ReturnReceiveRequest struct {
+	// Quantity defaults to the authorised quantity when zero.
+	Quantity int `json:"quantity" example:"1"`
+	// Serials identifies the returned units of a serial-tracked product.
+	Serials []string `json:"serials" example:"SN-00012345"`
+}
+
+type ReturnInspectRequest struct {
+	Condition string `json:"condition" example:"unopened"`
+	Notes     string `json:"notes" example:"Tags attached, no damage"`
+}
+
+type ReturnDispositionRequest struct {
+	Disposition string `json:"disposition" example:"restock"`
+	// Location is required for the quarantine disposition.
+	Location string `json:"location" example:"QUARANTINE-A"`
+}
+
+// ReturnReleaseRequest says what becomes of quarantined goods: restock or
+// write_off.
+type ReturnReleaseRequest struct {
+	Disposition string `json:"disposition" example:"restock"`
+}
+
+// ReturnRateReport is the per-product return summary from GET /returns/report.
+// Released returns count under what they were released as, so Quarantined is
+// what is still held.
+type ReturnRateReport struct {
+	ProductID   uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Name        string    `json:"name" example:"Red T-Shirt"`
+	SKU         string    `json:"sku" example:"RTS-XL-001"`
+	Shipped     int64     `json:"shipped" example:"200"`
+	Returned    int64     `json:"returned" example:"9"`
+	ReturnRate  float64   `json:"return_rate" example:"0.045"`
+	Restocked   int64     `json:"restocked" example:"6"`
+	Quarantined int64     `json:"quarantined" example:"2"`
+	WrittenOff  int64     `json:"written_off" example:"1"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import "github.com/google/uuid"
+
+// Roles. Each role grants a fixed set of permissions, which are carried in the
+// access token so routes can check them without a database lookup.
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleClerk   = "clerk"
+	RoleViewer  = "viewer"
+)
+
+// Permissions checked by routes.
+const (
+	PermProductsRead    = "products:read"
+	PermProductsWrite   = "products:write"
+	PermStockAdjust     = "stock:adjust"
+	PermStockApprove    = "stock:approve"
+	PermOrdersRead      = "orders:read"
+	PermSalesWrite      = "sales:write"
+	PermPurchasingWrite = "purchasing:write"
+	PermAnalyticsRead   = "analytics:read"
+	PermUsersManage     = "users:manage"
+	PermAuditRead       = "audit:read"
+)
+
+// rolePermissions lists what each role may do. Clerks move stock and process
+// sales but cannot edit products or prices.
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermProductsRead, PermProductsWrite, PermStockAdjust, PermStockApprove, PermOrdersRead,
+		PermSalesWrite, PermPurchasingWrite, PermAnalyticsRead, PermUsersManage, PermAuditRead,
+	},
+	RoleManager: {
+		PermProductsRead, PermProductsWrite, PermStockAdjust, PermStockApprove, PermOrdersRead,
+		PermSalesWrite, PermPurchasingWrite, PermAnalyticsRead,
+	},
+	RoleClerk:  {PermProductsRead, PermStockAdjust, PermOrdersRead, PermSalesWrite},
+	RoleViewer: {PermProductsRead, PermOrdersRead, PermAnalyticsRead},
+}
+
+// ValidRole reports whether role is one of the defined roles.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns the permissions granted to role, or none for an
+// unknown role.
+func RolePermissions(role string) []string {
+	return append([]string(nil), rolePermissions[role]...)
+}
+
+// RoleInfo describes a role and what it grants.
+type RoleInfo struct {
+	Role        string   `json:"role" example:"clerk"`
+	Permissions []string `json:"permissions" example:"products:read,stock:adjust"`
+}
+
+// Roles lists every role, most privileged first.
+func Roles() []RoleInfo {
+	var roles []RoleInfo
+	for _, role := range []string{RoleAdmin, RoleManager, RoleClerk, RoleViewer} {
+		roles = append(roles, RoleInfo{Role: role, Permissions: RolePermissions(role)})
+	}
+	return roles
+}
+
+type RoleRequest struct {
+	Role string `json:"role" example:"clerk"`
+}
+
+// UserRole is a user as listed to admins.
+type UserRole struct {
+	UserID   uuid.UUID `json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Username string    `json:"username" example:"john_doe"`
+	Email    string    `json:"email" example:"john@example.com"`
+	Role     string    `json:"role" example:"clerk"`
+}
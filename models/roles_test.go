@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestRolePermissions(t *testing.T) {
+	has := func(role, permission string) bool {
+		for _, p := range RolePermissions(role) {
+			if p == permission {
+				return true
+			}
+		}
+		return false
+	}
+	tests := []struct {
+		role, permission string
+		want             bool
+	}{
+		{RoleAdmin, PermUsersManage, true},
+		{RoleManager, PermUsersManage, false},
+		{RoleManager, PermProductsWrite, true},
+		{RoleClerk, PermStockAdjust, true},
+		{RoleClerk, PermProductsWrite, false},
+		{RoleViewer, PermOrdersRead, true},
+		{RoleViewer, PermSalesWrite, false},
+		{"owner", PermProductsRead, false},
+	}
+	for _, tt := range tests {
+		if got := has(tt.role, tt.permission); got != tt.want {
+			t.Errorf("%s has %s = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+
+	perms := RolePermissions(RoleClerk)
+	perms[0] = PermUsersManage
+	if has(RoleClerk, PermUsersManage) {
+		t.Error("changing the returned permissions changed the role")
+	}
+	if ValidRole("owner") || !ValidRole(RoleViewer) {
+		t.Error("ValidRole accepts only the defined roles")
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sales order statuses. Confirming reserves stock, backordering any shortfall;
+// shipping consumes the reservation and decrements Product.Quantity. A fully
+// reserved order that is not picked before ReservationExpiresAt expires and
+// releases its reservation. Backorders are filled by Priority (highest first),
+// then by ConfirmedAt.
+const (
+	SOStatusDraft     = "draft"
+	SOStatusConfirmed = "confirmed"
+	SOStatusPicked    = "picked"
+	SOStatusPacked    = "packed"
+	SOStatusShipped   = "shipped"
+	SOStatusCancelled = "cancelled"
+	SOStatusExpired   = "expired"
+)
+
+var soTransitions = map[string][]string{
+	SOStatusDraft:     {SOStatusConfirmed, SOStatusCancelled},
+	SOStatusConfirmed: {SOStatusPicked, SOStatusCancelled, SOStatusExpired},
+	SOStatusPicked:    {SOStatusPacked, SOStatusCancelled},
+	SOStatusPacked:    {SOStatusShipped, SOStatusCancelled},
+	SOStatusExpired:   {SOStatusConfirmed, SOStatusCancelled},
+}
+
+type SalesOrder struct {
+	ID                   uuid.UUID        `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"d4e5f6a7-b8c9-4d0e-1f2a-3b4c5d6e7f80"`
+	UserID               uuid.UUID        `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Customer             string           `gorm:"not null" json:"customer" example:"Jane Doe"`
+	Priority             int              `gorm:"not null;default:0" json:"priority" example:"0"`
+	Status               string           `gorm:"not null;default:'draft';index" json:"status" example:"confirmed"`
+	ReservationExpiresAt *time.Time       `gorm:"index" json:"reservation_expires_at" example:"2025-07-28T14:00:00Z"`
+	ConfirmedAt          *time.Time       `json:"confirmed_at"`
+	ShippedAt            *time.Time       `json:"shipped_at"`
+	CreatedAt            time.Time        `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt            time.Time        `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+	Lines                []SalesOrderLine `gorm:"foreignKey:SalesOrderID;constraint:OnDelete:CASCADE" json:"lines"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// CanTransitionTo reports whether the order may move from its current status to status.
+func (so *SalesOrder) CanTransitionTo(status string) bool {
+	for _, next := range soTransitions[so.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+type SalesOrderLine struct {
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"e5f6a7b8-c9d0-4e1f-2a3b-4c5d6e7f8091"`
+	SalesOrderID     uuid.UUID `gorm:"type:uuid;not null;index" json:"sales_order_id" example:"d4e5f6a7-b8c9-4d0e-1f2a-3b4c5d6e7f80"`
+	ProductID        uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity         int       `gorm:"not null" json:"quantity" example:"2"`
+	UnitPrice        float64   `gorm:"not null;default:0" json:"unit_price" example:"19.99"`
+	ReservedQuantity int       `gorm:"not null;default:0" json:"reserved_quantity" example:"2"`
+	ShippedQuantity  int       `gorm:"not null;default:0" json:"shipped_quantity" example:"0"`
+
+	// BackorderedQuantity is the part of Quantity still waiting for stock.
+	BackorderedQuantity int `gorm:"not null;default:0;index" json:"backordered_quantity" example:"0"`
+	// KitReservedQuantity is the part of ReservedQuantity of a bundle held as
+	// reserved components rather than assembled kits.
+	KitReservedQuantity int `gorm:"not null;default:0" json:"kit_reserved_quantity" example:"0"`
+}
+
+type SalesOrderLineRequest struct {
+	ProductID uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity  int       `json:"quantity" example:"2"`
+	// UnitPrice defaults to the product's price when zero.
+	UnitPrice float64 `json:"unit_price" example:"19.99"`
+	// Unit is the unit Quantity is ordered in; the line stores base units.
+	// UnitPrice is always per base unit.
+	Unit string `json:"unit,omitempty" example:"box"`
+}
+
+type SalesOrderRequest struct {
+	Customer string                  `json:"customer" example:"Jane Doe"`
+	Priority int                     `json:"priority" example:"0"`
+	Lines    []SalesOrderLineRequest `json:"lines"`
+}
+
+// BackorderSummary is the outstanding backorder quantity for one product.
+type BackorderSummary struct {
+	ProductID     uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Name          string    `json:"name" example:"Red T-Shirt"`
+	SKU           string    `json:"sku" example:"RTS-XL-001"`
+	Outstanding   int64     `json:"outstanding" example:"14"`
+	Orders        int64     `json:"orders" example:"3"`
+	OldestWaiting time.Time `json:"oldest_waiting" example:"2025-07-20T09:00:00Z"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestSalesOrderTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{SOStatusDraft, SOStatusConfirmed, true},
+		{SOStatusDraft, SOStatusShipped, false},
+		{SOStatusConfirmed, SOStatusPicked, true},
+		{SOStatusConfirmed, SOStatusExpired, true},
+		{SOStatusConfirmed, SOStatusShipped, false},
+		{SOStatusPicked, SOStatusExpired, false},
+		{SOStatusPacked, SOStatusShipped, true},
+		{SOStatusExpired, SOStatusConfirmed, true},
+		{SOStatusShipped, SOStatusCancelled, false},
+		{SOStatusCancelled, SOStatusConfirmed, false},
+	}
+	for _, tt := range tests {
+		so := SalesOrder{Status: tt.from}
+		if got := so.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Serial statuses. Only in_stock serials count towards Product.Quantity.
+const (
+	SerialInStock     = "in_stock"
+	SerialSold        = "sold"
+	SerialReturned    = "returned"
+	SerialQuarantined = "quarantined"
+	SerialWrittenOff  = "written_off"
+	SerialRemoved     = "removed"
+)
+
+// Serial history events.
+const (
+	SerialEventReceived    = "received"
+	SerialEventTransferred = "transferred"
+	SerialEventSold        = "sold"
+	SerialEventReturned    = "returned"
+	SerialEventRestocked   = "restocked"
+	SerialEventQuarantined = "quarantined"
+	SerialEventWrittenOff  = "written_off"
+	SerialEventAdjusted    = "adjusted"
+)
+
+// SerialNumber is one physical unit of a serial-tracked product. For such
+// products Product.Quantity always equals the number of in_stock serials.
+type SerialNumber struct {
+	ID                    uuid.UUID     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"1b2c3d4e-5f6a-4b7c-8d9e-0f1a2b3c4d5e"`
+	ProductID             uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_product_serial" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Serial                string        `gorm:"not null;uniqueIndex:idx_product_serial;index" json:"serial" example:"SN-00012345"`
+	Status                string        `gorm:"not null;index" json:"status" example:"in_stock"`
+	Location              string        `json:"location" example:"Main warehouse"`
+	Customer              string        `json:"customer,omitempty" example:"Jane Doe"`
+	SalesOrderLineID      *uuid.UUID    `gorm:"type:uuid;index" json:"sales_order_line_id,omitempty"`
+	ReturnAuthorizationID *uuid.UUID    `gorm:"type:uuid;index" json:"return_authorization_id,omitempty"`
+	CreatedAt             time.Time     `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt             time.Time     `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+	Events                []SerialEvent `gorm:"foreignKey:SerialNumberID;constraint:OnDelete:CASCADE" json:"events,omitempty"`
+}
+
+// SerialEvent is one entry in a serial number's history.
+type SerialEvent struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"2c3d4e5f-6a7b-4c8d-9e0f-1a2b3c4d5e6f"`
+	SerialNumberID uuid.UUID `gorm:"type:uuid;not null;index" json:"serial_number_id" example:"1b2c3d4e-5f6a-4b7c-8d9e-0f1a2b3c4d5e"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Event          string    `gorm:"not null" json:"event" example:"sold"`
+	Location       string    `json:"location,omitempty" example:"Main warehouse"`
+	Customer       string    `json:"customer,omitempty" example:"Jane Doe"`
+	Reference      string    `json:"reference,omitempty" example:"so:d4e5f6a7-b8c9-4d0e-1f2a-3b4c5d6e7f80"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;index" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+type SerialTransferRequest struct {
+	ProductID uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Serials   []string  `json:"serials" example:"SN-00012345"`
+	Location  string    `json:"location" example:"Store 4"`
+}
+
+type ShipLineRequest struct {
+	LineID  uuid.UUID `json:"line_id" example:"e5f6a7b8-c9d0-4e1f-2a3b-4c5d6e7f8091"`
+	Serials []string  `json:"serials" example:"SN-00012345"`
+}
+
+// ShipSalesOrderRequest names the serials shipped on serial-tracked lines.
+type ShipSalesOrderRequest struct {
+	Lines []ShipLineRequest `json:"lines"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one login and the family of refresh tokens rotated from it.
+// Access tokens carry the session ID, so revoking a session also rejects every
+// access token issued under it. OrgID is the organization the session works
+// in; refreshed tokens keep it until the user switches. MFA records whether
+// the user proved a second factor in this session.
+type Session struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"b1c2d3e4-f5a6-4b7c-8d9e-0f1a2b3c4d5e"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	OrgID      uuid.UUID  `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+	UserAgent  string     `json:"user_agent" example:"Mozilla/5.0"`
+	IP         string     `json:"ip" example:"203.0.113.7"`
+	MFA        bool       `gorm:"not null;default:false" json:"mfa" example:"true"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	LastUsedAt time.Time  `json:"last_used_at" example:"2025-07-25T14:30:00Z"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at" example:"2025-08-24T14:00:00Z"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+}
+
+// RefreshToken is one token in a session's rotation chain. Only its SHA-256
+// hash is stored. A token is used once; presenting it again revokes the
+// session.
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	SessionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RevokedToken is the ID of an access token revoked before its expiry. Rows
+// can be dropped once the token would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"m3V0cF9yZWZyZXNoX3Rva2VuX2V4YW1wbGU"`
+}
+
+// TokenResponse is returned by login and token refresh.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string `json:"refresh_token,omitempty" example:"m3V0cF9yZWZyZXNoX3Rva2VuX2V4YW1wbGU"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int    `json:"expires_in" example:"900"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity links a user to their account at the single sign-on
+// identity provider, which names it by Issuer and Subject. Email is what the
+// provider last said the address was.
+type ExternalIdentity struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"8b9c0d1e-2f3a-4b4c-9d5e-6f7a8b9c0d1e"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Issuer      string    `gorm:"not null;uniqueIndex:idx_external_identity_subject" json:"issuer" example:"https://login.example.com"`
+	Subject     string    `gorm:"not null;uniqueIndex:idx_external_identity_subject" json:"subject" example:"248289761001"`
+	Email       string    `json:"email" example:"john@example.com"`
+	LastLoginAt time.Time `json:"last_login_at" example:"2025-07-25T14:30:00Z"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+// SSOLoginState is a single sign-on login between leaving for the identity
+// provider and coming back. It is found by the hash of the state parameter
+// and holds the PKCE verifier and the nonce the ID token must carry.
+type SSOLoginState struct {
+	StateHash string    `gorm:"primaryKey"`
+	Verifier  string    `gorm:"not null"`
+	Nonce     string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
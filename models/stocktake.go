@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stocktake statuses. A stocktake is open while counts are collected and is
+// then approved, posting the variances, or cancelled.
+const (
+	StocktakeStatusOpen      = "open"
+	StocktakeStatusApproved  = "approved"
+	StocktakeStatusCancelled = "cancelled"
+)
+
+var stocktakeTransitions = map[string][]string{
+	StocktakeStatusOpen: {StocktakeStatusApproved, StocktakeStatusCancelled},
+}
+
+// Stocktake is a counting session over a frozen set of products. Each line
+// snapshots the system quantity when the session opens and records it again
+// whenever the line is counted; on approval the difference between the count
+// and the quantity expected at count time is posted as an adjustment, so stock
+// that moved while counting was in progress is neither lost nor counted twice.
+type Stocktake struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"0a1b2c3d-4e5f-4a6b-7c8d-9e0f1a2b3c4d"`
+	UserID      uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Name        string          `gorm:"not null" json:"name" example:"Q3 cycle count - aisle 4"`
+	Location    string          `json:"location" example:"Main warehouse"`
+	Status      string          `gorm:"not null;default:'open';index" json:"status" example:"open"`
+	ApprovedAt  *time.Time      `json:"approved_at"`
+	CancelledAt *time.Time      `json:"cancelled_at"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+	Lines       []StocktakeLine `gorm:"foreignKey:StocktakeID;constraint:OnDelete:CASCADE" json:"lines"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// CanTransitionTo reports whether the stocktake may move from its current status to status.
+func (s *Stocktake) CanTransitionTo(status string) bool {
+	for _, next := range stocktakeTransitions[s.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// StocktakeLine is one product in a stocktake. CountedQuantity is the sum of
+// the latest count from each device and stays nil until something is counted.
+// ExpectedQuantity is the system quantity when the line was last counted, and
+// Variance is the count less that.
+type StocktakeLine struct {
+	ID               uuid.UUID        `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"`
+	StocktakeID      uuid.UUID        `gorm:"type:uuid;not null;index" json:"stocktake_id" example:"0a1b2c3d-4e5f-4a6b-7c8d-9e0f1a2b3c4d"`
+	ProductID        uuid.UUID        `gorm:"type:uuid;not null;index" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	SnapshotQuantity int              `gorm:"not null" json:"snapshot_quantity" example:"42"`
+	ExpectedQuantity *int             `json:"expected_quantity" example:"41"`
+	CountedQuantity  *int             `json:"counted_quantity" example:"40"`
+	Variance         int              `gorm:"not null;default:0" json:"variance" example:"-1"`
+	Counts           []StocktakeCount `gorm:"foreignKey:StocktakeLineID;constraint:OnDelete:CASCADE" json:"counts,omitempty"`
+}
+
+// StocktakeCount is one submission for a line from a counting device. A later
+// count from the same device replaces its earlier one.
+type StocktakeCount struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d"`
+	StocktakeLineID uuid.UUID `gorm:"type:uuid;not null;index" json:"stocktake_line_id" example:"1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Device          string    `gorm:"not null" json:"device" example:"scanner-02"`
+	Quantity        int       `gorm:"not null" json:"quantity" example:"40"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:10:00Z"`
+}
+
+// StocktakeRequest opens a stocktake over the listed products, or over every
+// product of Type when no products are listed, or over all products otherwise.
+type StocktakeRequest struct {
+	Name       string      `json:"name" example:"Q3 cycle count - aisle 4"`
+	Location   string      `json:"location" example:"Main warehouse"`
+	ProductIDs []uuid.UUID `json:"product_ids"`
+	Type       string      `json:"type" example:"Clothing"`
+}
+
+type StocktakeCountLine struct {
+	ProductID uuid.UUID `json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Quantity  int       `json:"quantity" example:"40"`
+	// Unit is the unit Quantity was counted in; empty means the base unit.
+	Unit string `json:"unit,omitempty" example:"box"`
+}
+
+type StocktakeCountRequest struct {
+	// Device identifies the counter's device; counts from different devices add up.
+	Device string               `json:"device" example:"scanner-02"`
+	Counts []StocktakeCountLine `json:"counts"`
+}
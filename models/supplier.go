@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Supplier struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"5b0f2a1c-7d3e-4e8b-a9c6-2f1e3d4c5b6a"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Name         string    `gorm:"not null" json:"name" example:"Acme Textiles"`
+	Contact      string    `json:"contact" example:"Jane Roe, jane@acme.example, +1 555 0100"`
+	LeadTimeDays int       `gorm:"not null;default:0" json:"lead_time_days" example:"14"`
+	Currency     string    `gorm:"size:3;not null;default:'USD'" json:"currency" example:"USD"`
+	PaymentTerms string    `json:"payment_terms" example:"Net 30"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+
+	OrgID uuid.UUID `gorm:"type:uuid;index" json:"org_id" example:"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"`
+}
+
+// SupplierProduct links a product to a supplier that sells it, carrying the
+// supplier's own catalogue terms. At most one link per product is Preferred.
+type SupplierProduct struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"0e6c9d1a-3b2f-4c5d-8e7f-9a0b1c2d3e4f"`
+	SupplierID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_supplier_product" json:"supplier_id" example:"5b0f2a1c-7d3e-4e8b-a9c6-2f1e3d4c5b6a"`
+	ProductID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_supplier_product;index" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	SupplierSKU string    `json:"supplier_sku" example:"ACME-RTS-XL"`
+	CostPrice   float64   `gorm:"not null;default:0" json:"cost_price" example:"7.50"`
+	MinOrderQty int       `gorm:"not null;default:1" json:"min_order_qty" example:"24"`
+	PackSize    int       `gorm:"not null;default:1" json:"pack_size" example:"12"`
+	Preferred   bool      `gorm:"not null;default:false" json:"preferred" example:"true"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at" example:"2025-07-25T14:30:00Z"`
+}
+
+type SupplierRequest struct {
+	Name         string `json:"name" example:"Acme Textiles"`
+	Contact      string `json:"contact" example:"Jane Roe, jane@acme.example, +1 555 0100"`
+	LeadTimeDays int    `json:"lead_time_days" example:"14"`
+	Currency     string `json:"currency" example:"USD"`
+	PaymentTerms string `json:"payment_terms" example:"Net 30"`
+}
+
+type SupplierProductRequest struct {
+	SupplierSKU string  `json:"supplier_sku" example:"ACME-RTS-XL"`
+	CostPrice   float64 `json:"cost_price" example:"7.50"`
+	MinOrderQty int     `json:"min_order_qty" example:"24"`
+	PackSize    int     `json:"pack_size" example:"12"`
+	Preferred   bool    `json:"preferred" example:"true"`
+}
+
+// ProductSupplier is a supplier as listed on a product's detail.
+type ProductSupplier struct {
+	SupplierID   uuid.UUID `json:"supplier_id" example:"5b0f2a1c-7d3e-4e8b-a9c6-2f1e3d4c5b6a"`
+	Name         string    `json:"name" example:"Acme Textiles"`
+	SupplierSKU  string    `json:"supplier_sku" example:"ACME-RTS-XL"`
+	CostPrice    float64   `json:"cost_price" example:"7.50"`
+	Currency     string    `json:"currency" example:"USD"`
+	MinOrderQty  int       `json:"min_order_qty" example:"24"`
+	LeadTimeDays int       `json:"lead_time_days" example:"14"`
+	Preferred    bool      `json:"preferred" example:"true"`
+}
+
+// ProductDetail is a product together with its available quantity and the
+// suppliers it can be bought from.
+type ProductDetail struct {
+	Product
+	Available int               `json:"available" example:"36"`
+	Suppliers []ProductSupplier `json:"suppliers"`
+	Units     []UnitConversion  `json:"units"`
+	// Components is set for bundles, whose Available includes the kits the
+	// components could make.
+	Components []BundleComponent `json:"components,omitempty"`
+}
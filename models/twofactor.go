@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TwoFactor is a user's TOTP authenticator. It only counts once ConfirmedAt
+// is set, after the user proves their app produces valid codes. LastStep is
+// the time step of the last accepted code, so a code cannot be replayed.
+// Secret is encrypted with TOTP_ENCRYPTION_KEY; see utils.SealTOTPSecret.
+type TwoFactor struct {
+	UserID      uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id" example:"bfc5b2b1-bc0e-4f2b-8c18-7c7a47fdc9c4"`
+	Secret      string     `gorm:"not null" json:"-"`
+	LastStep    int64      `gorm:"not null;default:0" json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty" example:"2025-07-25T14:05:00Z"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at" example:"2025-07-25T14:00:00Z"`
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the
+// authenticator is lost. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// LoginChallenge is issued when a user with two-factor authentication gets
+// their password right. It is exchanged, with a TOTP or recovery code, for a
+// session. Only the token's hash is stored.
+type LoginChallenge struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TOTPEnrollment is the new authenticator's secret, as a raw base32 secret,
+// an otpauth:// URI and a QR code of the URI for authenticator apps to scan.
+type TOTPEnrollment struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"uri" example:"otpauth://totp/Inventory:john@example.com?algorithm=SHA1&digits=6&issuer=Inventory&period=30&secret=JBSWY3DPEHPK3PXP"`
+	QRCode string `json:"qr_code" example:"data:image/png;base64,iVBORw0KGgo..."`
+}
+
+// RecoveryCodes are shown once, when they are generated.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k7d2p-9xq4m,3hv8c-w2n6t"`
+}
+
+// MFAChallenge is Login's response when a second factor is needed.
+type MFAChallenge struct {
+	MFARequired    bool   `json:"mfa_required" example:"true"`
+	ChallengeToken string `json:"challenge_token" example:"c2hvcnQtbGl2ZWQtY2hhbGxlbmdlLXRva2Vu"`
+	ExpiresIn      int    `json:"expires_in" example:"300"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" example:"123456"`
+}
+
+// MFALoginRequest completes a login with either a TOTP code or a recovery
+// code.
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token" example:"c2hvcnQtbGl2ZWQtY2hhbGxlbmdlLXRva2Vu"`
+	Code           string `json:"code,omitempty" example:"123456"`
+	RecoveryCode   string `json:"recovery_code,omitempty" example:"k7d2p-9xq4m"`
+}
+
+type TwoFactorPolicyRequest struct {
+	Require bool `json:"require" example:"true"`
+}
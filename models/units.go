@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// DefaultBaseUnit is the unit stock is tracked in when a product names none.
+const DefaultBaseUnit = "each"
+
+// UnitConversion defines a pack unit for a product as a whole number of base
+// units, e.g. a box of 12 or a pallet of 480, so conversions are always exact.
+type UnitConversion struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id" example:"3a4b5c6d-7e8f-4a9b-0c1d-2e3f4a5b6c7d"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_product_unit" json:"product_id" example:"2c8a21e3-c882-4b40-9f27-35413e5e64e7"`
+	Unit      string    `gorm:"not null;uniqueIndex:idx_product_unit" json:"unit" example:"box"`
+	Factor    int       `gorm:"not null" json:"factor" example:"12"`
+}
+
+type UnitConversionRequest struct {
+	// Factor is how many base units make up one of this unit.
+	Factor int `json:"factor" example:"12"`
+}
@@ -0,0 +1,422 @@
+// Package oidc is a minimal OpenID Connect relying party: it signs users in
+// at an identity provider with the authorization code flow and PKCE, and
+// verifies the ID tokens the provider returns.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// discoveryPath is where providers publish their metadata, under the issuer.
+const discoveryPath = "/.well-known/openid-configuration"
+
+// metadataTTL is how long discovered metadata is used before it is fetched
+// again. Keys are refetched sooner when a token names a key we do not know.
+const metadataTTL = time.Hour
+
+// minKeyRefresh limits how often unknown key IDs make us refetch the JWKS.
+const minKeyRefresh = time.Minute
+
+// Config is what the provider knows us as.
+type Config struct {
+	// DiscoveryURL is the provider's metadata document, normally the issuer
+	// followed by /.well-known/openid-configuration.
+	DiscoveryURL string
+	ClientID     string
+	// ClientSecret is empty for public clients, which rely on PKCE alone.
+	ClientSecret string
+	// RedirectURL is our callback, registered with the provider.
+	RedirectURL string
+	Scopes      []string
+}
+
+// Metadata is the part of the provider's discovery document we use.
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider talks to one identity provider. It is safe for concurrent use.
+type Provider struct {
+	Config
+	Provisioning Provisioning
+	Client       *http.Client
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	metadataAt  time.Time
+	keys        map[string]jwk
+	keysFetched time.Time
+}
+
+// Default is the configured provider, or nil when single sign-on is off.
+var Default *Provider
+
+// Init configures Default from the environment. Single sign-on is off unless
+// OIDC_DISCOVERY_URL is set, in which case OIDC_CLIENT_ID and
+// OIDC_REDIRECT_URL are required too. OIDC_CLIENT_SECRET is optional and
+// OIDC_SCOPES defaults to "openid email profile"; see loadProvisioning for the
+// settings that decide organization and role. The provider is contacted
+// on first use, so it need not be up when the server starts.
+func Init() error {
+	discovery := os.Getenv("OIDC_DISCOVERY_URL")
+	if discovery == "" {
+		Default = nil
+		return nil
+	}
+	cfg := Config{
+		DiscoveryURL: discovery,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return errors.New("OIDC_DISCOVERY_URL needs OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	prov, err := loadProvisioning()
+	if err != nil {
+		return err
+	}
+	Default = New(cfg)
+	Default.Provisioning = prov
+	return nil
+}
+
+// New returns a provider for cfg.
+func New(cfg Config) *Provider {
+	return &Provider{Config: cfg, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Metadata returns the provider's discovery document, fetching it if it is
+// not cached.
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil && time.Since(p.metadataAt) < metadataTTL {
+		return p.metadata, nil
+	}
+	var md Metadata
+	if err := p.getJSON(ctx, p.DiscoveryURL, "", &md); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if md.Issuer == "" || md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: document is missing required endpoints")
+	}
+	if strings.HasSuffix(p.DiscoveryURL, discoveryPath) &&
+		strings.TrimSuffix(md.Issuer, "/") != strings.TrimSuffix(strings.TrimSuffix(p.DiscoveryURL, discoveryPath), "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %s", md.Issuer, p.DiscoveryURL)
+	}
+	if len(md.CodeChallengeMethods) > 0 && !contains(md.CodeChallengeMethods, "S256") {
+		return nil, errors.New("oidc discovery: provider does not support PKCE with S256")
+	}
+	p.metadata, p.metadataAt = &md, time.Now()
+	return p.metadata, nil
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes, URL-safe base64 encoded, for states,
+// nonces and verifiers.
+func RandomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// AuthURL is where to send the user to sign in. state comes back to the
+// callback, nonce comes back in the ID token and challenge is the PKCE code
+// challenge from NewPKCE.
+func (p *Provider) AuthURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc: bad authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Tokens is the provider's token endpoint response.
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Exchange redeems an authorization code, proving with verifier that we
+// started the login.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Tokens, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &e)
+		return nil, fmt.Errorf("oidc token request: %s: %s %s", resp.Status, e.Error, e.Description)
+	}
+	var tokens Tokens
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("oidc token request: no id_token in response")
+	}
+	return &tokens, nil
+}
+
+// Claims are the claims of an ID token or userinfo response.
+type Claims map[string]interface{}
+
+// String is the string claim name, or "".
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Bool is the boolean claim name. Some providers send "true" as a string.
+func (c Claims) Bool(name string) bool {
+	switch v := c[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// Strings is the claim name as a list, accepting a single string or an array
+// of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return v
+	}
+	return nil
+}
+
+// signingMethods are the algorithms we accept ID tokens in. "none" and
+// shared-secret algorithms are not among them.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// VerifyIDToken checks raw's signature against the provider's keys, that it
+// was issued by the provider for us and has not expired, and that it carries
+// nonce, and returns its claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (Claims, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parser := jwt.Parser{ValidMethods: signingMethods}
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.key(ctx, md, kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.Alg != "" && key.Alg != token.Method.Alg() {
+			return nil, fmt.Errorf("key %q is for %s, not %s", kid, key.Alg, token.Method.Alg())
+		}
+		return key.public, nil
+	}); err != nil {
+		return nil, fmt.Errorf("oidc id token: %w", err)
+	}
+
+	if !claims.VerifyIssuer(md.Issuer, true) {
+		return nil, errors.New("oidc id token: wrong issuer")
+	}
+	if !claims.VerifyAudience(p.ClientID, true) {
+		return nil, errors.New("oidc id token: not issued for this client")
+	}
+	if aud := Claims(claims).Strings("aud"); len(aud) > 1 && claims["azp"] != p.ClientID {
+		return nil, errors.New("oidc id token: authorized party is not this client")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("oidc id token: no expiry")
+	}
+	if claims["nonce"] != nonce {
+		return nil, errors.New("oidc id token: nonce mismatch")
+	}
+	if Claims(claims).String("sub") == "" {
+		return nil, errors.New("oidc id token: no subject")
+	}
+	return Claims(claims), nil
+}
+
+// UserInfo fetches the user's claims from the userinfo endpoint, for
+// providers that leave some out of the ID token. It returns nil claims if
+// the provider has no userinfo endpoint.
+func (p *Provider) UserInfo(ctx context.Context, accessToken string) (Claims, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if md.UserinfoEndpoint == "" {
+		return nil, nil
+	}
+	var claims Claims
+	if err := p.getJSON(ctx, md.UserinfoEndpoint, accessToken, &claims); err != nil {
+		return nil, fmt.Errorf("oidc userinfo: %w", err)
+	}
+	return claims, nil
+}
+
+// key returns the provider's key kid, refetching the key set when kid is
+// unknown, at most once per minKeyRefresh. A token without a kid is accepted
+// only while the provider has exactly one key.
+func (p *Provider) key(ctx context.Context, md *Metadata, kid string) (*jwk, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < minKeyRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	p.keysFetched = time.Now()
+	if err := p.getJSON(ctx, md.JWKSURI, "", &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+	p.keys = make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if err := k.parse(); err != nil {
+			continue
+		}
+		p.keys[k.Kid] = k
+	}
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) lookupKey(kid string) (*jwk, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return &k, true
+		}
+	}
+	k, ok := p.keys[kid]
+	return &k, ok
+}
+
+// getJSON GETs url into out, with accessToken as a bearer token if it is set.
+func (p *Provider) getJSON(ctx context.Context, url, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/models"
+)
+
+// Provisioning is how users signing in through the provider become members
+// here.
+type Provisioning struct {
+	// OrgID is the organization every single sign-on user joins. When it is
+	// uuid.Nil, new users get a personal organization as if they had
+	// registered.
+	OrgID uuid.UUID
+	// RoleClaim names the claim, usually a list of groups, that RoleMap maps
+	// to roles.
+	RoleClaim string
+	// RoleMap maps values of RoleClaim to roles. When it is empty, roles are
+	// managed here and DefaultRole is only given to new members; otherwise
+	// the provider is in charge and the role is updated at every sign-in.
+	RoleMap map[string]string
+	// DefaultRole is the role of users none of whose claim values map to one.
+	DefaultRole string
+}
+
+// loadProvisioning reads OIDC_ORG_ID, OIDC_ROLE_CLAIM (default "groups"),
+// OIDC_ROLE_MAP ("group=role,group=role") and OIDC_DEFAULT_ROLE (default
+// viewer).
+func loadProvisioning() (Provisioning, error) {
+	prov := Provisioning{
+		RoleClaim:   os.Getenv("OIDC_ROLE_CLAIM"),
+		RoleMap:     map[string]string{},
+		DefaultRole: os.Getenv("OIDC_DEFAULT_ROLE"),
+	}
+	if prov.RoleClaim == "" {
+		prov.RoleClaim = "groups"
+	}
+	if prov.DefaultRole == "" {
+		prov.DefaultRole = models.RoleViewer
+	}
+	if !models.ValidRole(prov.DefaultRole) {
+		return prov, fmt.Errorf("OIDC_DEFAULT_ROLE %q is not a role", prov.DefaultRole)
+	}
+	if id := os.Getenv("OIDC_ORG_ID"); id != "" {
+		orgID, err := uuid.Parse(id)
+		if err != nil {
+			return prov, fmt.Errorf("OIDC_ORG_ID: %w", err)
+		}
+		prov.OrgID = orgID
+	}
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAP"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(pair, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" || !models.ValidRole(role) {
+			return prov, fmt.Errorf("OIDC_ROLE_MAP entry %q is not value=role", pair)
+		}
+		prov.RoleMap[value] = role
+	}
+	return prov, nil
+}
+
+// SyncsRoles reports whether roles come from the provider.
+func (p Provisioning) SyncsRoles() bool {
+	return len(p.RoleMap) > 0
+}
+
+// RoleFor is the role claims map to: the most privileged of the roles their
+// RoleClaim values map to, or DefaultRole.
+func (p Provisioning) RoleFor(claims Claims) string {
+	granted := map[string]bool{}
+	for _, value := range claims.Strings(p.RoleClaim) {
+		if role, ok := p.RoleMap[value]; ok {
+			granted[role] = true
+		}
+	}
+	for _, info := range models.Roles() {
+		if granted[info.Role] {
+			return info.Role
+		}
+	}
+	return p.DefaultRole
+}
+
+// secondFactors are authentication methods (RFC 8176) that, next to a
+// password, make a sign-in multi-factor.
+var secondFactors = []string{"otp", "hwk", "swk", "sms", "fpt", "face", "iris", "retina", "vbm", "sc"}
+
+// MultiFactor reports whether the provider says the user signed in with more
+// than one factor: the amr claim includes "mfa", or a password and a second
+// factor.
+func MultiFactor(claims Claims) bool {
+	amr := claims.Strings("amr")
+	if contains(amr, "mfa") {
+		return true
+	}
+	if !contains(amr, "pwd") {
+		return false
+	}
+	for _, method := range secondFactors {
+		if contains(amr, method) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/lokesh2201013/controllers"
+	"github.com/lokesh2201013/models"
 	"github.com/lokesh2201013/utils"
 	//"github.com/lokesh2201013/database"
 )
@@ -11,16 +12,177 @@ import (
 func AuthRoutes(app *fiber.App) {
 	// Public routes
 	app.Post("/login", controllers.Login)
+	app.Post("/login/2fa", controllers.LoginMFA)
 	app.Post("/register", controllers.Register)
+	app.Post("/token/refresh", controllers.RefreshAccessToken)
+	app.Post("/logout", utils.AuthMiddleware(), utils.RequireSession(), controllers.Logout)
+	app.Post("/logout-all", utils.AuthMiddleware(), utils.RequireSession(), controllers.LogoutAll)
+	app.Get("/.well-known/jwks.json", controllers.GetJWKS)
+	app.Post("/password/forgot", controllers.ForgotPassword)
+	app.Post("/password/reset", controllers.ResetPassword)
+	app.Post("/email/verify", controllers.VerifyEmail)
+	app.Get("/verify-email", controllers.VerifyEmailLink)
+	app.Get("/reset-password", controllers.ResetPasswordForm)
+	app.Post("/reset-password", controllers.ResetPasswordSubmit)
+	app.Get("/auth/oidc/login", controllers.SSOLogin)
+	app.Get("/auth/oidc/callback", controllers.SSOCallback)
+	app.Post("/email/verify/resend", utils.AuthMiddleware(), utils.RequireSession(), controllers.ResendVerification)
 
-	// Protected routes (grouped)
+	// The caller's own account and sessions
+	me := app.Group("/me", utils.AuthMiddleware(), utils.RequireSession())
+	me.Get("/", controllers.GetMe)
+	me.Patch("/", controllers.UpdateMe)
+	me.Get("/sessions", controllers.GetMySessions)
+	me.Delete("/sessions/:id", controllers.RevokeMySession)
+
+	// Two-factor authentication of the caller's own account
+	twoFactor := app.Group("/2fa", utils.AuthMiddleware(), utils.RequireSession())
+	twoFactor.Post("/enroll", controllers.EnrollTOTP)
+	twoFactor.Post("/confirm", controllers.ConfirmTOTP)
+	twoFactor.Post("/recovery-codes", controllers.RegenerateRecoveryCodes)
+	twoFactor.Delete("/", controllers.DisableTOTP)
+
+	// Organizations; any verified user may list, create and switch
+	orgs := app.Group("/orgs", utils.AuthMiddleware(), utils.RequireSession(), utils.RequireVerifiedEmail())
+	orgs.Post("/", controllers.CreateOrganization)
+	orgs.Get("/", controllers.GetOrganizations)
+	orgs.Post("/:id/switch", controllers.SwitchOrganization)
+	app.Post("/invitations/accept", utils.AuthMiddleware(), utils.RequireSession(), utils.RequireVerifiedEmail(), controllers.AcceptInvitation)
+
+	// API keys of the caller in the active organization; keys cannot manage keys
+	apiKeys := app.Group("/api-keys", utils.AuthMiddleware(), utils.RequireSession(), utils.RequireVerifiedEmail())
+	apiKeys.Post("/", controllers.CreateAPIKey)
+	apiKeys.Get("/", controllers.GetAPIKeys)
+	apiKeys.Delete("/:id", controllers.RevokeAPIKey)
+
+	// Member and role administration of the active organization
+	admin := app.Group("/admin", utils.AuthMiddleware(), utils.RequirePermission(models.PermUsersManage))
+	admin.Get("/roles", controllers.GetRoles)
+	admin.Get("/users", controllers.GetUsers)
+	admin.Put("/users/:id/role", controllers.SetUserRole)
+	admin.Delete("/users/:id", controllers.RemoveMember)
+	admin.Post("/users/:id/unlock", controllers.UnlockUser)
+	admin.Post("/invitations", controllers.CreateInvitation)
+	admin.Get("/invitations", controllers.GetInvitations)
+	admin.Delete("/invitations/:id", controllers.DeleteInvitation)
+	admin.Put("/2fa-policy", controllers.SetTwoFactorPolicy)
+
+	// Audit trail of the active organization
+	auditLog := app.Group("/audit", utils.AuthMiddleware(), utils.RequirePermission(models.PermAuditRead))
+	// GET /audit?entity_type=product&from=2025-07-01&format=csv
+	auditLog.Get("/", controllers.GetAuditLog)
+	auditLog.Get("/export", controllers.ExportAuditLog)
+	auditLog.Get("/verify", controllers.VerifyAuditLog)
+
+	// Protected routes (grouped); each also requires a permission of the caller's role
 	protected := app.Group("/products", utils.AuthMiddleware())
-	protected.Post("/", controllers.ProductInsert)
-	protected.Put("/:id/quantity",controllers.UpdateQuantity)
-	protected.Get("/",controllers.GetAllUserProduct)
+	protected.Post("/", utils.RequirePermission(models.PermProductsWrite), controllers.ProductInsert)
+	protected.Put("/:id/quantity",utils.RequirePermission(models.PermStockAdjust), controllers.UpdateQuantity)
+	protected.Get("/",utils.RequirePermission(models.PermProductsRead), controllers.GetAllUserProduct)
 	// GET /products/by-id?product_id=...
-	protected.Get("/by-id", controllers.GetProductByID)       
+	protected.Get("/by-id", utils.RequirePermission(models.PermProductsRead), controllers.GetProductByID)       
 	// GET /products/quantity?most=true or ?least=true           
-    protected.Get("/quantity", controllers.GetProductByQuantityExtremes) 
+    protected.Get("/quantity", utils.RequirePermission(models.PermProductsRead), controllers.GetProductByQuantityExtremes) 
+	protected.Put("/:id/reorder-rule", utils.RequirePermission(models.PermProductsWrite), controllers.UpdateReorderRule)
+	protected.Get("/:id/lots", utils.RequirePermission(models.PermProductsRead), controllers.GetProductLots)
+	protected.Get("/:id/units", utils.RequirePermission(models.PermProductsRead), controllers.GetProductUnits)
+	protected.Put("/:id/units/:unit", utils.RequirePermission(models.PermProductsWrite), controllers.SetProductUnit)
+	protected.Delete("/:id/units/:unit", utils.RequirePermission(models.PermProductsWrite), controllers.DeleteProductUnit)
+	protected.Get("/:id/components", utils.RequirePermission(models.PermProductsRead), controllers.GetBundleComponents)
+	protected.Put("/:id/components", utils.RequirePermission(models.PermProductsWrite), controllers.SetBundleComponents)
+	protected.Get("/:id/bins", utils.RequirePermission(models.PermProductsRead), controllers.GetProductBins)
+
+	analytics := app.Group("/analytics", utils.AuthMiddleware())
+	// GET /analytics/summary?top=5
+	analytics.Get("/summary", utils.RequirePermission(models.PermAnalyticsRead), controllers.GetInventorySummary)
+
+	suppliers := app.Group("/suppliers", utils.AuthMiddleware())
+	suppliers.Post("/", utils.RequirePermission(models.PermPurchasingWrite), controllers.CreateSupplier)
+	suppliers.Get("/", utils.RequirePermission(models.PermOrdersRead), controllers.GetSuppliers)
+	suppliers.Get("/:id", utils.RequirePermission(models.PermOrdersRead), controllers.GetSupplier)
+	suppliers.Put("/:id", utils.RequirePermission(models.PermPurchasingWrite), controllers.UpdateSupplier)
+	suppliers.Delete("/:id", utils.RequirePermission(models.PermPurchasingWrite), controllers.DeleteSupplier)
+	suppliers.Get("/:id/products", utils.RequirePermission(models.PermOrdersRead), controllers.GetSupplierProducts)
+	suppliers.Put("/:id/products/:productId", utils.RequirePermission(models.PermPurchasingWrite), controllers.LinkSupplierProduct)
+	suppliers.Delete("/:id/products/:productId", utils.RequirePermission(models.PermPurchasingWrite), controllers.UnlinkSupplierProduct)
+
+	purchaseOrders := app.Group("/purchase-orders", utils.AuthMiddleware())
+	purchaseOrders.Post("/", utils.RequirePermission(models.PermPurchasingWrite), controllers.CreatePurchaseOrder)
+	purchaseOrders.Get("/", utils.RequirePermission(models.PermOrdersRead), controllers.GetPurchaseOrders)
+	purchaseOrders.Get("/:id", utils.RequirePermission(models.PermOrdersRead), controllers.GetPurchaseOrder)
+	purchaseOrders.Put("/:id", utils.RequirePermission(models.PermPurchasingWrite), controllers.UpdatePurchaseOrder)
+	purchaseOrders.Post("/:id/submit", utils.RequirePermission(models.PermPurchasingWrite), controllers.SubmitPurchaseOrder)
+	purchaseOrders.Post("/:id/receive", utils.RequirePermission(models.PermStockAdjust), controllers.ReceivePurchaseOrder)
+	purchaseOrders.Post("/:id/close", utils.RequirePermission(models.PermPurchasingWrite), controllers.ClosePurchaseOrder)
+	purchaseOrders.Post("/:id/cancel", utils.RequirePermission(models.PermPurchasingWrite), controllers.CancelPurchaseOrder)
+
+	purchasing := app.Group("/purchasing", utils.AuthMiddleware())
+	// POST /purchasing/suggest?dry_run=true
+	purchasing.Post("/suggest", utils.RequirePermission(models.PermPurchasingWrite), controllers.SuggestPurchaseOrders)
+
+	salesOrders := app.Group("/sales-orders", utils.AuthMiddleware())
+	salesOrders.Post("/", utils.RequirePermission(models.PermSalesWrite), controllers.CreateSalesOrder)
+	salesOrders.Get("/", utils.RequirePermission(models.PermOrdersRead), controllers.GetSalesOrders)
+	salesOrders.Get("/:id", utils.RequirePermission(models.PermOrdersRead), controllers.GetSalesOrder)
+	salesOrders.Post("/:id/confirm", utils.RequirePermission(models.PermSalesWrite), controllers.ConfirmSalesOrder)
+	salesOrders.Post("/:id/pick", utils.RequirePermission(models.PermSalesWrite), controllers.PickSalesOrder)
+	salesOrders.Post("/:id/pack", utils.RequirePermission(models.PermSalesWrite), controllers.PackSalesOrder)
+	salesOrders.Post("/:id/ship", utils.RequirePermission(models.PermSalesWrite), controllers.ShipSalesOrder)
+	salesOrders.Post("/:id/cancel", utils.RequirePermission(models.PermSalesWrite), controllers.CancelSalesOrder)
+
+	app.Get("/backorders", utils.AuthMiddleware(), utils.RequirePermission(models.PermOrdersRead), controllers.GetBackorders)
+
+	returns := app.Group("/returns", utils.AuthMiddleware())
+	returns.Post("/", utils.RequirePermission(models.PermSalesWrite), controllers.CreateReturn)
+	returns.Get("/", utils.RequirePermission(models.PermOrdersRead), controllers.GetReturns)
+	returns.Get("/report", utils.RequirePermission(models.PermOrdersRead), controllers.GetReturnReport)
+	returns.Get("/:id", utils.RequirePermission(models.PermOrdersRead), controllers.GetReturn)
+	returns.Post("/:id/receive", utils.RequirePermission(models.PermStockAdjust), controllers.ReceiveReturn)
+	returns.Post("/:id/inspect", utils.RequirePermission(models.PermStockAdjust), controllers.InspectReturn)
+	returns.Post("/:id/dispose", utils.RequirePermission(models.PermStockAdjust), controllers.DisposeReturn)
+	returns.Post("/:id/release", utils.RequirePermission(models.PermStockAdjust), controllers.ReleaseReturn)
+	returns.Post("/:id/cancel", utils.RequirePermission(models.PermSalesWrite), controllers.CancelReturn)
+
+	lots := app.Group("/lots", utils.AuthMiddleware())
+	// GET /lots/expiring?days=30
+	lots.Get("/expiring", utils.RequirePermission(models.PermProductsRead), controllers.GetExpiringLots)
+
+	serials := app.Group("/serials", utils.AuthMiddleware())
+	serials.Post("/transfer", utils.RequirePermission(models.PermStockAdjust), controllers.TransferSerials)
+	// GET /serials/:serial?product_id=
+	serials.Get("/:serial", utils.RequirePermission(models.PermProductsRead), controllers.GetSerial)
+
+	stocktakes := app.Group("/stocktakes", utils.AuthMiddleware())
+	stocktakes.Post("/", utils.RequirePermission(models.PermStockAdjust), controllers.CreateStocktake)
+	// GET /stocktakes?status=open&pagenum=1&limit=10
+	stocktakes.Get("/", utils.RequirePermission(models.PermProductsRead), controllers.GetStocktakes)
+	stocktakes.Get("/:id", utils.RequirePermission(models.PermProductsRead), controllers.GetStocktake)
+	stocktakes.Post("/:id/counts", utils.RequirePermission(models.PermStockAdjust), controllers.SubmitStocktakeCounts)
+	stocktakes.Post("/:id/approve", utils.RequirePermission(models.PermStockApprove), controllers.ApproveStocktake)
+	stocktakes.Post("/:id/cancel", utils.RequirePermission(models.PermStockAdjust), controllers.CancelStocktake)
+
+	assemblyOrders := app.Group("/assembly-orders", utils.AuthMiddleware())
+	assemblyOrders.Post("/", utils.RequirePermission(models.PermStockAdjust), controllers.CreateAssemblyOrder)
+	// GET /assembly-orders?status=draft&pagenum=1&limit=10
+	assemblyOrders.Get("/", utils.RequirePermission(models.PermProductsRead), controllers.GetAssemblyOrders)
+	assemblyOrders.Get("/:id", utils.RequirePermission(models.PermProductsRead), controllers.GetAssemblyOrder)
+	assemblyOrders.Post("/:id/complete", utils.RequirePermission(models.PermStockAdjust), controllers.CompleteAssemblyOrder)
+	assemblyOrders.Post("/:id/cancel", utils.RequirePermission(models.PermStockAdjust), controllers.CancelAssemblyOrder)
+
+	bins := app.Group("/bins", utils.AuthMiddleware())
+	bins.Post("/", utils.RequirePermission(models.PermProductsWrite), controllers.CreateBin)
+	bins.Get("/", utils.RequirePermission(models.PermProductsRead), controllers.GetBins)
+	// GET /bins/putaway?product_id=...&quantity=24
+	bins.Get("/putaway", utils.RequirePermission(models.PermProductsRead), controllers.SuggestPutaway)
+	bins.Post("/putaway", utils.RequirePermission(models.PermStockAdjust), controllers.PutAway)
+	bins.Get("/:id", utils.RequirePermission(models.PermProductsRead), controllers.GetBin)
+	bins.Put("/:id", utils.RequirePermission(models.PermProductsWrite), controllers.UpdateBin)
+	bins.Delete("/:id", utils.RequirePermission(models.PermProductsWrite), controllers.DeleteBin)
+	bins.Put("/:id/stock/:productId", utils.RequirePermission(models.PermStockAdjust), controllers.SetBinStock)
+
+	pickLists := app.Group("/pick-lists", utils.AuthMiddleware())
+	pickLists.Post("/", utils.RequirePermission(models.PermSalesWrite), controllers.CreatePickList)
+	pickLists.Get("/:id", utils.RequirePermission(models.PermOrdersRead), controllers.GetPickList)
+	pickLists.Post("/:id/complete", utils.RequirePermission(models.PermSalesWrite), controllers.CompletePickList)
 
 }
@@ -1,6 +1,16 @@
+import os
+import re
+import uuid
+
 import requests
 
 BASE_URL = "http://localhost:8080"
+# Run the server with MAILER=file; verification emails are appended to this file.
+MAIL_FILE = os.environ.get("MAIL_FILE", "mail.log")
+
+USERNAME = f"puja_{uuid.uuid4().hex[:8]}"
+EMAIL = f"{USERNAME}@example.com"
+PASSWORD = "correct-horse-battery-staple"
 
 def print_result(test_name, passed, expected=None, got=None, request_data=None, response_body=None):
     if passed:
@@ -15,19 +25,47 @@ def print_result(test_name, passed, expected=None, got=None, request_data=None,
             print(f"  Response Body: {response_body}")
 
 def test_register_user():
-    payload = {"username": "puja", "password": "mypassword"}
+    payload = {"username": USERNAME, "email": EMAIL, "password": PASSWORD}
     res = requests.post(f"{BASE_URL}/register", json=payload)
-    passed = res.status_code in [201, 409]
-    print_result("User Registration", passed, "201 or 409", res.status_code, payload, res.text)
+    passed = res.status_code == 201
+    print_result("User Registration", passed, 201, res.status_code, payload, res.text)
+    return passed
 
 def test_register_duplicate_user():
-    payload = {"username": "puja", "password": "mypassword"}
+    payload = {"username": USERNAME, "email": EMAIL, "password": PASSWORD}
     res = requests.post(f"{BASE_URL}/register", json=payload)
     passed = res.status_code == 409
     print_result("Register Duplicate User", passed, 409, res.status_code, payload, res.text)
 
+def read_verification_token():
+    try:
+        with open(MAIL_FILE, encoding="utf-8", newline="") as f:
+            mail = f.read()
+    except OSError:
+        return None
+    token = None
+    for message in re.split(r"(?m)^From: ", mail):
+        if f"To: {EMAIL}\r\n" not in message:
+            continue
+        match = re.search(r"/verify-email\?token=([A-Za-z0-9_-]+)", message)
+        if match:
+            token = match.group(1)
+    return token
+
+def test_verify_email():
+    token = read_verification_token()
+    if not token:
+        print("Verify Email: FAILED")
+        print(f"  No verification email for {EMAIL} in {MAIL_FILE}")
+        return False
+    payload = {"token": token}
+    res = requests.post(f"{BASE_URL}/email/verify", json=payload)
+    passed = res.status_code == 204
+    print_result("Verify Email", passed, 204, res.status_code, payload, res.text)
+    return passed
+
 def test_login():
-    payload = {"username": "puja", "password": "mypassword"}
+    payload = {"username": USERNAME, "password": PASSWORD}
     res = requests.post(f"{BASE_URL}/login", json=payload)
     token = None
     passed = False
@@ -41,7 +79,7 @@ def test_login():
     return token
 
 def test_login_with_invalid_credentials():
-    payload = {"username": "puja", "password": "wrongpassword"}
+    payload = {"username": USERNAME, "password": "wrong-horse-battery-staple"}
     res = requests.post(f"{BASE_URL}/login", json=payload)
     passed = res.status_code == 401
     print_result("Login with Invalid Credentials", passed, 401, res.status_code, payload, res.text)
@@ -135,8 +173,13 @@ def test_delete_product(token, product_id):
     print_result("Delete Product", passed, 200, res.status_code, {"product_id": product_id}, res.text)
 
 def run_all_tests():
-    test_register_user()
+    if not test_register_user():
+        print("Registration failed. Skipping further tests.")
+        return
     test_register_duplicate_user()
+    if not test_verify_email():
+        print("Email verification failed. Skipping further tests.")
+        return
     test_login_with_invalid_credentials()
 
     token = test_login()
@@ -1,6 +1,17 @@
+import os
+import re
+import uuid
+
 import requests
 
 BASE_URL = "http://localhost:8080"  # Change this to your API base URL
+# Run the server with MAILER=file; verification emails are appended to this file.
+MAIL_FILE = os.environ.get("MAIL_FILE", "mail.log")
+
+# Each run registers a fresh user, so it starts from an empty organization.
+USERNAME = f"puja_{uuid.uuid4().hex[:8]}"
+EMAIL = f"{USERNAME}@example.com"
+PASSWORD = "correct-horse-battery-staple"
 
 def print_result(test_name, passed, expected=None, got=None, request_data=None, response_body=None):
     """
@@ -23,10 +34,45 @@ def test_register_user():
     """
     Sends a POST request to register a new user.
     """
-    payload = {"username": "puja", "password": "mypassword"}
+    payload = {"username": USERNAME, "email": EMAIL, "password": PASSWORD}
     res = requests.post(f"{BASE_URL}/register", json=payload)
-    passed = res.status_code in [201, 409]  # 409 means user already exists
-    print_result("User Registration", passed, "201 or 409", res.status_code, payload, res.text)
+    passed = res.status_code == 201
+    print_result("User Registration", passed, 201, res.status_code, payload, res.text)
+    return passed
+
+def read_verification_token():
+    """
+    Finds the newest verification link mailed to EMAIL in MAIL_FILE.
+    """
+    try:
+        with open(MAIL_FILE, encoding="utf-8", newline="") as f:
+            mail = f.read()
+    except OSError:
+        return None
+    token = None
+    for message in re.split(r"(?m)^From: ", mail):
+        if f"To: {EMAIL}\r\n" not in message:
+            continue
+        match = re.search(r"/verify-email\?token=([A-Za-z0-9_-]+)", message)
+        if match:
+            token = match.group(1)
+    return token
+
+def test_verify_email():
+    """
+    Verifies the new user's email address with the mailed token.
+    Protected routes refuse users who have not verified.
+    """
+    token = read_verification_token()
+    if not token:
+        print("Verify Email: FAILED")
+        print(f"  No verification email for {EMAIL} in {MAIL_FILE}")
+        return False
+    payload = {"token": token}
+    res = requests.post(f"{BASE_URL}/email/verify", json=payload)
+    passed = res.status_code == 204
+    print_result("Verify Email", passed, 204, res.status_code, payload, res.text)
+    return passed
 
 def test_login():
     """
@@ -34,7 +80,7 @@ def test_login():
     On success, expects a 200 status and an 'access_token' in the response.
     Returns the token for use in authenticated routes.
     """
-    payload = {"username": "puja", "password": "mypassword"}
+    payload = {"username": USERNAME, "password": PASSWORD}
     res = requests.post(f"{BASE_URL}/login", json=payload)
     token = None
     passed = False
@@ -141,7 +187,12 @@ def run_all_tests():
     """
     Runs all tests in sequence.
     """
-    test_register_user()
+    if not test_register_user():
+        print("Registration failed. Skipping further tests.")
+        return
+    if not test_verify_email():
+        print("Email verification failed. Skipping further tests.")
+        return
     token = test_login()
     if not token:
         print("Login failed. Skipping further tests.")
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/models"
+)
+
+// APIKeyPrefix starts every API key, so they are easy to tell from JWTs and
+// to spot in leaked text.
+const APIKeyPrefix = "inv_"
+
+// apiKeyIDLength is how many characters after APIKeyPrefix identify a key.
+const apiKeyIDLength = 8
+
+// HashAPIKey is the stored form of an API key.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey returns a new random key, "inv_<id>_<secret>", and its prefix
+// "inv_<id>".
+func NewAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 5)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = APIKeyPrefix + strings.ToLower(base32.StdEncoding.EncodeToString(id))[:apiKeyIDLength]
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// presentedAPIKey is the API key sent in X-API-Key or as a bearer token, if
+// any.
+func presentedAPIKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(c.Get("Authorization"), "Bearer "); strings.HasPrefix(token, APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// apiKeyCacheTTL is how long an authenticated key keeps its own rate limit
+// bucket without being seen again.
+const apiKeyCacheTTL = time.Minute
+
+var (
+	authenticatedKeysMu sync.Mutex
+	authenticatedKeys   = map[string]time.Time{}
+)
+
+// RateLimitKey names the token bucket a request draws from: a key's own
+// bucket once the key has been authenticated, otherwise the client IP. Keys
+// that have never authenticated share their IP's bucket, so made-up keys
+// cannot dodge the limit.
+func RateLimitKey(c *fiber.Ctx) string {
+	if key := presentedAPIKey(c); key != "" && len(key) > len(APIKeyPrefix)+apiKeyIDLength {
+		hash := HashAPIKey(key)
+		authenticatedKeysMu.Lock()
+		until, ok := authenticatedKeys[hash]
+		authenticatedKeysMu.Unlock()
+		if ok && time.Now().Before(until) {
+			return "apikey:" + key[:len(APIKeyPrefix)+apiKeyIDLength]
+		}
+	}
+	return c.IP()
+}
+
+func rememberAuthenticatedKey(hash string) {
+	now := time.Now()
+	authenticatedKeysMu.Lock()
+	defer authenticatedKeysMu.Unlock()
+	authenticatedKeys[hash] = now.Add(apiKeyCacheTTL)
+	if len(authenticatedKeys) > 10000 {
+		for h, until := range authenticatedKeys {
+			if now.After(until) {
+				delete(authenticatedKeys, h)
+			}
+		}
+	}
+}
+
+// apiKeyOwner is an API key with what its owner may currently do.
+// MFARequired is set when the organization requires two-factor
+// authentication and the owner has not set it up.
+type apiKeyOwner struct {
+	models.APIKey
+	Role        string
+	Verified    bool
+	MFARequired bool
+}
+
+// authenticateAPIKey checks key and, if it is valid, sets the same locals as
+// a JWT would, plus apiKeyID. The permissions are the key's scopes that the
+// owner's current role in the key's organization still grants.
+func authenticateAPIKey(c *fiber.Ctx, key string) error {
+	unauthorized := func() error {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid API key"})
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix) || len(key) <= len(APIKeyPrefix)+apiKeyIDLength {
+		return unauthorized()
+	}
+	prefix := key[:len(APIKeyPrefix)+apiKeyIDLength]
+
+	var owner apiKeyOwner
+	err := database.DB.Table("api_keys k").
+		Select(`k.*, m.role, u.email_verified_at IS NOT NULL AS verified,
+			o.require_2fa AND NOT EXISTS (SELECT 1 FROM two_factors tf
+				WHERE tf.user_id = k.user_id AND tf.confirmed_at IS NOT NULL) AS mfa_required`).
+		Joins("JOIN memberships m ON m.org_id = k.org_id AND m.user_id = k.user_id").
+		Joins("JOIN users u ON u.user_id = k.user_id").
+		Joins("JOIN organizations o ON o.id = k.org_id").
+		Where("k.prefix = ?", prefix).Take(&owner).Error
+	hash := HashAPIKey(key)
+	if err != nil || subtle.ConstantTimeCompare([]byte(hash), []byte(owner.KeyHash)) != 1 {
+		return unauthorized()
+	}
+	now := time.Now()
+	if owner.RevokedAt != nil || (owner.ExpiresAt != nil && now.After(*owner.ExpiresAt)) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "API key has been revoked or has expired"})
+	}
+
+	// Record use at most once a minute to keep writes off the hot path.
+	database.DB.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", owner.ID, now.Add(-time.Minute)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": c.IP()})
+	rememberAuthenticatedKey(hash)
+
+	granted := map[string]bool{}
+	for _, p := range models.RolePermissions(owner.Role) {
+		granted[p] = true
+	}
+	perms := make([]string, 0, len(owner.Scopes))
+	for _, scope := range owner.Scopes {
+		if granted[scope] {
+			perms = append(perms, scope)
+		}
+	}
+
+	c.Locals("userID", owner.UserID.String())
+	c.Locals("orgID", owner.OrgID.String())
+	c.Locals("role", owner.Role)
+	c.Locals("permissions", perms)
+	c.Locals("emailVerified", owner.Verified)
+	c.Locals("mfaRequired", owner.MFARequired)
+	c.Locals("apiKeyID", owner.ID.String())
+	return c.Next()
+}
+
+// IsAPIKeyAuth reports whether the request was authenticated with an API key
+// rather than a session's access token.
+func IsAPIKeyAuth(c *fiber.Ctx) bool {
+	_, ok := c.Locals("apiKeyID").(string)
+	return ok
+}
+
+// RequireSession refuses API keys on routes that act on the caller's own
+// account or session, such as managing API keys. It must run after
+// AuthMiddleware.
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if IsAPIKeyAuth(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This endpoint needs a signed-in session, not an API key"})
+		}
+		return c.Next()
+	}
+}
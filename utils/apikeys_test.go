@@ -0,0 +1,52 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lokesh2201013/database"
+	"github.com/lokesh2201013/internal/testdb"
+	"github.com/lokesh2201013/models"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	app := testdb.App(t)
+	_, bearer := testdb.SignUp(t, app)
+
+	var created models.CreatedAPIKey
+	request := models.APIKeyRequest{Name: "test", Scopes: []string{models.PermProductsRead}}
+	if status := testdb.Call(t, app, fiber.MethodPost, "/api-keys", bearer, request, &created); status != fiber.StatusCreated {
+		t.Fatalf("create API key: status %d", status)
+	}
+	listProducts := func(key string) int {
+		return testdb.Call(t, app, fiber.MethodGet, "/products", key, nil, nil)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"valid key", created.Key, fiber.StatusOK},
+		{"wrong secret", created.Prefix + "_not-the-secret", fiber.StatusUnauthorized},
+		{"unknown key", "inv_zzzzzzzz_unknown", fiber.StatusUnauthorized},
+		{"too short", "inv_", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := listProducts(tt.key); status != tt.want {
+				t.Errorf("GET /products: status %d, want %d", status, tt.want)
+			}
+		})
+	}
+
+	t.Run("organization requires two-factor authentication", func(t *testing.T) {
+		if err := database.DB.Model(&models.Organization{}).Where("id = ?", created.OrgID).
+			Update("require_2fa", true).Error; err != nil {
+			t.Fatal(err)
+		}
+		if status := listProducts(created.Key); status != fiber.StatusForbidden {
+			t.Errorf("GET /products: status %d, want %d", status, fiber.StatusForbidden)
+		}
+	})
+}
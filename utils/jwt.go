@@ -1,8 +1,8 @@
 package utils
 
 import (
-	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -10,20 +10,61 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-var secretKey = []byte("itsmysecrectkey9310") 
+// DefaultAccessTokenTTL is the access token lifetime when ACCESS_TOKEN_TTL
+// is unset. Access tokens are short-lived; clients renew them with a refresh
+// token.
+const DefaultAccessTokenTTL = 15 * time.Minute
 
-func GenerateJWT(userID uuid.UUID, name string) (string, error) {
+// AccessTokenTTL is how long an access token is valid, from ACCESS_TOKEN_TTL.
+func AccessTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return DefaultAccessTokenTTL
+}
+
+// AccessClaims is who an access token is for and what it allows.
+type AccessClaims struct {
+	UserID      uuid.UUID
+	Name        string
+	SessionID   uuid.UUID
+	OrgID       uuid.UUID
+	Role        string
+	Permissions []string
+
+	// EmailVerified is false until the user verifies their email address.
+	EmailVerified bool
+	// MFARequired is set when the organization requires two-factor
+	// authentication but the session was opened without it.
+	MFARequired bool
+}
+
+// GenerateJWT issues an access token for subject. Each token gets its own ID
+// (jti) so it can be revoked individually, and carries the session ID (sid)
+// so revoking the session revokes it too. The active organization (org) and
+// the user's role and permissions in it are embedded so routes can scope and
+// authorise without a database lookup, as are whether the email address has
+// been verified and whether the organization is still waiting on a second
+// factor (mfa_required).
+func GenerateJWT(subject AccessClaims) (string, error) {
+	now := time.Now()
+	userID := subject.UserID
 	claims := jwt.MapClaims{
-		"exp":  time.Now().Add(24 * time.Hour).Unix(),
+		"exp":  now.Add(AccessTokenTTL()).Unix(),
+		"iat":  now.Unix(),
 		"iss":  "invertory",
+		"jti":  uuid.NewString(),
+		"sid":  subject.SessionID.String(),
 		"userID":  userID,
-		"email": name,
+		"email": subject.Name,
+		"org":   subject.OrgID.String(),
+		"role":  subject.Role,
+		"perms": subject.Permissions,
+		"verified": subject.EmailVerified,
+		"mfa_required": subject.MFARequired,
 	}
-	fmt.Println("+++++++++++++++++++++++++++++++++>USERID",userID)
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	signedToken, err := token.SignedString(secretKey)
+	signedToken, err := signToken(claims)
 	if err != nil {
 		log.Println("Error generating JWT:", err)
 		return "", err
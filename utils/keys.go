@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signingKey is one key of the keyring. Private is nil for keys that only
+// verify, such as a retired key kept until its tokens have expired.
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// keyring holds the key new tokens are signed with and every key tokens are
+// accepted from, by kid.
+type keyring struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty" example:"RSA"`
+	Kid string `json:"kid" example:"2025-07"`
+	Use string `json:"use" example:"sig"`
+	Alg string `json:"alg" example:"RS256"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty" example:"AQAB"`
+	Crv string `json:"crv,omitempty" example:"Ed25519"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	keys     *keyring
+	keysErr  error
+	keysOnce sync.Once
+)
+
+// LoadSigningKeys loads the keyring from the environment. It is called at
+// startup so a bad configuration fails fast; later calls return the same
+// result.
+//
+// With JWT_KEYS_DIR set, every <kid>.pem file in the directory is a key:
+// RSA keys sign with RS256 and Ed25519 keys with EdDSA. Private keys can sign
+// and verify, public keys only verify. JWT_SIGNING_KID picks the private key
+// that signs new tokens. To rotate, add the new key, switch JWT_SIGNING_KID
+// and remove the old key once its tokens have expired.
+//
+// Without it, tokens are signed with HS256 using JWT_SECRET under the kid
+// JWT_SECRET_KID (default "hs256"). Secrets are never published in the JWKS.
+func LoadSigningKeys() error {
+	keysOnce.Do(func() {
+		keys, keysErr = loadKeyring()
+	})
+	return keysErr
+}
+
+func currentKeyring() (*keyring, error) {
+	if err := LoadSigningKeys(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func loadKeyring() (*keyring, error) {
+	ring := &keyring{keys: make(map[string]*signingKey)}
+
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			// Tokens will not survive a restart, but nothing insecure ships.
+			log.Println("Warning: JWT_SECRET and JWT_KEYS_DIR not set, using a random signing secret")
+			buf := make([]byte, 32)
+			if _, err := rand.Read(buf); err != nil {
+				return nil, err
+			}
+			secret = hex.EncodeToString(buf)
+		}
+		kid := os.Getenv("JWT_SECRET_KID")
+		if kid == "" {
+			kid = "hs256"
+		}
+		ring.signing = &signingKey{ID: kid, Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+		ring.keys[kid] = ring.signing
+		return ring, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := readPEMKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %s: %w", file, err)
+		}
+		key.ID = kid
+		ring.keys[kid] = key
+	}
+
+	kid := os.Getenv("JWT_SIGNING_KID")
+	signing, ok := ring.keys[kid]
+	if !ok || signing.Private == nil {
+		return nil, fmt.Errorf("JWT_SIGNING_KID %q is not a private key in %s", kid, dir)
+	}
+	ring.signing = signing
+	return ring, nil
+}
+
+// readPEMKey parses an RSA or Ed25519 private or public key, in PKCS#1,
+// PKCS#8 or PKIX form.
+func readPEMKey(file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &signingKey{Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &signingKey{Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{Method: jwt.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &signingKey{Method: jwt.SigningMethodEdDSA, Public: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// signToken signs claims with the current signing key, naming it in the kid
+// header.
+func signToken(claims jwt.Claims) (string, error) {
+	ring, err := currentKeyring()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(ring.signing.Method, claims)
+	token.Header["kid"] = ring.signing.ID
+	return token.SignedString(ring.signing.Private)
+}
+
+// verificationKey is the jwt.Keyfunc for tokens we issued: it looks the key
+// up by kid and only accepts the algorithm that key was loaded for.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	ring, err := currentKeyring()
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ring.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// PublicJWKS returns the public verification keys, ordered by kid. Shared
+// secrets are left out, so the set is empty when signing with HS256.
+func PublicJWKS() (JWKSet, error) {
+	ring, err := currentKeyring()
+	if err != nil {
+		return JWKSet{}, err
+	}
+	set := JWKSet{Keys: []JWK{}}
+	for kid, key := range ring.keys {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set, nil
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// writePEM writes der as a PEM block of the given type to dir/<kid>.pem.
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// keyDir writes an RSA private key "rsa", an Ed25519 private key "ed" and
+// the public half of a retired RSA key "old" to a new directory.
+func keyDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "rsa", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "ed", "PRIVATE KEY", der)
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if der, err = x509.MarshalPKIXPublicKey(&oldKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "old", "PUBLIC KEY", der)
+	return dir
+}
+
+// useKeyring makes ring the keyring for the rest of the test.
+func useKeyring(t *testing.T, ring *keyring) {
+	t.Helper()
+	keysOnce.Do(func() {})
+	saved, savedErr := keys, keysErr
+	keys, keysErr = ring, nil
+	t.Cleanup(func() { keys, keysErr = saved, savedErr })
+}
+
+func TestLoadKeyringFromDir(t *testing.T) {
+	dir := keyDir(t)
+	t.Setenv("JWT_KEYS_DIR", dir)
+
+	t.Setenv("JWT_SIGNING_KID", "old")
+	if _, err := loadKeyring(); err == nil {
+		t.Error("signing with a public key: want an error")
+	}
+
+	t.Setenv("JWT_SIGNING_KID", "ed")
+	ring, err := loadKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring.signing.ID != "ed" || ring.signing.Method != jwt.SigningMethodEdDSA {
+		t.Errorf("signing with %s/%s, want ed/EdDSA", ring.signing.ID, ring.signing.Method.Alg())
+	}
+	if len(ring.keys) != 3 || ring.keys["rsa"].Method != jwt.SigningMethodRS256 || ring.keys["old"].Private != nil {
+		t.Errorf("keys = %v, want rsa and ed to sign and old only to verify", ring.keys)
+	}
+
+	useKeyring(t, ring)
+	set, err := PublicJWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kids []string
+	for _, key := range set.Keys {
+		kids = append(kids, key.Kid+"/"+key.Kty)
+	}
+	if len(kids) != 3 || kids[0] != "ed/OKP" || kids[1] != "old/RSA" || kids[2] != "rsa/RSA" {
+		t.Errorf("JWKS = %v, want [ed/OKP old/RSA rsa/RSA]", kids)
+	}
+}
+
+func TestRejectsWeakRSAKey(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "weak", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	if _, err := readPEMKey(filepath.Join(dir, "weak.pem")); err == nil {
+		t.Error("1024-bit RSA key: want an error")
+	}
+}
+
+func TestVerificationKey(t *testing.T) {
+	t.Setenv("JWT_KEYS_DIR", keyDir(t))
+	t.Setenv("JWT_SIGNING_KID", "rsa")
+	ring, err := loadKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKeyring(t, ring)
+
+	claims := jwt.MapClaims{"sub": "someone"}
+	signed, err := signToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, verificationKey); err != nil {
+		t.Errorf("token we signed: %v", err)
+	}
+
+	// An HS256 token keyed with the RSA public key must not pass as RS256.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "rsa"
+	pub, err := x509.MarshalPKIXPublicKey(ring.keys["rsa"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(token, verificationKey); err == nil {
+		t.Error("HS256 token under an RSA kid: want an error")
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	unknown.Header["kid"] = "missing"
+	token, err = unknown.SignedString(ring.keys["ed"].Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(token, verificationKey); err == nil {
+		t.Error("unknown kid: want an error")
+	}
+}
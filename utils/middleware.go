@@ -1,16 +1,37 @@
 package utils
 
 import (
-	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/lokesh2201013/database"
 )
 
+// tokenRevoked reports whether the access token jti, issued in session sid,
+// has been revoked, either on its own or by revoking or expiring its session.
+func tokenRevoked(jti, sid string) (bool, error) {
+	sessionID, err := uuid.Parse(sid)
+	if jti == "" || err != nil {
+		return true, nil
+	}
+	var revoked bool
+	err = database.DB.Raw(`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)
+		OR NOT EXISTS (SELECT 1 FROM sessions WHERE id = ? AND revoked_at IS NULL AND expires_at > NOW())`,
+		jti, sessionID).Scan(&revoked).Error
+	return revoked, err
+}
+
 func AuthMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// API keys are accepted alongside access tokens.
+		if key := presentedAPIKey(c); key != "" {
+			return authenticateAPIKey(c, key)
+		}
+
 		tokenHeader := c.Get("Authorization")
 		if tokenHeader == "" || !strings.HasPrefix(tokenHeader, "Bearer ") {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -20,12 +41,7 @@ func AuthMiddleware() fiber.Handler {
 
 		tokenString := strings.TrimPrefix(tokenHeader, "Bearer ")
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid signing method")
-			}
-			return secretKey, nil
-		})
+		token, err := jwt.Parse(tokenString, verificationKey)
 
 		if err != nil || !token.Valid {
 			log.Println("Token validation error:", err)
@@ -33,10 +49,99 @@ func AuthMiddleware() fiber.Handler {
 		}
 
 		claims := token.Claims.(jwt.MapClaims)
+		jti, _ := claims["jti"].(string)
+		sid, _ := claims["sid"].(string)
+		revoked, err := tokenRevoked(jti, sid)
+		if err != nil {
+			log.Println("Token revocation check error:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+		}
+		if revoked {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token has been revoked"})
+		}
+
 		c.Locals("userID", claims["userID"])
+		c.Locals("orgID", claims["org"])
+		c.Locals("role", claims["role"])
+		c.Locals("permissions", claimPermissions(claims))
+		verified, _ := claims["verified"].(bool)
+		c.Locals("emailVerified", verified)
+		mfaRequired, _ := claims["mfa_required"].(bool)
+		c.Locals("mfaRequired", mfaRequired)
+		c.Locals("jti", jti)
+		c.Locals("sid", sid)
+		if exp, ok := claims["exp"].(float64); ok {
+			c.Locals("exp", time.Unix(int64(exp), 0))
+		}
 		c.Locals("email", claims["name"])
-		fmt.Println("claims-=-=-=-=-=-=-=-=-=-=-=-=-=->",claims["userID"],claims["name"])
 
 		return c.Next()
 	}
 }
+
+// claimPermissions reads the perms claim, which decodes as []interface{}.
+func claimPermissions(claims jwt.MapClaims) []string {
+	raw, _ := claims["perms"].([]interface{})
+	perms := make([]string, 0, len(raw))
+	for _, p := range raw {
+		if s, ok := p.(string); ok {
+			perms = append(perms, s)
+		}
+	}
+	return perms
+}
+
+// HasPermission reports whether the authenticated caller's token grants
+// permission.
+func HasPermission(c *fiber.Ctx, permission string) bool {
+	perms, _ := c.Locals("permissions").([]string)
+	for _, p := range perms {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailVerified reports whether the authenticated caller has verified their
+// email address.
+func EmailVerified(c *fiber.Ctx) bool {
+	verified, _ := c.Locals("emailVerified").(bool)
+	return verified
+}
+
+// errEmailNotVerified is how routes refuse callers who have not verified
+// their email address.
+func errEmailNotVerified(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Email address not verified"})
+}
+
+// RequireVerifiedEmail only lets through callers who have verified their
+// email address. It must run after AuthMiddleware.
+func RequireVerifiedEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !EmailVerified(c) {
+			return errEmailNotVerified(c)
+		}
+		return c.Next()
+	}
+}
+
+// RequirePermission only lets requests through whose token grants
+// permission, and only for callers who have verified their email address and
+// satisfied their organization's two-factor requirement. It must run after
+// AuthMiddleware.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !EmailVerified(c) {
+			return errEmailNotVerified(c)
+		}
+		if required, _ := c.Locals("mfaRequired").(bool); required {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Organization requires two-factor authentication; set it up at /2fa/enroll and sign in again"})
+		}
+		if !HasPermission(c, permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Missing permission " + permission})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRequirePermission(t *testing.T) {
+	claims := jwt.MapClaims{"perms": []interface{}{"orders:read", 42, "sales:write"}}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("permissions", claimPermissions(claims))
+		c.Locals("emailVerified", c.Get("X-Verified") == "yes")
+		return c.Next()
+	})
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Get("/orders", RequirePermission("orders:read"), ok)
+	app.Get("/users", RequirePermission("users:manage"), ok)
+
+	for _, tc := range []struct {
+		path     string
+		verified bool
+		want     int
+	}{
+		{"/orders", true, fiber.StatusOK},
+		{"/users", true, fiber.StatusForbidden},
+		{"/orders", false, fiber.StatusForbidden},
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, tc.path, nil)
+		if tc.verified {
+			req.Header.Set("X-Verified", "yes")
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("GET %s (verified %v): status %d, want %d", tc.path, tc.verified, resp.StatusCode, tc.want)
+		}
+	}
+}
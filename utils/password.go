@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// MinPasswordLength is the fewest characters a password may have.
+	MinPasswordLength = 10
+	// MaxPasswordLength is the most bytes bcrypt will hash.
+	MaxPasswordLength = 72
+	// minPasswordDistinct stops passwords like "aaaaaaaaaa".
+	minPasswordDistinct = 5
+)
+
+//go:embed breached_passwords.txt
+var embeddedBreachedPasswords string
+
+var (
+	breachedMu     sync.RWMutex
+	breachedHashes = map[string]struct{}{}
+)
+
+func passwordSHA1(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// addBreachedLine adds one list entry: a password, or the upper- or
+// lower-case SHA-1 hex of one optionally followed by ":count" as in the Have
+// I Been Pwned downloads. Plain passwords are stored lower-cased.
+func addBreachedLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	if hash, _, _ := strings.Cut(line, ":"); len(hash) == 40 {
+		if _, err := hex.DecodeString(hash); err == nil {
+			breachedHashes[strings.ToUpper(hash)] = struct{}{}
+			return
+		}
+	}
+	breachedHashes[passwordSHA1(strings.ToLower(line))] = struct{}{}
+}
+
+func init() {
+	for _, line := range strings.Split(embeddedBreachedPasswords, "\n") {
+		addBreachedLine(line)
+	}
+}
+
+// LoadBreachedPasswords adds the passwords in BREACHED_PASSWORDS_FILE, one
+// per line as a password or a SHA-1 hash, to the built-in list of breached
+// passwords. It does nothing when the variable is unset.
+func LoadBreachedPasswords() error {
+	path := os.Getenv("BREACHED_PASSWORDS_FILE")
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	breachedMu.Lock()
+	defer breachedMu.Unlock()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		addBreachedLine(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// PasswordBreached reports whether password, ignoring case, is on the list
+// of breached passwords.
+func PasswordBreached(password string) bool {
+	breachedMu.RLock()
+	defer breachedMu.RUnlock()
+	for _, candidate := range []string{password, strings.ToLower(password)} {
+		if _, ok := breachedHashes[passwordSHA1(candidate)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordProblem explains why password is too weak, or returns "" if it is
+// acceptable. personal holds the user's own details, such as their username
+// and email, which the password must not contain.
+func PasswordProblem(password string, personal ...string) string {
+	if len([]rune(password)) < MinPasswordLength {
+		return "password must be at least 10 characters"
+	}
+	if len(password) > MaxPasswordLength {
+		return "password must be at most 72 bytes"
+	}
+	distinct := map[rune]struct{}{}
+	for _, r := range password {
+		distinct[r] = struct{}{}
+	}
+	if len(distinct) < minPasswordDistinct {
+		return "password must use at least 5 different characters"
+	}
+	lower := strings.ToLower(password)
+	for _, p := range personal {
+		if p = strings.ToLower(strings.TrimSpace(p)); len(p) >= 3 && strings.Contains(lower, p) {
+			return "password must not contain your username or email"
+		}
+	}
+	if PasswordBreached(password) {
+		return "password appears in a list of breached passwords; choose another"
+	}
+	return ""
+}
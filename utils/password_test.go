@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordProblem(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     string
+	}{
+		{"acceptable", "correct horse battery", nil, ""},
+		{"too short", "Tr0ub4dor", nil, "password must be at least 10 characters"},
+		{"length counts characters not bytes", "ééééabcdef", nil, ""},
+		{"too long for bcrypt", strings.Repeat("abcdefghij", 7) + "xyz", nil, "password must be at most 72 bytes"},
+		{"exactly 72 bytes", strings.Repeat("abcdefghi", 8), nil, ""},
+		{"too few distinct characters", "abababababab", nil, "password must use at least 5 different characters"},
+		{"contains username", "xxJohnDoe-2025", []string{"johndoe", "jd"}, "password must not contain your username or email"},
+		{"contains email local part", "my john.doe secret", []string{"jdoe", "john.doe"}, "password must not contain your username or email"},
+		{"short personal details are ignored", "correct horse battery", []string{"co", " "}, ""},
+		{"breached", "qwertyuiop", nil, "password appears in a list of breached passwords; choose another"},
+		{"breached ignoring case", "QwertyUiop", nil, "password appears in a list of breached passwords; choose another"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PasswordProblem(tt.password, tt.personal...); got != tt.want {
+				t.Errorf("PasswordProblem(%q) = %q, want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddBreachedLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		password string
+	}{
+		{"plain password", "Purple-Monkey-Dishwasher", "purple-monkey-dishwasher"},
+		{"upper-case SHA-1", passwordSHA1("orange-giraffe-toaster"), "orange-giraffe-toaster"},
+		{"lower-case SHA-1 with count", strings.ToLower(passwordSHA1("green-otter-kettle")) + ":42", "green-otter-kettle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if PasswordBreached(tt.password) {
+				t.Fatalf("%q already breached", tt.password)
+			}
+			addBreachedLine(tt.line)
+			if !PasswordBreached(tt.password) {
+				t.Errorf("%q not breached after adding %q", tt.password, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// sealedPrefix marks a TOTP secret encrypted with the TOTP key. Authenticator
+// secrets are base32, so it cannot start a plaintext one.
+const sealedPrefix = "v1:"
+
+var (
+	totpAEAD    cipher.AEAD
+	totpKeyErr  error
+	totpKeyOnce sync.Once
+)
+
+// LoadTOTPKey loads the key TOTP secrets are encrypted with at rest. It is
+// called at startup so a bad configuration fails fast; later calls return the
+// same result.
+//
+// TOTP_ENCRYPTION_KEY is 32 bytes, base64 encoded (openssl rand -base64 32).
+// Without it a random key is used, and authenticators enrolled before a
+// restart stop working, so recovery codes are the only way back in.
+func LoadTOTPKey() error {
+	totpKeyOnce.Do(func() {
+		totpAEAD, totpKeyErr = loadTOTPKey()
+	})
+	return totpKeyErr
+}
+
+func loadTOTPKey() (cipher.AEAD, error) {
+	var key []byte
+	if encoded := strings.TrimSpace(os.Getenv("TOTP_ENCRYPTION_KEY")); encoded != "" {
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY: want 32 bytes, got %d", len(key))
+		}
+	} else {
+		log.Println("Warning: TOTP_ENCRYPTION_KEY not set, using a random key; enrolled authenticators stop working after a restart")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func currentTOTPKey() (cipher.AEAD, error) {
+	if err := LoadTOTPKey(); err != nil {
+		return nil, err
+	}
+	return totpAEAD, nil
+}
+
+// SealTOTPSecret encrypts userID's authenticator secret for storage. The
+// ciphertext is bound to userID, so it cannot be copied to another account.
+func SealTOTPSecret(userID uuid.UUID, secret string) (string, error) {
+	aead, err := currentTOTPKey()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), userID[:])
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenTOTPSecret decrypts a secret sealed by SealTOTPSecret for userID.
+func OpenTOTPSecret(userID uuid.UUID, stored string) (string, error) {
+	aead, err := currentTOTPKey()
+	if err != nil {
+		return "", err
+	}
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return "", errors.New("TOTP secret is not encrypted")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed TOTP secret")
+	}
+	secret, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], userID[:])
+	if err != nil {
+		return "", errors.New("TOTP secret does not decrypt with TOTP_ENCRYPTION_KEY")
+	}
+	return string(secret), nil
+}
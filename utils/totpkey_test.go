@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTOTPSecretSealing(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	owner, other := uuid.New(), uuid.New()
+
+	sealed, err := SealTOTPSecret(owner, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, secret) {
+		t.Fatalf("sealed secret %q contains the plaintext", sealed)
+	}
+	again, err := SealTOTPSecret(owner, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == sealed {
+		t.Error("sealing twice gave the same ciphertext")
+	}
+
+	tests := []struct {
+		name    string
+		userID  uuid.UUID
+		stored  string
+		want    string
+		wantErr bool
+	}{
+		{"owner", owner, sealed, secret, false},
+		{"another account", other, sealed, "", true},
+		{"plaintext", owner, secret, "", true},
+		{"tampered", owner, sealed[:len(sealed)-2] + "AA", "", true},
+		{"truncated", owner, sealedPrefix + "AAAA", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpenTOTPSecret(tt.userID, tt.stored)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("OpenTOTPSecret = (%q, %v), want (%q, error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}